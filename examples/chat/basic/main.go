@@ -5,22 +5,15 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/pixelsquared/go-tabbyapi/tabby"
 )
 
 func main() {
-	// Get API endpoint and key from environment variables
-	endpoint := getEnvOrDefault("TABBY_API_ENDPOINT", "http://localhost:8080")
-	apiKey := os.Getenv("TABBY_API_KEY")
-
-	// Create a new TabbyAPI client
-	client := tabby.NewClient(
-		tabby.WithBaseURL(endpoint),
-		tabby.WithAPIKey(apiKey),
-		tabby.WithTimeout(30*time.Second),
+	// Create a new TabbyAPI client configured from TABBY_API_ENDPOINT and TABBY_API_KEY
+	client := tabby.NewClientFromEnv(
+		tabby.WithTimeout(30 * time.Second),
 	)
 	// Ensure the client is closed properly
 	defer client.Close()
@@ -42,10 +35,10 @@ func main() {
 				Content: "What are the main benefits of using Go for backend development?",
 			},
 		},
-		MaxTokens:   150, // Maximum number of tokens to generate
-		Temperature: 0.7, // Controls randomness
-		TopP:        0.9, // Top-p sampling
-		TopK:        40,  // Top-k sampling
+		MaxTokens:   150,                // Maximum number of tokens to generate
+		Temperature: tabby.Float64(0.7), // Controls randomness
+		TopP:        tabby.Float64(0.9), // Top-p sampling
+		TopK:        40,                 // Top-k sampling
 	}
 
 	// Call the API to generate a chat completion
@@ -80,11 +73,8 @@ func main() {
 	fmt.Println("\n--- Continuing the conversation ---")
 
 	// Add the assistant's response to the conversation history
-	if len(resp.Choices) > 0 {
-		req.Messages = append(req.Messages, tabby.ChatMessage{
-			Role:    tabby.ChatMessageRoleAssistant,
-			Content: resp.Choices[0].Message.Content,
-		})
+	if msg, ok := resp.FirstMessage(); ok {
+		req.Messages = append(req.Messages, msg)
 	}
 
 	// Add a follow-up question from the user
@@ -106,12 +96,3 @@ func main() {
 		fmt.Println(resp2.Choices[0].Message.Content)
 	}
 }
-
-// getEnvOrDefault returns the value of the environment variable or a default value
-func getEnvOrDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
@@ -6,22 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/pixelsquared/go-tabbyapi/tabby"
 )
 
 func main() {
-	// Get API endpoint and key from environment variables
-	endpoint := getEnvOrDefault("TABBY_API_ENDPOINT", "http://localhost:8080")
-	apiKey := os.Getenv("TABBY_API_KEY")
-
-	// Create a new TabbyAPI client with a 30-second timeout
-	client := tabby.NewClient(
-		tabby.WithBaseURL(endpoint),
-		tabby.WithAPIKey(apiKey),
-		tabby.WithTimeout(30*time.Second),
+	// Create a new TabbyAPI client configured from TABBY_API_ENDPOINT and TABBY_API_KEY
+	client := tabby.NewClientFromEnv(
+		tabby.WithTimeout(30 * time.Second),
 	)
 	// Ensure the client is closed properly
 	defer client.Close()
@@ -83,8 +76,8 @@ func main() {
 			},
 		},
 		MaxTokens:   300,
-		Temperature: 0.7,
-		TopP:        0.9,
+		Temperature: tabby.Float64(0.7),
+		TopP:        tabby.Float64(0.9),
 		JSONSchema:  reviewSchema,
 	}
 
@@ -133,12 +126,3 @@ func main() {
 		fmt.Printf("  Total tokens: %d\n", resp.Usage.TotalTokens)
 	}
 }
-
-// getEnvOrDefault returns the value of the environment variable or a default value
-func getEnvOrDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
@@ -6,22 +6,15 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"time"
 
 	"github.com/pixelsquared/go-tabbyapi/tabby"
 )
 
 func main() {
-	// Get API endpoint and key from environment variables
-	endpoint := getEnvOrDefault("TABBY_API_ENDPOINT", "http://localhost:8080")
-	apiKey := os.Getenv("TABBY_API_KEY")
-
-	// Create a new TabbyAPI client
-	client := tabby.NewClient(
-		tabby.WithBaseURL(endpoint),
-		tabby.WithAPIKey(apiKey),
-		tabby.WithTimeout(60*time.Second), // Longer timeout for streaming
+	// Create a new TabbyAPI client configured from TABBY_API_ENDPOINT and TABBY_API_KEY
+	client := tabby.NewClientFromEnv(
+		tabby.WithTimeout(60 * time.Second), // Longer timeout for streaming
 	)
 	// Ensure the client is closed properly
 	defer client.Close()
@@ -43,8 +36,8 @@ func main() {
 			},
 		},
 		MaxTokens:   300,
-		Temperature: 0.8,
-		TopP:        0.95,
+		Temperature: tabby.Float64(0.8),
+		TopP:        tabby.Float64(0.95),
 		TopK:        50,
 		Stream:      true, // Enable streaming response
 	}
@@ -139,12 +132,3 @@ func main() {
 	fmt.Print("	// Additional parameters would be set here\n")
 	fmt.Print("}\n")
 }
-
-// getEnvOrDefault returns the value of the environment variable or a default value
-func getEnvOrDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
@@ -5,22 +5,15 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/pixelsquared/go-tabbyapi/tabby"
 )
 
 func main() {
-	// Get API endpoint and key from environment variables
-	endpoint := getEnvOrDefault("TABBY_API_ENDPOINT", "http://localhost:8080")
-	apiKey := os.Getenv("TABBY_API_KEY")
-
-	// Create a new TabbyAPI client with a 30-second timeout
-	client := tabby.NewClient(
-		tabby.WithBaseURL(endpoint),
-		tabby.WithAPIKey(apiKey),
-		tabby.WithTimeout(30*time.Second),
+	// Create a new TabbyAPI client configured from TABBY_API_ENDPOINT and TABBY_API_KEY
+	client := tabby.NewClientFromEnv(
+		tabby.WithTimeout(30 * time.Second),
 	)
 	// Ensure the client is closed properly
 	defer client.Close()
@@ -33,11 +26,11 @@ func main() {
 	req := &tabby.CompletionRequest{
 		// The prompt can be a string or an array of strings
 		Prompt:      "Once upon a time, there was a programmer who",
-		MaxTokens:   100,           // Maximum number of tokens to generate
-		Temperature: 0.7,           // Controls randomness: 0.0 is deterministic, higher values are more random
-		TopP:        0.9,           // Top-p sampling: 1.0 is no filtering, lower is more focused
-		TopK:        40,            // Top-k sampling: higher values allow more diverse completions
-		Stop:        []string{"."}, // Stop generation at these strings (optional)
+		MaxTokens:   100,                // Maximum number of tokens to generate
+		Temperature: tabby.Float64(0.7), // Controls randomness: 0.0 is deterministic, higher values are more random
+		TopP:        tabby.Float64(0.9), // Top-p sampling: 1.0 is no filtering, lower is more focused
+		TopK:        40,                 // Top-k sampling: higher values allow more diverse completions
+		Stop:        []string{"."},      // Stop generation at these strings (optional)
 	}
 
 	// Call the API to generate a completion
@@ -68,12 +61,3 @@ func main() {
 		fmt.Printf("  Total tokens: %d\n", resp.Usage.TotalTokens)
 	}
 }
-
-// getEnvOrDefault returns the value of the environment variable or a default value
-func getEnvOrDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
@@ -6,22 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/pixelsquared/go-tabbyapi/tabby"
 )
 
 func main() {
-	// Get API endpoint and key from environment variables
-	endpoint := getEnvOrDefault("TABBY_API_ENDPOINT", "http://localhost:8080")
-	apiKey := os.Getenv("TABBY_API_KEY")
-
-	// Create a new TabbyAPI client with a 30-second timeout
-	client := tabby.NewClient(
-		tabby.WithBaseURL(endpoint),
-		tabby.WithAPIKey(apiKey),
-		tabby.WithTimeout(30*time.Second),
+	// Create a new TabbyAPI client configured from TABBY_API_ENDPOINT and TABBY_API_KEY
+	client := tabby.NewClientFromEnv(
+		tabby.WithTimeout(30 * time.Second),
 	)
 	// Ensure the client is closed properly
 	defer client.Close()
@@ -63,8 +56,8 @@ func main() {
 	req := &tabby.CompletionRequest{
 		Prompt:      "Generate information about a software developer named John who loves golang",
 		MaxTokens:   256,
-		Temperature: 0.7,
-		TopP:        0.9,
+		Temperature: tabby.Float64(0.7),
+		TopP:        tabby.Float64(0.9),
 		JSONSchema:  personSchema,
 	}
 
@@ -76,13 +69,13 @@ func main() {
 	}
 
 	// Extract and print the generated text
-	if len(resp.Choices) > 0 {
+	if text, ok := resp.FirstText(); ok {
 		fmt.Println("\nGenerated JSON:")
-		fmt.Println(resp.Choices[0].Text)
+		fmt.Println(text)
 
 		// Parse the JSON response to verify it matches our schema
 		var person map[string]interface{}
-		err := json.Unmarshal([]byte(resp.Choices[0].Text), &person)
+		err := json.Unmarshal([]byte(text), &person)
 		if err != nil {
 			fmt.Printf("\nError parsing JSON: %v\n", err)
 		} else {
@@ -108,12 +101,3 @@ func main() {
 		fmt.Printf("  Total tokens: %d\n", resp.Usage.TotalTokens)
 	}
 }
-
-// getEnvOrDefault returns the value of the environment variable or a default value
-func getEnvOrDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
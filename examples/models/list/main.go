@@ -5,22 +5,16 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/pixelsquared/go-tabbyapi/tabby"
 )
 
 func main() {
-	// Get API endpoint and key from environment variables
-	endpoint := getEnvOrDefault("TABBY_API_ENDPOINT", "http://localhost:8080")
-	adminKey := os.Getenv("TABBY_ADMIN_KEY") // Admin key for model management
-
-	// Create a new TabbyAPI client with admin privileges
-	client := tabby.NewClient(
-		tabby.WithBaseURL(endpoint),
-		tabby.WithAdminKey(adminKey), // Note: Model management requires admin access
-		tabby.WithTimeout(60*time.Second),
+	// Create a new TabbyAPI client configured from TABBY_API_ENDPOINT and TABBY_ADMIN_KEY
+	// Note: Model management requires admin access
+	client := tabby.NewClientFromEnv(
+		tabby.WithTimeout(60 * time.Second),
 	)
 	// Ensure the client is closed properly
 	defer client.Close()
@@ -87,8 +81,8 @@ func main() {
 			fmt.Printf("\nModel Properties:\n")
 			fmt.Printf("  Total Slots: %d\n", props.TotalSlots)
 			fmt.Printf("  Chat Template: %s\n", props.ChatTemplate)
-			if props.DefaultGenerationSettings != nil {
-				fmt.Printf("  Default Context Length: %d\n", props.DefaultGenerationSettings.NCtx)
+			if n := props.ContextLength(); n > 0 {
+				fmt.Printf("  Default Context Length: %d\n", n)
 			}
 		}
 	}
@@ -136,12 +130,3 @@ func main() {
 		}
 	}
 }
-
-// getEnvOrDefault returns the value of the environment variable or a default value
-func getEnvOrDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
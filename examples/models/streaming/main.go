@@ -13,15 +13,10 @@ import (
 )
 
 func main() {
-	// Get API endpoint and key from environment variables
-	endpoint := getEnvOrDefault("TABBY_API_ENDPOINT", "http://localhost:8080")
-	adminKey := os.Getenv("TABBY_ADMIN_KEY") // Admin key required for model management
-
-	// Create a new TabbyAPI client with admin privileges
-	client := tabby.NewClient(
-		tabby.WithBaseURL(endpoint),
-		tabby.WithAdminKey(adminKey),       // Note: Model management requires admin access
-		tabby.WithTimeout(300*time.Second), // Longer timeout for model loading
+	// Create a new TabbyAPI client configured from TABBY_API_ENDPOINT and TABBY_ADMIN_KEY
+	// Note: Model management requires admin access
+	client := tabby.NewClientFromEnv(
+		tabby.WithTimeout(300 * time.Second), // Longer timeout for model loading
 	)
 	// Ensure the client is closed properly
 	defer client.Close()
@@ -79,18 +74,20 @@ func main() {
 			log.Fatalf("Error receiving from stream: %v", err)
 		}
 
-		// Store total modules count when we first get it
-		if totalModules == 0 && response.Modules > 0 {
+		// Remember the total module count, since updates may leave it out
+		if response.Modules > 0 {
 			totalModules = response.Modules
 		}
 
-		// Update progress
-		currentModule := response.Module
+		// Compute progress against the remembered total; Progress reports 0%
+		// until the total is known
+		update := *response
+		update.Modules = totalModules
+		currentModule, _, progress := update.Progress()
 
 		// Only print if there's been a change in module number
 		if currentModule != lastModule {
 			elapsedTime := time.Since(startTime)
-			progress := float64(currentModule) / float64(totalModules) * 100.0
 
 			fmt.Printf("Loading module %d of %d (%.1f%%) - Model type: %s - Status: %s - Elapsed: %s\n",
 				currentModule, totalModules, progress, response.ModelType, response.Status, elapsedTime.Round(time.Second))
@@ -120,7 +117,7 @@ func main() {
 		if err != nil {
 			fmt.Printf("Error getting model properties: %v\n", err)
 		} else {
-			fmt.Printf("Model context length: %d tokens\n", props.DefaultGenerationSettings.NCtx)
+			fmt.Printf("Model context length: %d tokens\n", props.ContextLength())
 		}
 	}
 
@@ -135,7 +132,7 @@ func testCompletion(client tabby.Client, ctx context.Context) {
 	req := &tabby.CompletionRequest{
 		Prompt:      "Hello, world!",
 		MaxTokens:   20,
-		Temperature: 0.7,
+		Temperature: tabby.Float64(0.7),
 	}
 
 	// Call the API
@@ -146,10 +143,10 @@ func testCompletion(client tabby.Client, ctx context.Context) {
 	}
 
 	// Print the result
-	if len(resp.Choices) > 0 {
+	if text, ok := resp.FirstText(); ok {
 		fmt.Println("\nModel test response:")
 		fmt.Printf("Prompt: \"Hello, world!\"\n")
-		fmt.Printf("Response: \"%s\"\n", resp.Choices[0].Text)
+		fmt.Printf("Response: \"%s\"\n", text)
 	} else {
 		fmt.Println("No completion text was generated")
 	}
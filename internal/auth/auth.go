@@ -2,6 +2,7 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 )
 
@@ -64,3 +65,18 @@ func NewAdminKeyAuthenticator(key string) Authenticator {
 func NewBearerTokenAuthenticator(token string) Authenticator {
 	return &BearerTokenAuthenticator{Token: token}
 }
+
+// contextKey is the context key for a per-request Authenticator.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying a, which requests made with the
+// context use in place of the client's authenticator.
+func NewContext(ctx context.Context, a Authenticator) context.Context {
+	return context.WithValue(ctx, contextKey{}, a)
+}
+
+// FromContext returns the Authenticator carried by ctx, if any.
+func FromContext(ctx context.Context) (Authenticator, bool) {
+	a, ok := ctx.Value(contextKey{}).(Authenticator)
+	return a, ok && a != nil
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 	"testing"
 )
@@ -65,3 +66,15 @@ func TestBearerTokenAuthenticator_Apply(t *testing.T) {
 		t.Errorf("Expected Authorization header to be %q, got %q", expectedAuth, got)
 	}
 }
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("Expected no authenticator in a plain context")
+	}
+
+	want := NewAPIKeyAuthenticator("tenant-key")
+	got, ok := FromContext(NewContext(context.Background(), want))
+	if !ok || got != want {
+		t.Errorf("Expected the context's authenticator, got %v (ok=%v)", got, ok)
+	}
+}
@@ -2,6 +2,7 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 )
@@ -19,6 +20,9 @@ type APIError struct {
 	Message    string      `json:"message"`
 	Details    interface{} `json:"details,omitempty"`
 	RequestID  string      `json:"request_id,omitempty"`
+
+	// Err is a sentinel describing a recognized server condition, if any
+	Err error `json:"-"`
 }
 
 // Error implements the error interface
@@ -55,6 +59,11 @@ func (e *APIError) HTTPStatusCode() int {
 	return e.StatusCode
 }
 
+// Unwrap returns the sentinel error for a recognized server condition
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
 // RequestError represents a client request error
 type RequestError struct {
 	Message    string
@@ -128,3 +137,9 @@ var (
 	ErrCanceled       = &RequestError{Message: "request canceled"}
 	ErrStreamClosed   = &StreamError{Message: "stream closed"}
 )
+
+// Sentinel errors for server conditions recognized in error responses
+var (
+	ErrNoEmbeddingModelLoaded = errors.New("no embedding model loaded")
+	ErrNoModelLoaded          = errors.New("no model loaded")
+)
@@ -7,9 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pixelsquared/go-tabbyapi/internal/auth"
 	"github.com/pixelsquared/go-tabbyapi/internal/errors"
@@ -24,6 +27,22 @@ type Client struct {
 	httpClient  *http.Client
 	auth        auth.Authenticator
 	contentType string
+	retryPolicy RetryPolicy
+	beforeRetry func(attempt int, resp *http.Response, err error)
+	baseCtx     context.Context
+
+	// streamRetryPolicy replaces retryPolicy in DoRaw when non-nil; see
+	// WithStreamConnectRetry
+	streamRetryPolicy RetryPolicy
+
+	// slots limits concurrent requests when non-nil; see WithMaxConcurrent
+	slots chan struct{}
+
+	// flights coalesces identical GETs when non-nil; see WithDeduplication
+	flights *flightGroup
+
+	// onResponse is called after each attempt; see WithResponseHook
+	onResponse func(method string, resp *http.Response, err error, elapsed time.Duration)
 }
 
 // New creates a new REST client.
@@ -41,6 +60,38 @@ func New(baseURL string, options ...ClientOption) *Client {
 	return client
 }
 
+// WithBaseContext ties every request to ctx. When ctx is canceled, in-flight
+// requests are aborted and new requests fail immediately, while each
+// request's own context (and its deadline) continues to apply. Values not
+// found in a request's context are looked up in ctx.
+func WithBaseContext(ctx context.Context) ClientOption {
+	return func(c *Client) {
+		c.baseCtx = ctx
+	}
+}
+
+// WithMaxConcurrent limits the client to n requests in flight at once.
+// Further requests block until a slot frees or their context is done. A
+// streaming request holds its slot until the response body is closed. n <= 0
+// means no limit.
+func WithMaxConcurrent(n int) ClientOption {
+	return func(c *Client) {
+		c.slots = nil
+		if n > 0 {
+			c.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithResponseHook sets a function called after every HTTP attempt,
+// including retries, with the response or transport error and the time
+// until the response headers arrived. fn must not read or close the body.
+func WithResponseHook(fn func(method string, resp *http.Response, err error, elapsed time.Duration)) ClientOption {
+	return func(c *Client) {
+		c.onResponse = fn
+	}
+}
+
 // WithHTTPClient sets the HTTP client for the REST client.
 func WithHTTPClient(httpClient *http.Client) ClientOption {
 	return func(c *Client) {
@@ -87,58 +138,221 @@ func (c *Client) Delete(ctx context.Context, endpoint string, params url.Values)
 }
 
 // Do sends an HTTP request and returns the response.
+// Failed attempts are retried according to the client's retry policy.
+// With WithDeduplication, concurrent identical GETs share one request,
+// unless the context carries its own authenticator or retry limit.
 func (c *Client) Do(ctx context.Context, method, url string, body, result interface{}) error {
-	req, err := c.createRequest(ctx, method, url, body)
-	if err != nil {
-		return &errors.RequestError{
-			Message: "failed to create request",
-			Err:     err,
-		}
+	if c.flights != nil && method == http.MethodGet && !hasContextAuth(ctx) && !hasRetryOverride(ctx) {
+		return c.doShared(ctx, method, url, body, result)
 	}
+	return c.do(ctx, method, url, body, result)
+}
 
-	resp, err := c.httpClient.Do(req)
+// do sends a single request for Do, with retries.
+func (c *Client) do(ctx context.Context, method, url string, body, result interface{}) error {
+	ctx, stop, err := c.begin(ctx)
 	if err != nil {
-		return &errors.RequestError{
-			Message: "failed to execute request",
-			Err:     err,
-		}
+		return err
 	}
-	defer resp.Body.Close()
+	defer stop()
+
+	for attempts := 0; ; attempts++ {
+		// The request is rebuilt on each attempt so the body is re-read from the start
+		req, err := c.createRequest(ctx, method, url, body)
+		if err != nil {
+			return &errors.RequestError{
+				Message: "failed to create request",
+				Err:     err,
+			}
+		}
 
-	return c.handleResponse(resp, result)
+		resp, err := c.send(req)
+		if shouldRetry(ctx, c.retryPolicy, attempts, method, resp, err) {
+			if waitErr := c.waitRetry(ctx, c.retryPolicy, attempts, resp, err); waitErr != nil {
+				return &errors.RequestError{
+					Message: "request canceled while waiting to retry",
+					Err:     waitErr,
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return &errors.RequestError{
+				Message: "failed to execute request",
+				Err:     err,
+			}
+		}
+		defer resp.Body.Close()
+
+		return c.handleResponse(resp, result)
+	}
 }
 
 // DoRaw sends an HTTP request and returns the raw response for streaming.
+//
+// The request asks for an uncompressed, uncached event stream. Setting
+// Accept-Encoding explicitly stops the transport from transparently gzipping
+// the response, whose decompressor can hold back small chunks, so each SSE
+// event is readable as soon as it arrives.
+//
+// Establishing the connection is retried as in Do, using the stream connect
+// policy if one is set (see WithStreamConnectRetry) and the general retry
+// policy otherwise. A 5xx response is retried whenever the policy's
+// ShouldRetry allows it, whatever the method, since the server has not
+// started the stream. Once a successful response is returned, failures while
+// reading the stream are the caller's to handle.
+//
+// Any 2xx status is returned as a success. A 204 No Content response has an
+// empty body; stream readers should treat it as a stream that has already
+// ended rather than parsing it.
 func (c *Client) DoRaw(ctx context.Context, method, url string, body interface{}) (*http.Response, error) {
-	req, err := c.createRequest(ctx, method, url, body)
+	ctx, stop, err := c.begin(ctx)
 	if err != nil {
-		return nil, &errors.RequestError{
-			Message: "failed to create request",
-			Err:     err,
+		return nil, err
+	}
+	policy := c.streamPolicy()
+
+	for attempts := 0; ; attempts++ {
+		// The request is rebuilt on each attempt so the body is re-read from the start
+		req, err := c.createRequest(ctx, method, url, body)
+		if err != nil {
+			stop()
+			return nil, &errors.RequestError{
+				Message: "failed to create request",
+				Err:     err,
+			}
 		}
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Accept-Encoding", "identity")
+		req.Header.Set("Cache-Control", "no-cache")
+
+		resp, err := c.send(req)
+		if shouldRetryConnect(ctx, policy, attempts, method, resp, err) {
+			if waitErr := c.waitRetry(ctx, policy, attempts, resp, err); waitErr != nil {
+				stop()
+				return nil, &errors.RequestError{
+					Message: "request canceled while waiting to retry",
+					Err:     waitErr,
+				}
+			}
+			continue
+		}
+		if err != nil {
+			stop()
+			return nil, &errors.RequestError{
+				Message: "failed to execute request",
+				Err:     err,
+			}
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			defer stop()
+			defer resp.Body.Close()
+			return nil, c.parseErrorResponse(resp)
+		}
+
+		// The merged context and the request slot must outlive DoRaw, so they
+		// are released with the body
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: stop}
+		return resp, nil
 	}
+}
 
+// send performs a single HTTP attempt, reporting it to the response hook.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.onResponse == nil {
+		return c.httpClient.Do(req)
+	}
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, &errors.RequestError{
-			Message: "failed to execute request",
-			Err:     err,
+	c.onResponse(req.Method, resp, err, time.Since(start))
+	return resp, err
+}
+
+// begin prepares a request: it derives the request context and waits for a
+// free slot when concurrency is limited. stop must be called once the
+// request, including reading its response body, has finished; it is safe to
+// call more than once.
+func (c *Client) begin(ctx context.Context) (context.Context, func(), error) {
+	ctx, stopContext := c.requestContext(ctx)
+	if c.slots == nil {
+		return ctx, stopContext, nil
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		stopContext()
+		return nil, nil, &errors.RequestError{
+			Message: "request canceled while waiting for a free slot",
+			Err:     ctx.Err(),
 		}
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		defer resp.Body.Close()
-		return nil, c.parseErrorResponse(resp)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			<-c.slots
+			stopContext()
+		})
+	}, nil
+}
+
+// requestContext derives a context from ctx that is also canceled when the
+// client's base context is done, and that falls back to the base context's
+// values. stop must be called once the request, including reading its
+// response body, has finished.
+func (c *Client) requestContext(ctx context.Context) (context.Context, func()) {
+	if c.baseCtx == nil {
+		return ctx, func() {}
+	}
+
+	merged, cancel := context.WithCancelCause(baseValues{Context: ctx, base: c.baseCtx})
+	if c.baseCtx.Err() != nil {
+		cancel(context.Cause(c.baseCtx))
+	}
+	stopAfter := context.AfterFunc(c.baseCtx, func() {
+		cancel(context.Cause(c.baseCtx))
+	})
+	return merged, func() {
+		stopAfter()
+		cancel(nil)
+	}
+}
+
+// baseValues is a request context whose values fall back to those of base.
+type baseValues struct {
+	context.Context
+	base context.Context
+}
+
+func (c baseValues) Value(key any) any {
+	if v := c.Context.Value(key); v != nil {
+		return v
 	}
+	return c.base.Value(key)
+}
 
-	return resp, nil
+// releaseBody runs release after the wrapped body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
 }
 
 // createRequest creates a new HTTP request.
 func (c *Client) createRequest(ctx context.Context, method, url string, body interface{}) (*http.Request, error) {
 	var bodyReader io.Reader
 
-	if body != nil {
+	if raw, ok := body.(json.RawMessage); ok {
+		// Pre-encoded bodies are sent verbatim
+		bodyReader = bytes.NewReader(raw)
+	} else if body != nil {
 		data, err := json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
@@ -156,7 +370,10 @@ func (c *Client) createRequest(ctx context.Context, method, url string, body int
 	}
 	req.Header.Set("Accept", "application/json")
 
-	if c.auth != nil {
+	// An authenticator carried by the context replaces the client's own
+	if override, ok := auth.FromContext(ctx); ok {
+		override.Apply(req)
+	} else if c.auth != nil {
 		c.auth.Apply(req)
 	}
 
@@ -173,6 +390,15 @@ func (c *Client) handleResponse(resp *http.Response, result interface{}) error {
 		return nil
 	}
 
+	// A misconfigured server or proxy may stream anyway; the data: framing
+	// would otherwise surface as an opaque JSON syntax error
+	if isEventStream(resp) {
+		return &errors.RequestError{
+			Message:    "server sent an event stream in response to a non-streaming request; use the streaming method or check that the server honors stream: false",
+			StatusCode: resp.StatusCode,
+		}
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return &errors.RequestError{
@@ -198,6 +424,12 @@ func (c *Client) handleResponse(resp *http.Response, result interface{}) error {
 	return nil
 }
 
+// isEventStream reports whether resp is a server-sent event stream.
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
+
 // parseErrorResponse extracts error information from an error response.
 func (c *Client) parseErrorResponse(resp *http.Response) error {
 	body, err := io.ReadAll(resp.Body)
@@ -220,13 +452,32 @@ func (c *Client) parseErrorResponse(resp *http.Response) error {
 		return &errors.APIError{
 			StatusCode: resp.StatusCode,
 			Message:    string(body),
+			Err:        detectCondition(body),
 		}
 	}
 
 	apiError.StatusCode = resp.StatusCode
+	apiError.Err = detectCondition(body)
 	return &apiError
 }
 
+// detectCondition maps well-known server error messages to sentinel errors.
+func detectCondition(body []byte) error {
+	msg := strings.ToLower(string(body))
+	switch {
+	case strings.Contains(msg, "no embedding model"),
+		strings.Contains(msg, "embedding model is not loaded"),
+		strings.Contains(msg, "embedding model not loaded"):
+		return errors.ErrNoEmbeddingModelLoaded
+	case strings.Contains(msg, "no models are currently loaded"),
+		strings.Contains(msg, "no model is currently loaded"),
+		strings.Contains(msg, "model is not loaded"),
+		strings.Contains(msg, "no model loaded"):
+		return errors.ErrNoModelLoaded
+	}
+	return nil
+}
+
 // buildURL constructs the full URL for a request.
 // BuildURL constructs the full URL for a request.
 func (c *Client) BuildURL(endpoint string, params url.Values) string {
@@ -3,11 +3,14 @@ package rest
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pixelsquared/go-tabbyapi/internal/auth"
 	"github.com/pixelsquared/go-tabbyapi/internal/errors"
@@ -155,6 +158,80 @@ func TestClient_Error(t *testing.T) {
 	}
 }
 
+func TestClient_EventStreamToNonStreamRequest(t *testing.T) {
+	// Create a test server that streams even though streaming wasn't requested
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		_, _ = w.Write([]byte("data: {\"choices\":[]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	// Create client
+	client := New(server.URL)
+
+	var result map[string]interface{}
+	err := client.Post(context.Background(), "/v1/completions", map[string]interface{}{"prompt": "hi", "stream": false}, &result)
+
+	var reqErr *errors.RequestError
+	if !stderrors.As(err, &reqErr) {
+		t.Fatalf("Expected *errors.RequestError, got %T: %v", err, err)
+	}
+	if !strings.Contains(reqErr.Message, "event stream") || reqErr.Err != nil {
+		t.Errorf("Expected a descriptive event stream error, got %v", reqErr)
+	}
+}
+
+func TestClient_ErrorResponse_NoEmbeddingModel(t *testing.T) {
+	// Create a test server that reports a missing embedding model
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"detail":"No embedding models are currently loaded."}`))
+	}))
+	defer server.Close()
+
+	// Create client
+	client := New(server.URL)
+
+	// Send POST request
+	err := client.Post(context.Background(), "/v1/embeddings", map[string]string{"input": "hi"}, nil)
+	if err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+
+	// Check that the sentinel is detected
+	if !stderrors.Is(err, errors.ErrNoEmbeddingModelLoaded) {
+		t.Errorf("Expected ErrNoEmbeddingModelLoaded, got %v", err)
+	}
+
+	apiError, ok := err.(*errors.APIError)
+	if !ok {
+		t.Fatalf("Expected *errors.APIError, got %T", err)
+	}
+	if apiError.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, apiError.StatusCode)
+	}
+}
+
+func TestClient_DetectsNoModelLoaded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"detail":"No models are currently loaded."}`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL)
+
+	err := client.Get(context.Background(), "/v1/models/current", nil, nil)
+	if !stderrors.Is(err, errors.ErrNoModelLoaded) {
+		t.Errorf("Expected ErrNoModelLoaded, got %v", err)
+	}
+	if stderrors.Is(err, errors.ErrNoEmbeddingModelLoaded) {
+		t.Error("Expected generation model condition not to match ErrNoEmbeddingModelLoaded")
+	}
+}
+
 func TestClient_WithAuth(t *testing.T) {
 	// Create a test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -196,6 +273,17 @@ func TestClient_DoRaw(t *testing.T) {
 			t.Errorf("Expected method %s, got %s", http.MethodGet, r.Method)
 		}
 
+		// Check that streaming headers are set
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("Expected Accept header to be text/event-stream, got %s", r.Header.Get("Accept"))
+		}
+		if r.Header.Get("Accept-Encoding") != "identity" {
+			t.Errorf("Expected Accept-Encoding header to be identity, got %s", r.Header.Get("Accept-Encoding"))
+		}
+		if r.Header.Get("Cache-Control") != "no-cache" {
+			t.Errorf("Expected Cache-Control header to be no-cache, got %s", r.Header.Get("Cache-Control"))
+		}
+
 		// Write response
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -233,3 +321,32 @@ func TestClient_DoRaw(t *testing.T) {
 		t.Errorf("Expected message 'success', got %s", result.Message)
 	}
 }
+
+func TestClient_BaseContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	t.Run("caller deadline still applies", func(t *testing.T) {
+		client := New(server.URL, WithBaseContext(context.Background()))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := client.Get(ctx, "/test", nil, nil)
+		if !stderrors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("base cancel aborts request", func(t *testing.T) {
+		base, cancelBase := context.WithCancel(context.Background())
+		client := New(server.URL, WithBaseContext(base))
+		time.AfterFunc(50*time.Millisecond, cancelBase)
+
+		err := client.Get(context.Background(), "/test", nil, nil)
+		if !stderrors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/pixelsquared/go-tabbyapi/internal/auth"
+	"github.com/pixelsquared/go-tabbyapi/internal/errors"
+)
+
+// WithDeduplication enables coalescing concurrent identical GET requests:
+// while one is in flight, further requests with the same URL and body wait
+// for it and share its result instead of reaching the server. Other methods
+// are never deduplicated, since they may not be idempotent.
+func WithDeduplication(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.flights = nil
+		if enabled {
+			c.flights = &flightGroup{calls: make(map[string]*flight)}
+		}
+	}
+}
+
+// flightGroup tracks in-flight shared requests by key.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is one shared request and the callers waiting for it.
+type flight struct {
+	done    chan struct{}
+	raw     json.RawMessage
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do runs fn once for all concurrent callers with the same key and returns
+// its result to each of them.
+//
+// fn runs on a context detached from any single caller's cancellation, so
+// one caller giving up does not fail the others; it is canceled only once
+// every waiting caller has returned.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) (json.RawMessage, error)) (json.RawMessage, error) {
+	g.mu.Lock()
+	f, ok := g.calls[key]
+	if ok {
+		f.waiters++
+	} else {
+		sharedCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = f
+
+		go func() {
+			f.raw, f.err = fn(sharedCtx)
+			g.mu.Lock()
+			g.forget(key, f)
+			g.mu.Unlock()
+			cancel()
+			close(f.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.raw, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Later callers start a fresh request rather than join this one
+			g.forget(key, f)
+			f.cancel()
+		}
+		g.mu.Unlock()
+		return nil, &errors.RequestError{
+			Message: "request canceled while waiting for a shared request",
+			Err:     ctx.Err(),
+		}
+	}
+}
+
+// forget removes f from the group if it is still the flight for key.
+// g.mu must be held.
+func (g *flightGroup) forget(key string, f *flight) {
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+}
+
+// doShared performs a GET through the flight group and decodes the shared
+// response body into result.
+func (c *Client) doShared(ctx context.Context, method, url string, body, result interface{}) error {
+	key, err := flightKey(method, url, body)
+	if err != nil {
+		return &errors.RequestError{Message: "failed to create request", Err: err}
+	}
+
+	raw, err := c.flights.do(ctx, key, func(ctx context.Context) (json.RawMessage, error) {
+		var raw json.RawMessage
+		err := c.do(ctx, method, url, body, &raw)
+		return raw, err
+	})
+	if err != nil || result == nil || len(raw) == 0 {
+		return err
+	}
+
+	if err := json.Unmarshal(raw, result); err != nil {
+		return &errors.RequestError{
+			Message:    "failed to unmarshal response body",
+			StatusCode: http.StatusOK,
+			Err:        err,
+		}
+	}
+	return nil
+}
+
+// hasContextAuth reports whether ctx overrides the client's authenticator.
+// Such requests are never shared, since callers with different credentials
+// may be entitled to different responses.
+func hasContextAuth(ctx context.Context) bool {
+	_, ok := auth.FromContext(ctx)
+	return ok
+}
+
+// flightKey identifies a request by method, URL, and a hash of its body.
+func flightKey(method, url string, body interface{}) (string, error) {
+	var data []byte
+	if raw, ok := body.(json.RawMessage); ok {
+		data = raw
+	} else if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256(data)
+	return method + " " + url + " " + hex.EncodeToString(sum[:]), nil
+}
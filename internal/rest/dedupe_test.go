@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowServer answers every request after delay and counts the calls.
+func slowServer(t *testing.T, delay time.Duration, calls *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"success"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_Deduplication_CoalescesGETs(t *testing.T) {
+	var calls int32
+	server := slowServer(t, 50*time.Millisecond, &calls)
+	client := New(server.URL, WithDeduplication(true))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result testResponse
+			if err := client.Get(context.Background(), "/test", nil, &result); err != nil {
+				t.Errorf("Get returned an error: %v", err)
+				return
+			}
+			if result.Message != "success" {
+				t.Errorf("Expected message 'success', got %q", result.Message)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls)
+	}
+}
+
+func TestClient_Deduplication_SkipsOtherMethods(t *testing.T) {
+	var calls int32
+	server := slowServer(t, 20*time.Millisecond, &calls)
+	client := New(server.URL, WithDeduplication(true))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Post(context.Background(), "/test", map[string]string{}, nil); err != nil {
+				t.Errorf("Post returned an error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 5 {
+		t.Errorf("Expected every POST to reach the server, got %d calls", calls)
+	}
+}
+
+func TestClient_Deduplication_SkipsRetryOverride(t *testing.T) {
+	var calls int32
+	server := slowServer(t, 20*time.Millisecond, &calls)
+	client := New(server.URL, WithDeduplication(true))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := ContextWithMaxRetries(context.Background(), 0)
+			if err := client.Get(ctx, "/test", nil, nil); err != nil {
+				t.Errorf("Get returned an error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 5 {
+		t.Errorf("Expected every GET with a retry override to reach the server, got %d calls", calls)
+	}
+}
+
+func TestClient_Deduplication_CallerCancelDoesNotFailOthers(t *testing.T) {
+	var calls int32
+	server := slowServer(t, 50*time.Millisecond, &calls)
+	client := New(server.URL, WithDeduplication(true))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- client.Get(ctx, "/test", nil, nil)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	var result testResponse
+	if err := client.Get(context.Background(), "/test", nil, &result); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	if err := <-errs; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the canceled caller to see context.DeadlineExceeded, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls)
+	}
+}
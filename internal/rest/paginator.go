@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"context"
+	"net/url"
+)
+
+// CursorParam is the query parameter used to request the page after a cursor.
+const CursorParam = "cursor"
+
+// Page is a single page of a list response. Next holds the cursor for the
+// following page and is empty on the last (or only) page.
+type Page[T any] struct {
+	Data []T    `json:"data"`
+	Next string `json:"next,omitempty"`
+}
+
+// Paginator fetches every page of a list endpoint by following the next
+// cursor until it is exhausted. Endpoints that do not paginate return a
+// single page without a cursor, which is handled as the only page.
+type Paginator[T any] struct {
+	client   *Client
+	endpoint string
+	params   url.Values
+}
+
+// NewPaginator creates a Paginator for the given list endpoint. params are
+// sent with every page request.
+func NewPaginator[T any](client *Client, endpoint string, params url.Values) *Paginator[T] {
+	return &Paginator[T]{client: client, endpoint: endpoint, params: params}
+}
+
+// All fetches every page and returns the concatenated items. It stops if the
+// server repeats a cursor, so a misbehaving endpoint cannot loop forever.
+func (p *Paginator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	seen := make(map[string]bool)
+	cursor := ""
+
+	for {
+		params := url.Values{}
+		for k, v := range p.params {
+			params[k] = v
+		}
+		if cursor != "" {
+			params.Set(CursorParam, cursor)
+		}
+
+		var page Page[T]
+		if err := p.client.Get(ctx, p.endpoint, params, &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Data...)
+
+		if page.Next == "" || seen[page.Next] {
+			return items, nil
+		}
+		seen[page.Next] = true
+		cursor = page.Next
+	}
+}
@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPaginator_All_FollowsCursor(t *testing.T) {
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursor := r.URL.Query().Get(CursorParam)
+		cursors = append(cursors, cursor)
+
+		page := Page[string]{Data: []string{"a", "b"}, Next: "page2"}
+		if cursor == "page2" {
+			page = Page[string]{Data: []string{"c"}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	items, err := NewPaginator[string](New(server.URL), "/items", nil).All(context.Background())
+	if err != nil {
+		t.Fatalf("All returned an error: %v", err)
+	}
+	if len(items) != 3 || items[0] != "a" || items[2] != "c" {
+		t.Errorf("Expected [a b c], got %v", items)
+	}
+	if len(cursors) != 2 || cursors[0] != "" || cursors[1] != "page2" {
+		t.Errorf("Expected cursors [\"\" page2], got %q", cursors)
+	}
+}
+
+func TestPaginator_All_SinglePage(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":["only"]}`))
+	}))
+	defer server.Close()
+
+	items, err := NewPaginator[string](New(server.URL), "/items", nil).All(context.Background())
+	if err != nil {
+		t.Fatalf("All returned an error: %v", err)
+	}
+	if len(items) != 1 || items[0] != "only" {
+		t.Errorf("Expected [only], got %v", items)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy decides whether and when failed requests are retried.
+type RetryPolicy interface {
+	// ShouldRetry reports whether a request should be retried.
+	ShouldRetry(resp *http.Response, err error) bool
+
+	// RetryDelay returns the delay before the given retry attempt (starting at 1).
+	RetryDelay(attempts int) time.Duration
+
+	// MaxRetries returns the maximum number of retries.
+	MaxRetries() int
+}
+
+// MethodRetryPolicy is an optional extension of RetryPolicy that also
+// receives the HTTP method, so non-idempotent requests can be treated
+// differently. It is used in place of ShouldRetry when implemented.
+type MethodRetryPolicy interface {
+	ShouldRetryMethod(method string, resp *http.Response, err error) bool
+}
+
+// ResponseDelayPolicy is an optional extension of RetryPolicy whose delay
+// depends on the failed attempt. It is used in place of RetryDelay when
+// implemented.
+type ResponseDelayPolicy interface {
+	RetryDelayFor(ctx context.Context, attempts int, resp *http.Response, err error) time.Duration
+}
+
+// WithRetryPolicy sets the retry policy for the REST client.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithStreamConnectRetry sets a retry policy used by DoRaw in place of the
+// general retry policy, so establishing a stream can be retried differently
+// from other requests. A nil policy makes DoRaw use the general policy.
+func WithStreamConnectRetry(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.streamRetryPolicy = policy
+	}
+}
+
+// WithBeforeRetry sets a function called just before the client sleeps ahead
+// of each retry. attempt is the retry about to be made, starting at 1; resp
+// and err are the outcome of the failed attempt, and resp's body is discarded
+// once fn returns.
+func WithBeforeRetry(fn func(attempt int, resp *http.Response, err error)) ClientOption {
+	return func(c *Client) {
+		c.beforeRetry = fn
+	}
+}
+
+// maxRetriesKey is the context key for a per-request retry limit.
+type maxRetriesKey struct{}
+
+// ContextWithMaxRetries returns a copy of ctx whose requests are retried at
+// most n times, overriding the policy's MaxRetries. n <= 0 disables retries.
+func ContextWithMaxRetries(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxRetriesKey{}, max(n, 0))
+}
+
+// maxRetries returns the retry limit for requests made with ctx.
+func maxRetries(ctx context.Context, policy RetryPolicy) int {
+	if n, ok := ctx.Value(maxRetriesKey{}).(int); ok {
+		return n
+	}
+	return policy.MaxRetries()
+}
+
+// hasRetryOverride reports whether ctx sets its own retry limit with
+// ContextWithMaxRetries. Such requests are never shared, since a caller that
+// disabled retries should not wait on another caller's retries, nor the
+// reverse.
+func hasRetryOverride(ctx context.Context) bool {
+	_, ok := ctx.Value(maxRetriesKey{}).(int)
+	return ok
+}
+
+// shouldRetry reports whether policy retries the attempt that produced
+// resp/err. attempts is the number of retries already made.
+func shouldRetry(ctx context.Context, policy RetryPolicy, attempts int, method string, resp *http.Response, err error) bool {
+	if policy == nil || attempts >= maxRetries(ctx, policy) {
+		return false
+	}
+	if err == nil && resp != nil && resp.StatusCode < 400 {
+		return false
+	}
+	if p, ok := policy.(MethodRetryPolicy); ok {
+		return p.ShouldRetryMethod(method, resp, err)
+	}
+	return policy.ShouldRetry(resp, err)
+}
+
+// shouldRetryConnect is shouldRetry for establishing a stream. A 5xx
+// response means the server has not started generating, so it is retryable
+// whenever policy.ShouldRetry allows it, even for a POST that a
+// MethodRetryPolicy would not retry.
+func shouldRetryConnect(ctx context.Context, policy RetryPolicy, attempts int, method string, resp *http.Response, err error) bool {
+	if policy != nil && err == nil && resp != nil && resp.StatusCode >= 500 {
+		return attempts < maxRetries(ctx, policy) && policy.ShouldRetry(resp, err)
+	}
+	return shouldRetry(ctx, policy, attempts, method, resp, err)
+}
+
+// streamPolicy returns the retry policy for establishing streams.
+func (c *Client) streamPolicy() RetryPolicy {
+	if c.streamRetryPolicy != nil {
+		return c.streamRetryPolicy
+	}
+	return c.retryPolicy
+}
+
+// waitRetry reports the failed attempt to the before-retry hook, discards
+// resp and sleeps before the next attempt, returning early with the context
+// error if ctx is done.
+func (c *Client) waitRetry(ctx context.Context, policy RetryPolicy, attempts int, resp *http.Response, err error) error {
+	if c.beforeRetry != nil {
+		c.beforeRetry(attempts+1, resp, err)
+	}
+	var delay time.Duration
+	if p, ok := policy.(ResponseDelayPolicy); ok {
+		delay = p.RetryDelayFor(ctx, attempts+1, resp, err)
+	} else {
+		delay = policy.RetryDelay(attempts + 1)
+	}
+	if resp != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,230 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRetryPolicy retries any error or 5xx response with no delay.
+type testRetryPolicy struct {
+	maxRetries int
+}
+
+func (p *testRetryPolicy) ShouldRetry(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= 500
+}
+
+func (p *testRetryPolicy) RetryDelay(attempts int) time.Duration { return time.Millisecond }
+
+func (p *testRetryPolicy) MaxRetries() int { return p.maxRetries }
+
+// getOnlyRetryPolicy only retries GET requests.
+type getOnlyRetryPolicy struct {
+	testRetryPolicy
+}
+
+func (p *getOnlyRetryPolicy) ShouldRetryMethod(method string, resp *http.Response, err error) bool {
+	return method == http.MethodGet && p.ShouldRetry(resp, err)
+}
+
+// flakyServer fails the first n requests with a 503 and records each request body.
+func flakyServer(t *testing.T, failures int32, calls *int32, bodies *[]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		if bodies != nil {
+			data, _ := io.ReadAll(r.Body)
+			*bodies = append(*bodies, string(data))
+		}
+		if n <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"success"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClient_Retry_SucceedsAfterFailures(t *testing.T) {
+	var calls int32
+	var bodies []string
+	server := flakyServer(t, 2, &calls, &bodies)
+
+	client := New(server.URL, WithRetryPolicy(&testRetryPolicy{maxRetries: 3}))
+
+	var result testResponse
+	err := client.Post(context.Background(), "/test", map[string]string{"key": "value"}, &result)
+	if err != nil {
+		t.Fatalf("Post returned an error: %v", err)
+	}
+	if result.Message != "success" {
+		t.Errorf("Expected message 'success', got %s", result.Message)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+
+	// Every attempt should carry the full request body
+	for i, body := range bodies {
+		if body != `{"key":"value"}` {
+			t.Errorf("Attempt %d: expected full body, got %q", i+1, body)
+		}
+	}
+}
+
+func TestClient_Retry_StopsAtMaxRetries(t *testing.T) {
+	var calls int32
+	server := flakyServer(t, 10, &calls, nil)
+
+	client := New(server.URL, WithRetryPolicy(&testRetryPolicy{maxRetries: 2}))
+
+	err := client.Get(context.Background(), "/test", nil, nil)
+	if err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls (1 + 2 retries), got %d", calls)
+	}
+}
+
+func TestClient_Retry_CallsBeforeRetry(t *testing.T) {
+	var calls int32
+	server := flakyServer(t, 2, &calls, nil)
+
+	var attempts []int
+	client := New(server.URL,
+		WithRetryPolicy(&testRetryPolicy{maxRetries: 3}),
+		WithBeforeRetry(func(attempt int, resp *http.Response, err error) {
+			if err != nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("Attempt %d: expected the failed 503 response, got %v, %v", attempt, resp, err)
+			}
+			attempts = append(attempts, attempt)
+		}),
+	)
+
+	if err := client.Get(context.Background(), "/test", nil, nil); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Errorf("Expected the hook to see attempts [1 2], got %v", attempts)
+	}
+}
+
+func TestClient_Retry_UsesMethodRetryPolicy(t *testing.T) {
+	var calls int32
+	server := flakyServer(t, 1, &calls, nil)
+
+	client := New(server.URL, WithRetryPolicy(&getOnlyRetryPolicy{testRetryPolicy{maxRetries: 3}}))
+
+	if err := client.Post(context.Background(), "/test", nil, nil); err == nil {
+		t.Error("Expected POST to fail without retrying")
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 POST call, got %d", calls)
+	}
+
+	var getCalls int32
+	getServer := flakyServer(t, 1, &getCalls, nil)
+	client = New(getServer.URL, WithRetryPolicy(&getOnlyRetryPolicy{testRetryPolicy{maxRetries: 3}}))
+	if err := client.Get(context.Background(), "/test", nil, nil); err != nil {
+		t.Errorf("Expected GET to succeed after retry, got %v", err)
+	}
+	if getCalls != 2 {
+		t.Errorf("Expected 2 GET calls, got %d", getCalls)
+	}
+}
+
+func TestClient_NoRetryPolicy(t *testing.T) {
+	var calls int32
+	server := flakyServer(t, 1, &calls, nil)
+
+	client := New(server.URL)
+	if err := client.Get(context.Background(), "/test", nil, nil); err == nil {
+		t.Error("Expected an error without a retry policy")
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestClient_DoRaw_RetriesConnect(t *testing.T) {
+	var calls int32
+	var bodies []string
+	server := flakyServer(t, 1, &calls, &bodies)
+
+	client := New(server.URL, WithRetryPolicy(&testRetryPolicy{maxRetries: 3}))
+
+	resp, err := client.DoRaw(context.Background(), http.MethodPost, server.URL+"/test", map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatalf("DoRaw returned an error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if string(data) != `{"message":"success"}` {
+		t.Errorf("Expected success body, got %q", data)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+	for i, body := range bodies {
+		if body != `{"key":"value"}` {
+			t.Errorf("Attempt %d: expected full body, got %q", i+1, body)
+		}
+	}
+}
+
+func TestClient_StreamConnectRetry_OnlyAppliesToDoRaw(t *testing.T) {
+	var calls int32
+	server := flakyServer(t, 1, &calls, nil)
+
+	client := New(server.URL, WithStreamConnectRetry(&testRetryPolicy{maxRetries: 3}))
+
+	// Without a general policy, Do is not retried
+	if err := client.Post(context.Background(), "/test", map[string]string{}, nil); err == nil {
+		t.Fatal("Expected an error from Post, got nil")
+	}
+	if calls != 1 {
+		t.Fatalf("Expected 1 call for Post, got %d", calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	resp, err := client.DoRaw(context.Background(), http.MethodPost, server.URL+"/test", map[string]string{})
+	if err != nil {
+		t.Fatalf("DoRaw returned an error: %v", err)
+	}
+	resp.Body.Close()
+	if calls != 2 {
+		t.Errorf("Expected 2 calls for DoRaw, got %d", calls)
+	}
+}
+
+func TestClient_ResponseHook_SeesEveryAttempt(t *testing.T) {
+	var calls int32
+	server := flakyServer(t, 1, &calls, nil)
+
+	var statuses []int
+	client := New(server.URL,
+		WithRetryPolicy(&testRetryPolicy{maxRetries: 2}),
+		WithResponseHook(func(method string, resp *http.Response, err error, elapsed time.Duration) {
+			if method != http.MethodGet || err != nil {
+				t.Errorf("Expected a successful GET attempt, got %s, %v", method, err)
+				return
+			}
+			statuses = append(statuses, resp.StatusCode)
+		}),
+	)
+
+	if err := client.Get(context.Background(), "/test", nil, nil); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	if len(statuses) != 2 || statuses[0] != http.StatusServiceUnavailable || statuses[1] != http.StatusOK {
+		t.Errorf("Expected statuses [503 200], got %v", statuses)
+	}
+}
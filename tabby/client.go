@@ -11,11 +11,16 @@ package tabby
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -60,8 +65,23 @@ type Client interface {
 	// Auth returns the AuthService for managing authentication permissions.
 	Auth() AuthService
 
+	// Convenience methods
+
+	// Generate runs a non-streaming chat completion and returns the text of
+	// the first choice's message, for callers that only want the reply.
+	Generate(ctx context.Context, req *ChatCompletionRequest) (string, error)
+
+	// GenerateStream runs a streaming chat completion, calling onDelta with
+	// each piece of text as it arrives, and returns the complete reply. A
+	// non-nil error from onDelta stops the stream and is returned.
+	GenerateStream(ctx context.Context, req *ChatCompletionRequest, onDelta func(delta string) error) (string, error)
+
 	// Close releases resources used by the client.
 	// Always call this method when you're done using the client.
+	//
+	// Close cancels any in-flight requests and open streams, which fail with
+	// an error wrapping context.Canceled. Requests made after Close fail the
+	// same way.
 	Close() error
 
 	// Client configuration options
@@ -104,6 +124,26 @@ type CompletionsService interface {
 	// The returned CompletionStream must be closed when no longer needed to release resources.
 	// Use req.Stream = true when using this method.
 	CreateStream(ctx context.Context, req *CompletionRequest) (CompletionStream, error)
+
+	// CreateRaw generates a completion from a pre-encoded JSON request body.
+	//
+	// The body is sent verbatim, bypassing CompletionRequest entirely, which
+	// avoids a marshal/unmarshal round trip for requests that are already JSON
+	// (for example, loaded from a config file). Authentication is applied as
+	// for Create. The stream flag is not forced, so the body should not
+	// request streaming.
+	CreateRaw(ctx context.Context, body json.RawMessage) (*CompletionResponse, error)
+
+	// CreateTimed generates a completion like Create and also returns the
+	// wall-clock duration of the call, including any retries. The duration is
+	// returned even when the request fails.
+	CreateTimed(ctx context.Context, req *CompletionRequest) (*CompletionResponse, time.Duration, error)
+
+	// Marshal returns the exact JSON body the client would send for req,
+	// after validation and stream flag forcing: as CreateStream would send
+	// it if req.Stream is set, and as Create would otherwise. It makes no
+	// request, and is meant for attaching the wire payload to bug reports.
+	Marshal(req *CompletionRequest) ([]byte, error)
 }
 
 // ChatService handles chat completion requests for multi-turn conversations
@@ -122,6 +162,70 @@ type ChatService interface {
 	// The returned ChatCompletionStream must be closed when no longer needed to release resources.
 	// Use req.Stream = true when using this method.
 	CreateStream(ctx context.Context, req *ChatCompletionRequest) (ChatCompletionStream, error)
+
+	// CreateRaw generates a chat completion from a pre-encoded JSON request body.
+	//
+	// The body is sent verbatim, bypassing ChatCompletionRequest entirely, which
+	// avoids a marshal/unmarshal round trip for requests that are already JSON.
+	// Authentication is applied as for Create. The stream flag is not forced,
+	// so the body should not request streaming.
+	CreateRaw(ctx context.Context, body json.RawMessage) (*ChatCompletionResponse, error)
+
+	// CreateTimed generates a chat completion like Create and also returns the
+	// wall-clock duration of the call, including any retries. The duration is
+	// returned even when the request fails.
+	CreateTimed(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, time.Duration, error)
+
+	// CreateStreamCallback streams a chat completion, calling onDelta with each
+	// content delta of the first choice as it arrives, and returns the fully
+	// assembled response once the stream ends.
+	//
+	// If onDelta returns an error, the stream is closed and that error is
+	// returned. Deltas for additional choices (when requesting several) are
+	// assembled into the response but not passed to onDelta. The response has
+	// no Usage unless the server sends it in the stream.
+	CreateStreamCallback(ctx context.Context, req *ChatCompletionRequest, onDelta func(delta string) error) (*ChatCompletionResponse, error)
+
+	// CreateStreamSchema streams a chat completion whose first choice must be
+	// JSON conforming to schema, validating the content as it arrives.
+	//
+	// The schema is sent as the request's JSONSchema unless one is already
+	// set. Each value's type is checked as soon as it starts and each value is
+	// checked in full once complete, using the keywords supported by
+	// ValidateAgainstSchema. At the first violation the stream is closed, to
+	// stop generation early, and Recv returns a *ValidationError naming the
+	// offending path. If the stream ends before the JSON is complete, Recv
+	// returns a *ValidationError instead of io.EOF.
+	CreateStreamSchema(ctx context.Context, req *ChatCompletionRequest, schema map[string]interface{}) (ChatCompletionStream, error)
+
+	// Continue resumes a chat completion that stopped at the token limit.
+	//
+	// The first choice of prevResp is appended to prevReq's messages as a
+	// partial assistant message and the request is sent again with
+	// AddGenerationPrompt set to false, so the model carries on from where it
+	// stopped. The returned response's first message holds the previous
+	// content followed by the new content, so Continue can be called again
+	// with the same prevReq while NeedsContinuation reports true. Neither
+	// prevReq nor prevResp is modified.
+	Continue(ctx context.Context, prevReq *ChatCompletionRequest, prevResp *ChatCompletionResponse) (*ChatCompletionResponse, error)
+
+	// CountPromptTokens returns the number of tokens req's messages take up
+	// once the server has rendered them with the model's prompt template.
+	//
+	// The request is sent as a real chat completion limited to a single
+	// generated token, and the count is read from Usage.PromptTokens, so it
+	// exactly matches what a full request would use but costs a prompt
+	// evaluation and a queue slot. For a cheaper estimate, encode the
+	// messages with TokensService.Encode, which skips generation but may
+	// not apply the prompt template the same way.
+	CountPromptTokens(ctx context.Context, req *ChatCompletionRequest) (int, error)
+
+	// Marshal returns the exact JSON body the client would send for req,
+	// after validation, stream flag forcing, and client-level settings such
+	// as WithMaxTokensField and WithMessageSanitizer: as CreateStream would
+	// send it if req.Stream is set, and as Create would otherwise. It makes
+	// no request, and is meant for attaching the wire payload to bug reports.
+	Marshal(req *ChatCompletionRequest) ([]byte, error)
 }
 
 // ModelsService handles model management operations including listing, loading,
@@ -133,19 +237,35 @@ type ModelsService interface {
 	// server, including their IDs and parameters.
 	List(ctx context.Context) (*ModelList, error)
 
+	// ListAll returns every available model, following pagination cursors
+	// until all pages have been fetched.
+	//
+	// Unlike List, which returns a single response, ListAll keeps requesting
+	// pages while the server reports a next cursor. Against servers that do
+	// not paginate it behaves like List.
+	ListAll(ctx context.Context) ([]ModelCard, error)
+
 	// Get returns the currently loaded model.
 	//
 	// This method provides information about the model that is currently loaded
 	// and ready for use in the TabbyAPI server.
 	Get(ctx context.Context) (*ModelCard, error)
 
+	// GetCurrent is like Get but returns (nil, nil) when no model is loaded,
+	// so callers only see an error for genuine failures.
+	GetCurrent(ctx context.Context) (*ModelCard, error)
+
 	// Load loads a model with the specified parameters.
 	//
 	// This method initiates loading of a model into memory with the provided configuration
 	// parameters. The operation is synchronous and returns when loading is complete.
 	//
 	// Parameters like max sequence length, RoPE scaling, cache size, and others can be
-	// specified in the ModelLoadRequest.
+	// specified in the ModelLoadRequest. An unsupported CacheMode is rejected
+	// with a *ValidationError before the request is sent.
+	//
+	// After loading, the current model is fetched and set as the response's
+	// Model, carrying the parameters the server actually applied.
 	Load(ctx context.Context, req *ModelLoadRequest) (*ModelLoadResponse, error)
 
 	// LoadStream loads a model and returns a stream of loading progress.
@@ -157,6 +277,31 @@ type ModelsService interface {
 	// The returned ModelLoadStream must be closed when no longer needed.
 	LoadStream(ctx context.Context, req *ModelLoadRequest) (ModelLoadStream, error)
 
+	// LoadWithProgress loads a model through LoadStream, calling onProgress
+	// with the percentage of modules loaded and the status of each update.
+	//
+	// The module count may be unknown in early updates; the percentage is 0
+	// until it arrives and is remembered across later updates. It returns
+	// the last update, with Model set as in Load, once the stream ends.
+	LoadWithProgress(ctx context.Context, req *ModelLoadRequest, onProgress func(pct float64, status string)) (*ModelLoadResponse, error)
+
+	// LoadIfNeeded loads a model only if it is not already the current model.
+	//
+	// This method checks the currently loaded model first. If it matches the
+	// requested model name, no load is performed and (nil, false, nil) is
+	// returned. Otherwise the model is loaded and (response, true, nil) is
+	// returned. This avoids costly redundant reloads in orchestration code.
+	// A lookup failing for any reason other than no model being loaded, as
+	// reported by GetCurrent, is returned without attempting the load.
+	LoadIfNeeded(ctx context.Context, req *ModelLoadRequest) (*ModelLoadResponse, bool, error)
+
+	// LoadPreset loads the model described by the named preset.
+	//
+	// Presets map a name to a fully-populated ModelLoadRequest; see
+	// RegisterModelPreset and the built-in Preset constants. An unknown
+	// name is rejected with a *ValidationError before any request is sent.
+	LoadPreset(ctx context.Context, presetName string) (*ModelLoadResponse, error)
+
 	// Unload unloads the currently loaded model.
 	//
 	// This method releases memory and resources used by the currently loaded model.
@@ -167,21 +312,38 @@ type ModelsService interface {
 	//
 	// This method provides additional properties of the currently loaded model,
 	// such as context size, chat template, and default generation settings.
+	// Use ModelPropsResponse.ContextLength to read the context size.
+	//
+	// If the props omit the context size, GetProps makes a second request,
+	// to the current model endpoint as ModelsService.Get does, for the loaded
+	// model's MaxSeqLen as a fallback. An error from that request is returned.
 	GetProps(ctx context.Context) (*ModelPropsResponse, error)
 
+	// WaitForReady blocks until the loaded model is serving requests.
+	//
+	// After Load or LoadStream reports completion, the model may need a moment
+	// before it can serve. This method polls GetProps every interval until it
+	// succeeds, returning nil, or until ctx is done, returning the context error
+	// along with the last polling failure. An interval of zero or less polls
+	// every 500ms.
+	WaitForReady(ctx context.Context, interval time.Duration) error
+
 	// Download downloads a model from HuggingFace.
 	//
 	// This method downloads a model from HuggingFace Model Hub to the local
 	// TabbyAPI server. The model can then be loaded using the Load method.
 	//
 	// The DownloadRequest allows specifying the repository ID, revision,
-	// authentication token, and file filters.
+	// authentication token, and file filters. Empty filter patterns are
+	// rejected with a *ValidationError; use DownloadRequest.WithSafetensorsOnly
+	// for the common safetensors-only filter.
 	Download(ctx context.Context, req *DownloadRequest) (*DownloadResponse, error)
 
 	// ListDraft returns all available draft models.
 	//
 	// This method retrieves information about draft models available to the TabbyAPI
 	// server. Draft models are models that are still in development or testing.
+	// Each returned card has Kind set to ModelKindDraft.
 	ListDraft(ctx context.Context) (*ModelList, error)
 
 	// ListEmbedding returns all available embedding models.
@@ -203,6 +365,8 @@ type ModelsService interface {
 	// vector representations of text through the EmbeddingsService.
 	//
 	// The EmbeddingModelLoadRequest allows specifying the model name and device.
+	// The request is checked with EmbeddingModelLoadRequest.Validate first, so
+	// a missing name or unsupported device fails without contacting the server.
 	LoadEmbedding(ctx context.Context, req *EmbeddingModelLoadRequest) (*ModelLoadResponse, error)
 
 	// UnloadEmbedding unloads the current embedding model.
@@ -227,7 +391,42 @@ type EmbeddingsService interface {
 	//
 	// The embedding model must be loaded via ModelsService.LoadEmbedding
 	// before using this method, unless a default embedding model is configured.
+	// If no embedding model is loaded, the returned error matches
+	// ErrNoEmbeddingModelLoaded when checked with errors.Is.
+	//
+	// The request is checked with EmbeddingsRequest.Validate before it is
+	// sent; empty input yields a *ValidationError.
 	Create(ctx context.Context, req *EmbeddingsRequest) (*EmbeddingsResponse, error)
+
+	// CreateOne generates an embedding for a single text input.
+	//
+	// This is a convenience wrapper around Create for the common case of
+	// embedding one string. It returns the decoded vector of the first
+	// embedding in the response, or an error if none was returned.
+	CreateOne(ctx context.Context, text string) ([]float32, error)
+
+	// RankBySimilarity embeds query and corpus and returns the topK corpus
+	// entries most similar to the query by cosine similarity, best first.
+	//
+	// The corpus is embedded in batches, so it may be larger than the server
+	// accepts in a single request. topK <= 0 returns every entry, ranked. An
+	// empty corpus returns no results without contacting the server.
+	RankBySimilarity(ctx context.Context, query string, corpus []string, topK int) ([]ScoredText, error)
+
+	// CreateStream generates embeddings for a large input incrementally,
+	// yielding each vector in input order so callers can start indexing
+	// before the whole input has been embedded.
+	//
+	// TabbyAPI does not stream embeddings, so the input is split into
+	// batches, each sent with Create once the previous batch has been
+	// received. The first batch is sent before CreateStream returns. Each
+	// yielded EmbeddingObject has Index set to its position in the whole
+	// input. Closing the stream early skips the remaining batches.
+	CreateStream(ctx context.Context, req *EmbeddingsRequest) (Stream[*EmbeddingObject], error)
+
+	// Marshal returns the exact JSON body Create would send for req, after
+	// validation, without making a request.
+	Marshal(req *EmbeddingsRequest) ([]byte, error)
 }
 
 // LoraService handles Low-Rank Adaptation (LoRA) adapter management.
@@ -259,6 +458,14 @@ type LoraService interface {
 	// This method removes all active LoRA adapters, returning the model
 	// to its base behavior.
 	Unload(ctx context.Context) error
+
+	// Swap replaces the active LoRA adapters with the requested set.
+	//
+	// This method snapshots the currently active adapters, unloads them, and
+	// loads the adapters in the LoraLoadRequest. If the new load fails, the
+	// previously active adapters are reloaded before the error is returned,
+	// so the server is left in its original state whenever possible.
+	Swap(ctx context.Context, req *LoraLoadRequest) (*LoraLoadResponse, error)
 }
 
 // TokensService handles tokenization operations including encoding text to token IDs
@@ -280,6 +487,15 @@ type TokensService interface {
 	// corresponding text. This is useful for debugging or for processing
 	// model outputs at the token level.
 	Decode(ctx context.Context, req *TokenDecodeRequest) (*TokenDecodeResponse, error)
+
+	// Inspect encodes text and pairs each token ID with the piece of text it
+	// decodes to, to show how the model's tokenizer splits the text.
+	//
+	// Each token is decoded with its own request, so this is meant for
+	// debugging short texts rather than for production use. Pieces of a
+	// multi-byte character split across tokens may decode to replacement
+	// characters.
+	Inspect(ctx context.Context, text string) ([]TokenInfo, error)
 }
 
 // TemplatesService handles prompt template management for different model types.
@@ -318,6 +534,14 @@ type SamplingService interface {
 	// and creativity of text generation (temperature, top_p, top_k, etc.).
 	ListOverrides(ctx context.Context) (*SamplerOverrideListResponse, error)
 
+	// GetActiveOverride returns the currently active sampler overrides and the
+	// name of the selected preset.
+	//
+	// This is a convenience over ListOverrides for callers that only need the
+	// active state rather than the full preset list. The preset name is empty
+	// when no preset is selected.
+	GetActiveOverride(ctx context.Context) (map[string]interface{}, string, error)
+
 	// SwitchOverride changes the active sampler override.
 	//
 	// This method allows switching to a predefined preset or setting custom
@@ -369,30 +593,220 @@ func NewClient(options ...Option) Client {
 		baseURL:    "http://localhost:8080",
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 	}
-
 	for _, opt := range options {
 		opt(c)
 	}
 
+	parent := c.parentCtx
+	if parent == nil {
+		parent = context.Background()
+	}
+	c.baseCtx, c.cancelBase = context.WithCancel(parent)
+
+	if !c.customHTTPClient && (c.forceHTTP1 || c.disableKeepAlives) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if c.forceHTTP1 {
+			transport = http1Transport()
+		}
+		transport.DisableKeepAlives = c.disableKeepAlives
+		c.httpClient.Transport = transport
+	}
+	// The guard looks up the context length before every request
+	if c.contextGuard {
+		c.guardCache = newModelInfoCache(contextGuardCacheTTL)
+	}
+
 	return c
 }
 
+// Environment variables read by NewClientFromEnv.
+const (
+	// EnvEndpoint holds the base URL of the TabbyAPI server.
+	EnvEndpoint = "TABBY_API_ENDPOINT"
+
+	// EnvAPIKey holds the API key sent in the X-API-Key header.
+	EnvAPIKey = "TABBY_API_KEY"
+
+	// EnvAdminKey holds the admin key sent in the X-Admin-Key header.
+	EnvAdminKey = "TABBY_ADMIN_KEY"
+
+	// EnvTimeout holds the request timeout, either as a Go duration
+	// ("90s", "2m") or as a whole number of seconds ("90").
+	EnvTimeout = "TABBY_API_TIMEOUT"
+)
+
+// NewClientFromEnv creates a new TabbyAPI client configured from the standard
+// environment variables, then applies the provided options.
+//
+// TABBY_API_ENDPOINT, TABBY_API_KEY, TABBY_ADMIN_KEY, and TABBY_API_TIMEOUT are
+// read if set and non-empty; unset variables leave the NewClient defaults in
+// place, and an unparseable timeout is ignored. If both keys are set, both
+// headers are sent. Explicit options are applied after the environment, so they
+// always take precedence.
+func NewClientFromEnv(options ...Option) Client {
+	var envOptions []Option
+
+	if endpoint := os.Getenv(EnvEndpoint); endpoint != "" {
+		envOptions = append(envOptions, WithBaseURL(endpoint))
+	}
+	if key := os.Getenv(EnvAPIKey); key != "" {
+		envOptions = append(envOptions, WithAPIKey(key))
+	}
+	if key := os.Getenv(EnvAdminKey); key != "" {
+		envOptions = append(envOptions, WithAdminKey(key))
+	}
+	if timeout, ok := parseEnvTimeout(os.Getenv(EnvTimeout)); ok {
+		envOptions = append(envOptions, WithTimeout(timeout))
+	}
+
+	return NewClient(append(envOptions, options...)...)
+}
+
+// parseEnvTimeout parses a timeout given as a duration or a number of seconds.
+func parseEnvTimeout(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, true
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	return 0, false
+}
+
 // clientImpl is the concrete implementation of the Client interface.
 // It maintains the configuration and internal state for API requests.
 type clientImpl struct {
-	baseURL     string
-	httpClient  *http.Client
-	auth        Authenticator
-	retryPolicy RetryPolicy
-	restClient  *rest.Client
+	baseURL        string
+	httpClient     *http.Client
+	auth           Authenticator
+	retryPolicy    RetryPolicy
+	restClient     *rest.Client
+	restMu         sync.Mutex
+	maxTokensField MaxTokensField
+
+	// customHTTPClient is set when WithHTTPClient replaced the default client
+	customHTTPClient bool
+
+	// forceHTTP1 restricts the default client's transport to HTTP/1.1
+	forceHTTP1 bool
+
+	// disableKeepAlives closes the default transport's connections after each request
+	disableKeepAlives bool
+
+	// stream holds settings applied to completion and chat streams
+	stream streamConfig
+
+	// autoLoadEmbeddingModel is loaded on demand by EmbeddingsService.Create
+	autoLoadEmbeddingModel string
+
+	// sanitizeMessages enables SanitizeMessages on chat requests, dropping
+	// empty messages if dropEmptyMessages is also set
+	sanitizeMessages  bool
+	dropEmptyMessages bool
+
+	// enforceSystemFirst enables EnforceSystemFirst on chat requests, merging
+	// multiple system messages if mergeSystemMessages is set
+	enforceSystemFirst  bool
+	mergeSystemMessages bool
+
+	// redactedHeaders extends defaultRedactedHeaders
+	redactedHeaders []string
+
+	// endpoints overrides defaultEndpoints by logical name
+	endpoints map[string]string
+
+	// maxConcurrent limits requests in flight; zero means unlimited
+	maxConcurrent int
+
+	// metrics records requests into expvar; see WithExpvarMetrics
+	metrics *expvarMetrics
+
+	// deduplicate coalesces identical in-flight GETs; see WithRequestDeduplication
+	deduplicate bool
+
+	// unloadMethod is the HTTP method for unload endpoints; see WithUnloadMethod
+	unloadMethod string
+
+	// modelCache memoizes ModelsService.Get and GetProps; nil disables it
+	modelCache *modelInfoCache
+
+	// guardCache holds the context guard's own copy of the model props, kept
+	// apart from modelCache so enabling the guard does not cache Get and
+	// GetProps for the caller
+	guardCache *modelInfoCache
+
+	// streamRetryPolicy replaces retryPolicy when establishing streams
+	streamRetryPolicy RetryPolicy
+
+	// healthAwareRetry scales retry delays by server health; see WithHealthAwareRetry
+	healthAwareRetry bool
+
+	// beforeRetry is called ahead of each retry; see WithBeforeRetry
+	beforeRetry func(attempt int, resp *http.Response, err error)
+
+	// validateResponses checks response object types; see WithResponseValidation
+	validateResponses bool
+
+	// strictStream rejects a mismatched Stream flag instead of overriding it
+	strictStream bool
+
+	// contextGuard fits MaxTokens to the remaining context; see WithContextGuard
+	contextGuard bool
+
+	// notLoadedErrors maps not-loaded states to sentinels; see WithNotLoadedErrors
+	notLoadedErrors bool
+
+	// tokens throttles generation by token usage; nil means unlimited
+	tokens *tokenLimiter
+
+	// parentCtx is the context set by WithBaseContext, if any
+	parentCtx context.Context
+
+	// baseCtx is canceled by Close to abort in-flight requests
+	baseCtx    context.Context
+	cancelBase context.CancelFunc
 }
 
 // Close releases resources used by the client
 func (c *clientImpl) Close() error {
-	// No resources to release yet
+	c.cancelBase()
 	return nil
 }
 
+// ContextWithAuth returns a copy of ctx that makes requests use a in place
+// of the client's configured authentication, so one client can serve several
+// tenants without being cloned per key. a replaces the client's
+// authenticator entirely; wrap both in a MultiAuthenticator to send the
+// client's credentials as well. Requests carrying their own authenticator are
+// never coalesced by WithRequestDeduplication, and neither read nor fill the
+// model info caches of WithModelInfoCache and WithContextGuard.
+//
+// Example:
+//
+//	ctx = tabby.ContextWithAuth(ctx, &tabby.APIKeyAuthenticator{Key: tenant.Key})
+//	resp, err := client.Completions().Create(ctx, req)
+func ContextWithAuth(ctx context.Context, a Authenticator) context.Context {
+	return auth.NewContext(ctx, a)
+}
+
+// ContextWithMaxRetries returns a copy of ctx whose requests are retried at
+// most n times, overriding the MaxRetries of the client's retry policy for
+// that call only. n <= 0 disables retries, as for a user-interactive action
+// that should fail fast. The policy still decides which failures are
+// retried and how long to wait. Requests made with ctx are never coalesced
+// by WithRequestDeduplication.
+//
+// Example:
+//
+//	ctx = tabby.ContextWithMaxRetries(ctx, 0)
+//	resp, err := client.Chat().Create(ctx, req)
+func ContextWithMaxRetries(ctx context.Context, n int) context.Context {
+	return rest.ContextWithMaxRetries(ctx, n)
+}
+
 // Implement WithX methods for clientImpl
 func (c *clientImpl) WithBaseURL(url string) Client {
 	c.baseURL = url
@@ -405,12 +819,12 @@ func (c *clientImpl) WithHTTPClient(client *http.Client) Client {
 }
 
 func (c *clientImpl) WithAPIKey(key string) Client {
-	c.auth = &APIKeyAuthenticator{Key: key}
+	c.setKeyAuth(&APIKeyAuthenticator{Key: key})
 	return c
 }
 
 func (c *clientImpl) WithAdminKey(key string) Client {
-	c.auth = &AdminKeyAuthenticator{Key: key}
+	c.setKeyAuth(&AdminKeyAuthenticator{Key: key})
 	return c
 }
 
@@ -429,23 +843,83 @@ func (c *clientImpl) WithRetryPolicy(policy RetryPolicy) Client {
 	return c
 }
 
-// getRestClient returns a REST client, initializing it if needed
+// getRestClient returns a REST client, initializing it if needed. Services
+// may be requested from several goroutines, and they must share one REST
+// client so limits such as WithMaxConcurrent apply client-wide.
 func (c *clientImpl) getRestClient() *rest.Client {
+	c.restMu.Lock()
+	defer c.restMu.Unlock()
+
 	if c.restClient == nil {
 		authProvider := auth.NoAuth
 		if c.auth != nil {
 			authProvider = c.auth
 		}
 
-		c.restClient = rest.New(
-			c.baseURL,
+		options := []rest.ClientOption{
 			rest.WithHTTPClient(c.httpClient),
 			rest.WithAuth(authProvider),
-		)
+			rest.WithBaseContext(c.baseCtx),
+			rest.WithMaxConcurrent(c.maxConcurrent),
+			rest.WithDeduplication(c.deduplicate),
+		}
+		retryPolicy, streamRetryPolicy := c.retryPolicy, c.streamRetryPolicy
+		if c.healthAwareRetry {
+			// Health checks bypass the concurrency limit, which the request
+			// being retried already holds a slot of, and are never retried
+			health := &healthService{client: rest.New(c.baseURL,
+				rest.WithHTTPClient(c.httpClient),
+				rest.WithAuth(authProvider),
+				rest.WithBaseContext(c.baseCtx),
+			)}
+			if retryPolicy != nil {
+				retryPolicy = &healthAwareRetryPolicy{RetryPolicy: retryPolicy, health: health}
+			}
+			if streamRetryPolicy != nil {
+				streamRetryPolicy = &healthAwareRetryPolicy{RetryPolicy: streamRetryPolicy, health: health}
+			}
+		}
+		if retryPolicy != nil {
+			options = append(options, rest.WithRetryPolicy(retryPolicy))
+		}
+		if streamRetryPolicy != nil {
+			options = append(options, rest.WithStreamConnectRetry(streamRetryPolicy))
+		}
+		if c.metrics != nil {
+			options = append(options, rest.WithResponseHook(c.metrics.observe))
+		}
+		if c.beforeRetry != nil {
+			options = append(options, rest.WithBeforeRetry(c.redactedBeforeRetry))
+		}
+
+		c.restClient = rest.New(c.baseURL, options...)
 	}
 	return c.restClient
 }
 
+// Logical endpoint names accepted by WithEndpointOverride.
+const (
+	EndpointCompletions = "completions"
+	EndpointChat        = "chat"
+	EndpointEmbeddings  = "embeddings"
+)
+
+// defaultEndpoints maps logical endpoint names to their TabbyAPI paths.
+var defaultEndpoints = map[string]string{
+	EndpointCompletions: "v1/completions",
+	EndpointChat:        "v1/chat/completions",
+	EndpointEmbeddings:  "v1/embeddings",
+}
+
+// endpoint returns the path for a logical endpoint name, applying any
+// override set with WithEndpointOverride.
+func (c *clientImpl) endpoint(name string) string {
+	if path, ok := c.endpoints[name]; ok {
+		return path
+	}
+	return defaultEndpoints[name]
+}
+
 // buildURL constructs the URL for the API request
 func (c *clientImpl) buildURL(endpoint string) string {
 	endpoint = strings.TrimLeft(endpoint, "/")
@@ -454,27 +928,82 @@ func (c *clientImpl) buildURL(endpoint string) string {
 
 // Service getters
 func (c *clientImpl) Completions() CompletionsService {
-	return &completionsService{client: c.getRestClient(), baseURL: c.baseURL}
+	return &completionsService{
+		client:            c.getRestClient(),
+		baseURL:           c.baseURL,
+		endpoint:          c.endpoint(EndpointCompletions),
+		stream:            c.stream,
+		tokens:            c.tokens,
+		validateResponses: c.validateResponses,
+		strictStream:      c.strictStream,
+		guard:             c.newContextGuard(),
+	}
 }
 
 func (c *clientImpl) Chat() ChatService {
-	return &chatService{client: c.getRestClient(), baseURL: c.baseURL}
+	return &chatService{
+		client:            c.getRestClient(),
+		baseURL:           c.baseURL,
+		endpoint:          c.endpoint(EndpointChat),
+		maxTokensField:    c.maxTokensField,
+		sanitizeMessages:  c.sanitizeMessages,
+		dropEmptyMessages: c.dropEmptyMessages,
+		stream:            c.stream,
+		tokens:            c.tokens,
+		validateResponses: c.validateResponses,
+
+		enforceSystemFirst:  c.enforceSystemFirst,
+		mergeSystemMessages: c.mergeSystemMessages,
+		strictStream:        c.strictStream,
+		guard:               c.newContextGuard(),
+	}
+}
+
+// newContextGuard returns the guard for generation services, or nil if
+// WithContextGuard is not enabled.
+func (c *clientImpl) newContextGuard() *contextGuard {
+	if !c.contextGuard {
+		return nil
+	}
+	models := c.Models().(*modelsService)
+	models.cache = c.guardCache
+	return &contextGuard{
+		models: models,
+		tokens: &tokensService{client: c.getRestClient()},
+	}
 }
 
 func (c *clientImpl) Models() ModelsService {
-	return &modelsService{client: c.getRestClient(), baseURL: c.baseURL}
+	return &modelsService{
+		client:          c.getRestClient(),
+		baseURL:         c.baseURL,
+		unloadMethod:    c.unloadMethod,
+		cache:           c.modelCache,
+		guardCache:      c.guardCache,
+		notLoadedErrors: c.notLoadedErrors,
+	}
 }
 
 func (c *clientImpl) Embeddings() EmbeddingsService {
-	return &embeddingsService{client: c.getRestClient()}
+	return &embeddingsService{
+		client:            c.getRestClient(),
+		endpoint:          c.endpoint(EndpointEmbeddings),
+		validateResponses: c.validateResponses,
+		autoLoadModel:     c.autoLoadEmbeddingModel,
+		models:            c.Models().(*modelsService),
+	}
 }
 
 func (c *clientImpl) Lora() LoraService {
-	return &loraService{client: c.getRestClient()}
+	return &loraService{
+		client:          c.getRestClient(),
+		unloadMethod:    c.unloadMethod,
+		notLoadedErrors: c.notLoadedErrors,
+	}
 }
 
 func (c *clientImpl) Templates() TemplatesService {
-	return &templatesService{client: c.getRestClient()}
+	return &templatesService{client: c.getRestClient(), unloadMethod: c.unloadMethod}
 }
 
 func (c *clientImpl) Tokens() TokensService {
@@ -482,7 +1011,7 @@ func (c *clientImpl) Tokens() TokensService {
 }
 
 func (c *clientImpl) Sampling() SamplingService {
-	return &samplingService{client: c.getRestClient()}
+	return &samplingService{client: c.getRestClient(), unloadMethod: c.unloadMethod}
 }
 
 func (c *clientImpl) Health() HealthService {
@@ -493,26 +1022,188 @@ func (c *clientImpl) Auth() AuthService {
 	return &authService{client: c.getRestClient()}
 }
 
+func (c *clientImpl) Generate(ctx context.Context, req *ChatCompletionRequest) (string, error) {
+	resp, err := c.Chat().Create(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	msg, ok := resp.FirstMessage()
+	if !ok {
+		return "", fmt.Errorf("failed to generate: no choices returned")
+	}
+	return contentText(msg.Content), nil
+}
+
+func (c *clientImpl) GenerateStream(ctx context.Context, req *ChatCompletionRequest, onDelta func(delta string) error) (string, error) {
+	resp, err := c.Chat().CreateStreamCallback(ctx, req, onDelta)
+	if err != nil {
+		return "", err
+	}
+	msg, ok := resp.FirstMessage()
+	if !ok {
+		return "", fmt.Errorf("failed to generate: no choices returned")
+	}
+	return contentText(msg.Content), nil
+}
+
 // Internal stream implementation to avoid circular imports
 // GenericStream implements a generic SSE stream
 type GenericStream[T any] struct {
-	ctx      context.Context
-	cancel   context.CancelFunc
-	response *http.Response
-	reader   *bufio.Reader
-	closed   bool
-	mu       sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	response  *http.Response
+	reader    *bufio.Reader
+	closed    bool
+	mu        sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+
+	// noContent is set for a 204 response, which has no events to read
+	noContent bool
+
+	// terminalEvents are SSE event types that end the stream like io.EOF;
+	// ended is set once one has been received
+	terminalEvents []string
+	ended          bool
+
+	// utf8 holds back split UTF-8 sequences when WithUTF8Buffering is enabled
+	utf8 *utf8Carry
+
+	// pending holds data payloads split out of the last event, returned
+	// before any further event is read
+	pending []string
 }
 
-// newGenericStream creates a new stream for handling SSE responses
+// streamConfig holds client-level settings applied to generation streams.
+type streamConfig struct {
+	utf8Buffering bool
+
+	// terminalEvents replaces defaultTerminalEvents when non-nil
+	terminalEvents []string
+
+	// maxDuration caps the stream's lifetime when positive
+	maxDuration time.Duration
+
+	// bufferSize gives each stream its own read buffer of this size, in
+	// place of a pooled one, when positive
+	bufferSize int
+}
+
+// defaultTerminalEvents are the SSE event types some servers send to signal
+// the end of a stream instead of closing it.
+var defaultTerminalEvents = []string{"done", "end"}
+
+// configure applies client-level stream settings and returns s.
+func (s *GenericStream[T]) configure(config streamConfig) *GenericStream[T] {
+	if config.utf8Buffering {
+		s.utf8 = newUTF8Carry()
+	}
+	if config.terminalEvents != nil {
+		s.terminalEvents = config.terminalEvents
+	}
+	if config.maxDuration > 0 {
+		s.setMaxDuration(config.maxDuration)
+	}
+	if config.bufferSize > 0 && config.bufferSize != streamReaderSize {
+		// Nothing has been read yet, so the pooled reader can be swapped out
+		putStreamReader(s.reader)
+		s.reader = bufio.NewReaderSize(streamBody(s.response), config.bufferSize)
+	}
+	return s
+}
+
+// setMaxDuration puts a deadline d from now on the stream's context. The
+// body is closed when the deadline passes, which unblocks a pending read.
+func (s *GenericStream[T]) setMaxDuration(d time.Duration) {
+	ctx, cancel := context.WithTimeout(s.ctx, d)
+	parentCancel := s.cancel
+	s.ctx = ctx
+	s.cancel = func() {
+		cancel()
+		parentCancel()
+	}
+
+	context.AfterFunc(ctx, func() {
+		if ctx.Err() == context.DeadlineExceeded && s.response != nil && s.response.Body != nil {
+			s.response.Body.Close()
+		}
+	})
+}
+
+// streamReaderSize is the size of the pooled stream read buffers.
+const streamReaderSize = 4096
+
+// streamReaderPool holds bufio.Readers for reuse across streams, so many
+// short streams do not each allocate a fresh read buffer.
+var streamReaderPool = sync.Pool{
+	New: func() interface{} { return bufio.NewReaderSize(nil, streamReaderSize) },
+}
+
+// newGenericStream creates a new stream for handling SSE responses.
+// A 204 No Content response yields a stream whose first Recv returns io.EOF.
 func newGenericStream[T any](ctx context.Context, resp *http.Response) *GenericStream[T] {
 	ctx, cancel := context.WithCancel(ctx)
 	return &GenericStream[T]{
-		ctx:      ctx,
-		cancel:   cancel,
-		response: resp,
-		reader:   bufio.NewReader(resp.Body),
+		ctx:            ctx,
+		cancel:         cancel,
+		response:       resp,
+		reader:         getStreamReader(streamBody(resp)),
+		noContent:      resp.StatusCode == http.StatusNoContent,
+		terminalEvents: defaultTerminalEvents,
+	}
+}
+
+// streamBody returns resp's body, decompressed when a proxy has gzip- or
+// deflate-encoded the event stream. Streaming requests ask for the identity
+// encoding, so the transport leaves any such encoding in place.
+func streamBody(resp *http.Response) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		return &streamDecoder{src: resp.Body, open: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		}}
+	case "deflate":
+		return &streamDecoder{src: resp.Body, open: func(r io.Reader) (io.Reader, error) {
+			return zlib.NewReader(r)
+		}}
+	}
+	return resp.Body
+}
+
+// streamDecoder opens its decompressor on the first Read, since reading the
+// compression header would otherwise block until the first event arrives.
+type streamDecoder struct {
+	src  io.Reader
+	open func(io.Reader) (io.Reader, error)
+	r    io.Reader
+	err  error
+}
+
+func (d *streamDecoder) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r, d.err = d.open(d.src)
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+// getStreamReader returns a pooled reader reset to read from r.
+func getStreamReader(r io.Reader) *bufio.Reader {
+	reader := streamReaderPool.Get().(*bufio.Reader)
+	reader.Reset(r)
+	return reader
+}
+
+// putStreamReader returns reader to the pool, dropping its reference to the body.
+// Readers sized by WithStreamBufferSize are left for the garbage collector.
+func putStreamReader(reader *bufio.Reader) {
+	if reader.Size() != streamReaderSize {
+		return
 	}
+	reader.Reset(nil)
+	streamReaderPool.Put(reader)
 }
 
 // Recv reads the next item from the stream
@@ -524,6 +1215,9 @@ func (s *GenericStream[T]) Recv() (T, error) {
 	if s.closed {
 		return empty, ErrStreamClosed
 	}
+	if s.noContent || s.ended {
+		return empty, io.EOF
+	}
 
 	// Check if context has been canceled
 	select {
@@ -532,15 +1226,41 @@ func (s *GenericStream[T]) Recv() (T, error) {
 	default:
 	}
 
-	// Read and parse the next event
-	event, err := s.readEvent()
-	if err != nil {
-		return empty, err
+	var data []byte
+	if len(s.pending) > 0 {
+		data = []byte(s.pending[0])
+		s.pending = s.pending[1:]
+	} else {
+		// Read and parse the next event
+		event, err := s.readEvent()
+		if err != nil {
+			// A read cut short by the max duration reports the deadline
+			if s.ctx.Err() == context.DeadlineExceeded {
+				return empty, s.ctx.Err()
+			}
+			return empty, err
+		}
+		if s.isTerminal(event.event) {
+			s.ended = true
+			return empty, io.EOF
+		}
+		data = []byte(event.data)
+		s.pending = event.more
+	}
+
+	if apiErr := parseStreamErrorFrame(data, s.response.StatusCode); apiErr != nil {
+		s.ended = true
+		s.pending = nil
+		return empty, apiErr
+	}
+
+	if s.utf8 != nil {
+		data = s.utf8.apply(data)
 	}
 
 	// Parse the data
 	var item T
-	if err := json.Unmarshal([]byte(event.data), &item); err != nil {
+	if err := json.Unmarshal(data, &item); err != nil {
 		return empty, &StreamError{
 			Message: "failed to unmarshal event data",
 			Err:     err,
@@ -550,22 +1270,42 @@ func (s *GenericStream[T]) Recv() (T, error) {
 	return item, nil
 }
 
-// Close closes the stream and releases resources
-func (s *GenericStream[T]) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.closed {
-		return nil
+// isTerminal reports whether eventType is one of the stream's terminal events.
+func (s *GenericStream[T]) isTerminal(eventType string) bool {
+	for _, terminal := range s.terminalEvents {
+		if strings.EqualFold(eventType, terminal) {
+			return true
+		}
 	}
+	return false
+}
+
+// Close closes the stream and releases resources.
+// It is safe to call from another goroutine while Recv is blocked; closing
+// the body unblocks the pending read.
+func (s *GenericStream[T]) Close() error {
+	first := false
+	s.closeOnce.Do(func() {
+		first = true
+		s.cancel()
+		if s.response != nil && s.response.Body != nil {
+			s.closeErr = s.response.Body.Close()
+		}
+	})
 
+	// Recv checks closed under the same lock, so the reader is never used again
+	s.mu.Lock()
 	s.closed = true
-	s.cancel()
+	if s.reader != nil {
+		putStreamReader(s.reader)
+		s.reader = nil
+	}
+	s.mu.Unlock()
 
-	if s.response != nil && s.response.Body != nil {
-		return s.response.Body.Close()
+	if !first {
+		return nil
 	}
-	return nil
+	return s.closeErr
 }
 
 // sseEvent represents a Server-Sent Event
@@ -573,9 +1313,16 @@ type sseEvent struct {
 	id    string
 	event string
 	data  string
+
+	// more holds further payloads when the event's data lines turned out
+	// to be separate messages; see joinDataLines
+	more []string
 }
 
-// readEvent reads a single SSE event from the response body
+// readEvent reads a single SSE event from the response body.
+// Lines are consumed as soon as they arrive, so an event is returned the
+// moment its terminating blank line is read rather than when the read
+// buffer fills.
 func (s *GenericStream[T]) readEvent() (*sseEvent, error) {
 	var buffer bytes.Buffer
 
@@ -612,6 +1359,7 @@ func (s *GenericStream[T]) readEvent() (*sseEvent, error) {
 // parseEvent parses a string into an SSE event
 func parseEvent(eventStr string) (*sseEvent, error) {
 	event := &sseEvent{}
+	var data []string
 	for _, line := range strings.Split(eventStr, "\n") {
 		line = strings.TrimRight(line, "\r")
 		if line == "" {
@@ -624,10 +1372,7 @@ func parseEvent(eventStr string) (*sseEvent, error) {
 		} else if strings.HasPrefix(line, "event:") {
 			event.event = strings.TrimSpace(line[6:])
 		} else if strings.HasPrefix(line, "data:") {
-			if event.data != "" {
-				event.data += "\n"
-			}
-			event.data += strings.TrimSpace(line[5:])
+			data = append(data, strings.TrimSpace(line[5:]))
 		} else if strings.HasPrefix(line, ":") {
 			// Comment, ignore
 		} else if strings.Contains(line, ":") {
@@ -641,14 +1386,12 @@ func parseEvent(eventStr string) (*sseEvent, error) {
 				} else if field == "event" {
 					event.event = value
 				} else if field == "data" {
-					if event.data != "" {
-						event.data += "\n"
-					}
-					event.data += value
+					data = append(data, value)
 				}
 			}
 		}
 	}
+	event.data, event.more = joinDataLines(data)
 
 	// If no event type is specified, it's a "message" event
 	if event.event == "" {
@@ -658,56 +1401,205 @@ func parseEvent(eventStr string) (*sseEvent, error) {
 	return event, nil
 }
 
+// streamErrorFrame is an error object sent as a stream event in place of a
+// chunk, as TabbyAPI does when generation fails after the 200 response has
+// started.
+type streamErrorFrame struct {
+	Error *struct {
+		Message string      `json:"message"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
+
+// parseStreamErrorFrame returns an *APIError if data is an error frame, or
+// nil otherwise. The error's StatusCode is the frame's numeric code when it
+// is an HTTP error status, and status (the response's) otherwise.
+func parseStreamErrorFrame(data []byte, status int) *APIError {
+	// Chunks rarely mention "error", so most skip the second decode
+	if !bytes.Contains(data, []byte(`"error"`)) {
+		return nil
+	}
+
+	var frame streamErrorFrame
+	if err := json.Unmarshal(data, &frame); err != nil || frame.Error == nil {
+		return nil
+	}
+
+	var details interface{}
+	_ = json.Unmarshal(data, &details)
+	if code, ok := frame.Error.Code.(float64); ok && code >= 400 && code < 600 {
+		status = int(code)
+	}
+	message := frame.Error.Message
+	if message == "" {
+		message = "stream returned an error"
+	}
+	return &APIError{StatusCode: status, Message: message, Details: details}
+}
+
+// joinDataLines combines the data lines of one event. A single line, the
+// norm for TabbyAPI's OpenAI-style streams, is used as is. Several lines are
+// joined with newlines as the SSE format specifies, unless each line is a
+// complete JSON document by itself: those are separate messages whose
+// blank-line separators were lost, and joining them would corrupt the JSON,
+// so the first is returned as data and the rest as more.
+func joinDataLines(lines []string) (data string, more []string) {
+	switch len(lines) {
+	case 0:
+		return "", nil
+	case 1:
+		return lines[0], nil
+	}
+
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			return strings.Join(lines, "\n"), nil
+		}
+	}
+	return lines[0], lines[1:]
+}
+
 // Helper functions to create typed streams
-func createCompletionStream(ctx context.Context, resp *http.Response) CompletionStream {
-	return newGenericStream[*CompletionStreamResponse](ctx, resp)
+func createCompletionStream(ctx context.Context, resp *http.Response, config streamConfig) CompletionStream {
+	return newGenericStream[*CompletionStreamResponse](ctx, resp).configure(config)
 }
 
-func createChatCompletionStream(ctx context.Context, resp *http.Response) ChatCompletionStream {
-	return newGenericStream[*ChatCompletionStreamResponse](ctx, resp)
+func createChatCompletionStream(ctx context.Context, resp *http.Response, config streamConfig) ChatCompletionStream {
+	return newGenericStream[*ChatCompletionStreamResponse](ctx, resp).configure(config)
 }
 
 func createModelLoadStream(ctx context.Context, resp *http.Response) ModelLoadStream {
 	return newGenericStream[*ModelLoadResponse](ctx, resp)
 }
 
+// RawStream sends a request to an arbitrary server-sent events endpoint and
+// streams its events decoded as T, for endpoints the client has no method
+// for yet. path is relative to the base URL, as in "v1/custom/stream", and
+// body, if non-nil, is sent as JSON; a json.RawMessage is sent verbatim.
+//
+// The request carries the client's authentication, and establishing the
+// stream is retried as for the built-in streams. The stream honors
+// WithUTF8Buffering, WithTerminalStreamEvents, and WithStreamMaxDuration.
+// RawStream is a function rather than a Client method because Go methods
+// cannot take type parameters; client must have been created by NewClient.
+//
+// Example:
+//
+//	stream, err := tabby.RawStream[*MyEvent](ctx, client, http.MethodPost, "v1/custom/stream", req)
+//	if err != nil {
+//	    return err
+//	}
+//	defer stream.Close()
+func RawStream[T any](ctx context.Context, client Client, method, path string, body interface{}) (Stream[T], error) {
+	c, ok := client.(*clientImpl)
+	if !ok {
+		return nil, fmt.Errorf("RawStream requires a client created by NewClient, got %T", client)
+	}
+
+	resp, err := c.getRestClient().DoRaw(ctx, method, c.buildURL(path), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream: %w", err)
+	}
+	return newGenericStream[T](ctx, resp).configure(c.stream), nil
+}
+
 // Service implementations
 
 // completionsService implements the CompletionsService interface
 type completionsService struct {
-	client  *rest.Client
-	baseURL string
+	client   *rest.Client
+	baseURL  string
+	endpoint string
+	stream   streamConfig
+	tokens   *tokenLimiter
+
+	validateResponses bool
+	strictStream      bool
+	guard             *contextGuard
+}
+
+// prepare validates req and copies it with the stream flag forced, leaving
+// the caller's request untouched. With WithStrictStreamFlag, a mismatched
+// stream flag is an error instead.
+func (s *completionsService) prepare(req *CompletionRequest, stream bool) (*CompletionRequest, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateStreamFlag(s.strictStream, req.Stream, stream); err != nil {
+		return nil, err
+	}
+
+	reqCopy := *req
+	reqCopy.Stream = stream
+	if err := validateStreamOptions(reqCopy.Stream, reqCopy.StreamOptions); err != nil {
+		return nil, err
+	}
+	if err := validateTokenFilters(reqCopy.AllowedTokens, reqCopy.BannedTokens, reqCopy.AllowedStrings, reqCopy.BannedStrings); err != nil {
+		return nil, err
+	}
+	return &reqCopy, nil
+}
+
+func (s *completionsService) Marshal(req *CompletionRequest) ([]byte, error) {
+	reqCopy, err := s.prepare(req, req.Stream)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(reqCopy)
 }
 
 func (s *completionsService) Create(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
 	// Force stream to false to ensure we get a regular response
-	reqCopy := *req
-	reqCopy.Stream = false
+	reqCopy, err := s.prepare(req, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guard.fitCompletion(ctx, reqCopy); err != nil {
+		return nil, err
+	}
+
+	if err := s.tokens.wait(ctx); err != nil {
+		return nil, err
+	}
 
 	// Create a response object
 	var response CompletionResponse
 
 	// Send the request to the completions endpoint
-	err := s.client.Post(ctx, "v1/completions", &reqCopy, &response)
+	err = s.client.Post(ctx, s.endpoint, reqCopy, &response)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create completion: %w", err)
 	}
+	s.tokens.consumeUsage(response.Usage)
+	if err := checkObjectType(s.validateResponses, response.Object, ObjectTextCompletion); err != nil {
+		return nil, err
+	}
 
 	return &response, nil
 }
 
 func (s *completionsService) CreateStream(ctx context.Context, req *CompletionRequest) (CompletionStream, error) {
 	// Force stream to true to ensure we get a streaming response
-	reqCopy := *req
-	reqCopy.Stream = true
+	reqCopy, err := s.prepare(req, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guard.fitCompletion(ctx, reqCopy); err != nil {
+		return nil, err
+	}
+
+	// Usage is unknown until the stream ends, so charge the token limit up front
+	if err := s.tokens.wait(ctx); err != nil {
+		return nil, err
+	}
+	s.tokens.consumeStream(reqCopy.MaxTokens)
 
 	// Construct the URL manually
-	endpoint := "v1/completions"
-	endpoint = strings.TrimLeft(endpoint, "/")
+	endpoint := strings.TrimLeft(s.endpoint, "/")
 	url := fmt.Sprintf("%s/%s", s.baseURL, endpoint)
 
 	// Create the request
-	reqBody, err := json.Marshal(&reqCopy)
+	reqBody, err := json.Marshal(reqCopy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
@@ -721,76 +1613,387 @@ func (s *completionsService) CreateStream(ctx context.Context, req *CompletionRe
 	httpReq.Header.Set("Accept", "application/json")
 
 	// Send the request
-	resp, err := s.client.DoRaw(ctx, http.MethodPost, url, &reqCopy)
+	resp, err := s.client.DoRaw(ctx, http.MethodPost, url, reqCopy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create completion stream: %w", err)
 	}
 
 	// Create a stream from the response
-	return createCompletionStream(ctx, resp), nil
+	return createCompletionStream(ctx, resp, s.stream), nil
+}
+
+func (s *completionsService) CreateTimed(ctx context.Context, req *CompletionRequest) (*CompletionResponse, time.Duration, error) {
+	start := time.Now()
+	response, err := s.Create(ctx, req)
+	return response, time.Since(start), err
+}
+
+func (s *completionsService) CreateRaw(ctx context.Context, body json.RawMessage) (*CompletionResponse, error) {
+	if err := s.tokens.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var response CompletionResponse
+	err := s.client.Post(ctx, s.endpoint, body, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create completion: %w", err)
+	}
+	s.tokens.consumeUsage(response.Usage)
+	if err := checkObjectType(s.validateResponses, response.Object, ObjectTextCompletion); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // chatService implements the ChatService interface
 type chatService struct {
-	client  *rest.Client
-	baseURL string
+	client           *rest.Client
+	baseURL          string
+	endpoint         string
+	maxTokensField   MaxTokensField
+	sanitizeMessages bool
+	stream           streamConfig
+	tokens           *tokenLimiter
+
+	dropEmptyMessages bool
+
+	validateResponses bool
+
+	enforceSystemFirst  bool
+	mergeSystemMessages bool
+	strictStream        bool
+	guard               *contextGuard
+}
+
+// prepare validates req and copies it with the stream flag forced and
+// client-level request settings applied, leaving the caller's request
+// untouched. With WithStrictStreamFlag, a mismatched stream flag is an error
+// instead.
+func (s *chatService) prepare(req *ChatCompletionRequest, stream bool) (*ChatCompletionRequest, error) {
+	if err := validateStreamFlag(s.strictStream, req.Stream, stream); err != nil {
+		return nil, err
+	}
+
+	reqCopy := *req
+	reqCopy.Stream = stream
+	if err := validateStreamOptions(reqCopy.Stream, reqCopy.StreamOptions); err != nil {
+		return nil, err
+	}
+	if err := validateTokenFilters(reqCopy.AllowedTokens, reqCopy.BannedTokens, reqCopy.AllowedStrings, reqCopy.BannedStrings); err != nil {
+		return nil, err
+	}
+
+	// Send the token limit under the configured key(s)
+	limit := reqCopy.MaxTokens
+	if reqCopy.MaxCompletionTokens != 0 {
+		limit = reqCopy.MaxCompletionTokens
+	}
+	switch s.maxTokensField {
+	case MaxTokensFieldCompletion:
+		reqCopy.MaxTokens, reqCopy.MaxCompletionTokens = 0, limit
+	case MaxTokensFieldBoth:
+		reqCopy.MaxTokens, reqCopy.MaxCompletionTokens = limit, limit
+	default:
+		reqCopy.MaxTokens, reqCopy.MaxCompletionTokens = limit, 0
+	}
+
+	if s.sanitizeMessages {
+		reqCopy.Messages = SanitizeMessages(reqCopy.Messages, s.dropEmptyMessages)
+	}
+	if s.enforceSystemFirst {
+		messages, err := EnforceSystemFirst(reqCopy.Messages, s.mergeSystemMessages)
+		if err != nil {
+			return nil, err
+		}
+		reqCopy.Messages = messages
+	}
+
+	return &reqCopy, nil
+}
+
+func (s *chatService) Marshal(req *ChatCompletionRequest) ([]byte, error) {
+	reqCopy, err := s.prepare(req, req.Stream)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(reqCopy)
 }
 
 func (s *chatService) Create(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
 	// Force stream to false to ensure we get a regular response
-	reqCopy := *req
-	reqCopy.Stream = false
+	reqCopy, err := s.prepare(req, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guard.fitChat(ctx, reqCopy); err != nil {
+		return nil, err
+	}
+
+	if err := s.tokens.wait(ctx); err != nil {
+		return nil, err
+	}
 
 	// Create a response object
 	var response ChatCompletionResponse
 
 	// Send the request to the chat completions endpoint
-	err := s.client.Post(ctx, "v1/chat/completions", &reqCopy, &response)
+	err = s.client.Post(ctx, s.endpoint, reqCopy, &response)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat completion: %w", err)
 	}
+	s.tokens.consumeUsage(response.Usage)
+	if err := checkObjectType(s.validateResponses, response.Object, ObjectChatCompletion); err != nil {
+		return nil, err
+	}
 
 	return &response, nil
 }
 
 func (s *chatService) CreateStream(ctx context.Context, req *ChatCompletionRequest) (ChatCompletionStream, error) {
 	// Force stream to true to ensure we get a streaming response
-	reqCopy := *req
-	reqCopy.Stream = true
+	reqCopy, err := s.prepare(req, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guard.fitChat(ctx, reqCopy); err != nil {
+		return nil, err
+	}
+
+	// Usage is unknown until the stream ends, so charge the token limit up
+	// front; prepare has already folded MaxCompletionTokens into one of the two
+	if err := s.tokens.wait(ctx); err != nil {
+		return nil, err
+	}
+	s.tokens.consumeStream(max(reqCopy.MaxTokens, reqCopy.MaxCompletionTokens))
 
 	// Construct the URL manually
-	endpoint := "v1/chat/completions"
-	endpoint = strings.TrimLeft(endpoint, "/")
+	endpoint := strings.TrimLeft(s.endpoint, "/")
 	url := fmt.Sprintf("%s/%s", s.baseURL, endpoint)
 
 	// Send the request
-	resp, err := s.client.DoRaw(ctx, http.MethodPost, url, &reqCopy)
+	resp, err := s.client.DoRaw(ctx, http.MethodPost, url, reqCopy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat completion stream: %w", err)
 	}
 
 	// Create a stream from the response
-	return createChatCompletionStream(ctx, resp), nil
+	return createChatCompletionStream(ctx, resp, s.stream), nil
+}
+
+func (s *chatService) CreateTimed(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, time.Duration, error) {
+	start := time.Now()
+	response, err := s.Create(ctx, req)
+	return response, time.Since(start), err
+}
+
+func (s *chatService) CreateStreamCallback(ctx context.Context, req *ChatCompletionRequest, onDelta func(delta string) error) (*ChatCompletionResponse, error) {
+	stream, err := s.CreateStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	return assembleChatStream(stream, onDelta)
+}
+
+func (s *chatService) CreateStreamSchema(ctx context.Context, req *ChatCompletionRequest, schema map[string]interface{}) (ChatCompletionStream, error) {
+	reqCopy := *req
+	if reqCopy.JSONSchema == nil {
+		reqCopy.JSONSchema = schema
+	}
+
+	stream, err := s.CreateStream(ctx, &reqCopy)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaStream{ChatCompletionStream: stream, scanner: newSchemaScanner(schema)}, nil
+}
+
+func (s *chatService) Continue(ctx context.Context, prevReq *ChatCompletionRequest, prevResp *ChatCompletionResponse) (*ChatCompletionResponse, error) {
+	partial, ok := prevResp.FirstMessage()
+	if !ok {
+		return nil, &ValidationError{Field: "choices", Message: "previous response has no choices to continue"}
+	}
+	prefix := contentText(partial.Content)
+
+	reqCopy := *prevReq
+	reqCopy.Messages = append(append([]ChatMessage(nil), prevReq.Messages...), ChatMessage{
+		Role:    ChatMessageRoleAssistant,
+		Content: prefix,
+	})
+	reqCopy.Stream = false
+	reqCopy.AddGenerationPrompt = Bool(false)
+
+	response, err := s.Create(ctx, &reqCopy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to continue chat completion: %w", err)
+	}
+	if len(response.Choices) > 0 {
+		first := &response.Choices[0]
+		first.Message.Content = prefix + contentText(first.Message.Content)
+	}
+	return response, nil
+}
+
+func (s *chatService) CountPromptTokens(ctx context.Context, req *ChatCompletionRequest) (int, error) {
+	// A zero limit would be omitted and fall back to the server default, so
+	// generate the minimum of one token
+	reqCopy := *req
+	reqCopy.MaxTokens = 1
+	reqCopy.MaxCompletionTokens = 0
+	reqCopy.Stream = false
+	reqCopy.StreamOptions = nil
+
+	response, err := s.Create(ctx, &reqCopy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count prompt tokens: %w", err)
+	}
+	if response.Usage == nil {
+		return 0, fmt.Errorf("failed to count prompt tokens: server did not report usage")
+	}
+	return response.Usage.PromptTokens, nil
+}
+
+func (s *chatService) CreateRaw(ctx context.Context, body json.RawMessage) (*ChatCompletionResponse, error) {
+	if err := s.tokens.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var response ChatCompletionResponse
+	err := s.client.Post(ctx, s.endpoint, body, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat completion: %w", err)
+	}
+	s.tokens.consumeUsage(response.Usage)
+	if err := checkObjectType(s.validateResponses, response.Object, ObjectChatCompletion); err != nil {
+		return nil, err
+	}
+	return &response, nil
 }
 
 // embeddingsService implements the EmbeddingsService interface
 type embeddingsService struct {
-	client *rest.Client
+	client   *rest.Client
+	endpoint string
+
+	validateResponses bool
+
+	// autoLoadModel is loaded through models and the request retried once
+	// when no embedding model is loaded; empty disables it
+	autoLoadModel string
+	models        *modelsService
+}
+
+func (s *embeddingsService) Marshal(req *EmbeddingsRequest) ([]byte, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(req)
 }
 
 func (s *embeddingsService) Create(ctx context.Context, req *EmbeddingsRequest) (*EmbeddingsResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
 	var response EmbeddingsResponse
-	err := s.client.Post(ctx, "v1/embeddings", req, &response)
+	err := s.client.Post(ctx, s.endpoint, req, &response)
+	if err != nil && s.autoLoadModel != "" && errors.Is(err, ErrNoEmbeddingModelLoaded) {
+		_, loadErr := s.models.LoadEmbedding(ctx, &EmbeddingModelLoadRequest{EmbeddingModelName: s.autoLoadModel})
+		if loadErr != nil {
+			return nil, fmt.Errorf("failed to auto-load embedding model %q: %w", s.autoLoadModel, loadErr)
+		}
+		err = s.client.Post(ctx, s.endpoint, req, &response)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embeddings: %w", err)
 	}
+	if err := checkObjectType(s.validateResponses, response.Object, ObjectList); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
 
+func (s *embeddingsService) CreateOne(ctx context.Context, text string) ([]float32, error) {
+	response, err := s.Create(ctx, &EmbeddingsRequest{Input: text})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("failed to create embeddings: no embeddings returned")
+	}
+	return response.Data[0].AsFloat32()
+}
+
+func (s *embeddingsService) RankBySimilarity(ctx context.Context, query string, corpus []string, topK int) ([]ScoredText, error) {
+	if len(corpus) == 0 {
+		return nil, nil
+	}
+
+	queryVector, err := s.CreateOne(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(corpus))
+	for start := 0; start < len(corpus); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(corpus))
+		response, err := s.Create(ctx, &EmbeddingsRequest{Input: corpus[start:end]})
+		if err != nil {
+			return nil, err
+		}
+
+		// Results are placed by index, since the server may reorder them
+		for _, data := range response.Data {
+			if data.Index < 0 || start+data.Index >= end {
+				return nil, fmt.Errorf("failed to create embeddings: index %d out of range", data.Index)
+			}
+			vector, err := data.AsFloat32()
+			if err != nil {
+				return nil, err
+			}
+			vectors[start+data.Index] = vector
+		}
+		for i := start; i < end; i++ {
+			if vectors[i] == nil {
+				return nil, fmt.Errorf("failed to create embeddings: no embedding returned for corpus[%d]", i)
+			}
+		}
+	}
+
+	return rankByScore(queryVector, corpus, vectors, topK)
+}
+
+// unload sends a request to an unload endpoint. It uses DELETE unless
+// WithUnloadMethod selected POST, in which case an empty JSON object is sent
+// as the body.
+func unload(ctx context.Context, client *rest.Client, method, endpoint string) error {
+	if method == http.MethodPost {
+		return client.Post(ctx, endpoint, struct{}{}, nil)
+	}
+	return client.Delete(ctx, endpoint, nil)
+}
+
 // modelsService implements the ModelsService interface
 type modelsService struct {
-	client  *rest.Client
-	baseURL string
+	client       *rest.Client
+	baseURL      string
+	unloadMethod string
+	cache        *modelInfoCache
+
+	// guardCache is the context guard's cache, dropped along with cache so
+	// the guard sees a model loaded through this client
+	guardCache *modelInfoCache
+
+	// notLoadedErrors reports nothing loaded as ErrNoModelLoaded or
+	// ErrNoEmbeddingModelLoaded
+	notLoadedErrors bool
+}
+
+// invalidateCache drops the cached model info after a load or unload.
+func (s *modelsService) invalidateCache() {
+	s.cache.invalidate()
+	s.guardCache.invalidate()
 }
 
 func (s *modelsService) List(ctx context.Context) (*ModelList, error) {
@@ -799,29 +2002,168 @@ func (s *modelsService) List(ctx context.Context) (*ModelList, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to list models: %w", err)
 	}
+	response.setKind(ModelKindPrimary)
 	return &response, nil
 }
 
+func (s *modelsService) ListAll(ctx context.Context) ([]ModelCard, error) {
+	models, err := rest.NewPaginator[ModelCard](s.client, "v1/models", nil).All(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	for i := range models {
+		models[i].Kind = ModelKindPrimary
+	}
+	return models, nil
+}
+
 func (s *modelsService) Get(ctx context.Context) (*ModelCard, error) {
+	cache := s.cache.forContext(ctx)
+	if card, ok := cache.getCard(); ok {
+		return card, nil
+	}
+
 	var response ModelCard
 	err := s.client.Get(ctx, "v1/models/current", nil, &response)
+	if s.notLoadedErrors {
+		err = checkNotLoaded(err, response.ID == "", ErrNoModelLoaded)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current model: %w", err)
 	}
+	response.Kind = ModelKindPrimary
+	cache.putCard(&response)
 	return &response, nil
 }
 
+func (s *modelsService) GetCurrent(ctx context.Context) (*ModelCard, error) {
+	card, err := s.Get(ctx)
+	if err == nil {
+		return card, nil
+	}
+	// Older servers answer 404 rather than a recognizable message
+	if errors.Is(err, ErrNoModelLoaded) || ClassifyError(err) == KindNotFound {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// checkNotLoaded reports the not-loaded state of an endpoint describing what
+// is currently loaded as sentinel. That state is either a 404 response, which
+// stays in the chain for errors.As, or a successful but empty response, as
+// given by empty. Other errors are returned unchanged.
+func checkNotLoaded(err error, empty bool, sentinel error) error {
+	if err == nil {
+		if empty {
+			return sentinel
+		}
+		return nil
+	}
+	if !errors.Is(err, sentinel) && ClassifyError(err) == KindNotFound {
+		return fmt.Errorf("%w: %w", sentinel, err)
+	}
+	return err
+}
+
 func (s *modelsService) Load(ctx context.Context, req *ModelLoadRequest) (*ModelLoadResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
 	reqCopy := *req
 	var response ModelLoadResponse
 	err := s.client.Post(ctx, "v1/models/load", &reqCopy, &response)
+	s.invalidateCache()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load model: %w", err)
 	}
+
+	s.setCurrentModel(ctx, &response)
 	return &response, nil
 }
 
+// setCurrentModel sets resp.Model to the card of the model now loaded. The
+// load has succeeded either way, so a failed lookup only leaves Model unset.
+func (s *modelsService) setCurrentModel(ctx context.Context, resp *ModelLoadResponse) {
+	if current, err := s.Get(ctx); err == nil {
+		resp.Model = current
+	}
+}
+
+func (s *modelsService) LoadIfNeeded(ctx context.Context, req *ModelLoadRequest) (*ModelLoadResponse, bool, error) {
+	// Only a lookup that found nothing loaded falls through to loading; an
+	// auth, network, or server failure would most likely fail the load too
+	current, err := s.GetCurrent(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check current model: %w", err)
+	}
+	if current != nil && current.ID == req.ModelName {
+		return nil, false, nil
+	}
+
+	response, err := s.Load(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+	return response, true, nil
+}
+
+func (s *modelsService) LoadPreset(ctx context.Context, presetName string) (*ModelLoadResponse, error) {
+	req, err := presetLoadRequest(presetName)
+	if err != nil {
+		return nil, err
+	}
+	return s.Load(ctx, req)
+}
+
+func (s *modelsService) LoadWithProgress(ctx context.Context, req *ModelLoadRequest, onProgress func(pct float64, status string)) (*ModelLoadResponse, error) {
+	stream, err := s.LoadStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var last *ModelLoadResponse
+	total := 0
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return last, fmt.Errorf("failed to load model: %w", err)
+		}
+		if update == nil {
+			continue
+		}
+		last = update
+
+		// Modules stays 0 until the server knows it, so keep the last count
+		if update.Modules > 0 {
+			total = update.Modules
+		}
+		progress := *update
+		progress.Modules = total
+		_, _, pct := progress.Progress()
+		if onProgress != nil {
+			onProgress(pct, update.Status)
+		}
+	}
+
+	if last == nil {
+		last = &ModelLoadResponse{}
+	}
+	// Drop anything cached while the load was still in progress
+	s.invalidateCache()
+	s.setCurrentModel(ctx, last)
+	return last, nil
+}
+
 func (s *modelsService) LoadStream(ctx context.Context, req *ModelLoadRequest) (ModelLoadStream, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
 	reqCopy := *req
 
 	// Construct the URL manually
@@ -831,6 +2173,7 @@ func (s *modelsService) LoadStream(ctx context.Context, req *ModelLoadRequest) (
 
 	// Send the request
 	resp, err := s.client.DoRaw(ctx, http.MethodPost, url, &reqCopy)
+	s.invalidateCache()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load model stream: %w", err)
 	}
@@ -838,7 +2181,8 @@ func (s *modelsService) LoadStream(ctx context.Context, req *ModelLoadRequest) (
 }
 
 func (s *modelsService) Unload(ctx context.Context) error {
-	err := s.client.Delete(ctx, "v1/models/current", nil)
+	err := unload(ctx, s.client, s.unloadMethod, "v1/models/current")
+	s.invalidateCache()
 	if err != nil {
 		return fmt.Errorf("failed to unload model: %w", err)
 	}
@@ -846,6 +2190,32 @@ func (s *modelsService) Unload(ctx context.Context) error {
 }
 
 func (s *modelsService) GetProps(ctx context.Context) (*ModelPropsResponse, error) {
+	cache := s.cache.forContext(ctx)
+	if props, ok := cache.getProps(); ok {
+		return props, nil
+	}
+
+	response, err := s.getProps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up the fallback for ContextLength only when the props lack it
+	if response.DefaultGenerationSettings == nil || response.DefaultGenerationSettings.NCtx == 0 {
+		current, err := s.Get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get context length fallback: %w", err)
+		}
+		if current.Parameters != nil {
+			response.maxSeqLen = current.Parameters.MaxSeqLen
+		}
+	}
+	cache.putProps(response)
+	return response, nil
+}
+
+// getProps fetches the model props without the ContextLength fallback lookup.
+func (s *modelsService) getProps(ctx context.Context) (*ModelPropsResponse, error) {
 	var response ModelPropsResponse
 	err := s.client.Get(ctx, "v1/models/props", nil, &response)
 	if err != nil {
@@ -854,7 +2224,36 @@ func (s *modelsService) GetProps(ctx context.Context) (*ModelPropsResponse, erro
 	return &response, nil
 }
 
+// defaultReadyInterval is the polling interval WaitForReady uses when given
+// none.
+const defaultReadyInterval = 500 * time.Millisecond
+
+func (s *modelsService) WaitForReady(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultReadyInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := s.getProps(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("model not ready: %w (last error: %v)", ctx.Err(), err)
+		case <-ticker.C:
+		}
+	}
+}
+
 func (s *modelsService) Download(ctx context.Context, req *DownloadRequest) (*DownloadResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
 	var response DownloadResponse
 	err := s.client.Post(ctx, "v1/models/download", req, &response)
 	if err != nil {
@@ -869,6 +2268,7 @@ func (s *modelsService) ListDraft(ctx context.Context) (*ModelList, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to list draft models: %w", err)
 	}
+	response.setKind(ModelKindDraft)
 	return &response, nil
 }
 
@@ -878,19 +2278,28 @@ func (s *modelsService) ListEmbedding(ctx context.Context) (*ModelList, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to list embedding models: %w", err)
 	}
+	response.setKind(ModelKindEmbedding)
 	return &response, nil
 }
 
 func (s *modelsService) GetEmbedding(ctx context.Context) (*ModelCard, error) {
 	var response ModelCard
 	err := s.client.Get(ctx, "v1/models/embedding/current", nil, &response)
+	if s.notLoadedErrors {
+		err = checkNotLoaded(err, response.ID == "", ErrNoEmbeddingModelLoaded)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current embedding model: %w", err)
 	}
+	response.Kind = ModelKindEmbedding
 	return &response, nil
 }
 
 func (s *modelsService) LoadEmbedding(ctx context.Context, req *EmbeddingModelLoadRequest) (*ModelLoadResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
 	var response ModelLoadResponse
 	err := s.client.Post(ctx, "v1/models/embedding/load", req, &response)
 	if err != nil {
@@ -900,7 +2309,7 @@ func (s *modelsService) LoadEmbedding(ctx context.Context, req *EmbeddingModelLo
 }
 
 func (s *modelsService) UnloadEmbedding(ctx context.Context) error {
-	err := s.client.Delete(ctx, "v1/models/embedding/current", nil)
+	err := unload(ctx, s.client, s.unloadMethod, "v1/models/embedding/current")
 	if err != nil {
 		return fmt.Errorf("failed to unload embedding model: %w", err)
 	}
@@ -909,7 +2318,11 @@ func (s *modelsService) UnloadEmbedding(ctx context.Context) error {
 
 // loraService implements the LoraService interface
 type loraService struct {
-	client *rest.Client
+	client       *rest.Client
+	unloadMethod string
+
+	// notLoadedErrors reports no active adapters as ErrNoLoraLoaded
+	notLoadedErrors bool
 }
 
 func (s *loraService) List(ctx context.Context) (*LoraList, error) {
@@ -924,6 +2337,9 @@ func (s *loraService) List(ctx context.Context) (*LoraList, error) {
 func (s *loraService) GetActive(ctx context.Context) (*LoraList, error) {
 	var response LoraList
 	err := s.client.Get(ctx, "v1/loras/active", nil, &response)
+	if s.notLoadedErrors {
+		err = checkNotLoaded(err, len(response.Data) == 0, ErrNoLoraLoaded)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active LoRAs: %w", err)
 	}
@@ -940,13 +2356,49 @@ func (s *loraService) Load(ctx context.Context, req *LoraLoadRequest) (*LoraLoad
 }
 
 func (s *loraService) Unload(ctx context.Context) error {
-	err := s.client.Delete(ctx, "v1/loras/active", nil)
+	err := unload(ctx, s.client, s.unloadMethod, "v1/loras/active")
 	if err != nil {
 		return fmt.Errorf("failed to unload LoRAs: %w", err)
 	}
 	return nil
 }
 
+func (s *loraService) Swap(ctx context.Context, req *LoraLoadRequest) (*LoraLoadResponse, error) {
+	// Snapshot the active adapters so they can be restored on failure
+	active, err := s.GetActive(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to swap LoRAs: %w", err)
+	}
+
+	if err := s.Unload(ctx); err != nil {
+		return nil, fmt.Errorf("failed to swap LoRAs: %w", err)
+	}
+
+	response, err := s.Load(ctx, req)
+	if err == nil && len(response.Failure) > 0 {
+		err = fmt.Errorf("failed to load LoRAs: %s", strings.Join(response.Failure, ", "))
+	}
+	if err == nil {
+		return response, nil
+	}
+
+	// Roll back to the previously active adapters, clearing any partial load first
+	if response != nil && len(response.Success) > 0 {
+		_ = s.Unload(ctx)
+	}
+	if len(active.Data) > 0 {
+		previous := &LoraLoadRequest{SkipQueue: req.SkipQueue}
+		for _, card := range active.Data {
+			previous.Loras = append(previous.Loras, LoraLoadInfo{Name: card.ID, Scaling: card.Scaling})
+		}
+		if _, rollbackErr := s.Load(ctx, previous); rollbackErr != nil {
+			return response, fmt.Errorf("failed to swap LoRAs: %w (rollback failed: %v)", err, rollbackErr)
+		}
+	}
+
+	return response, fmt.Errorf("failed to swap LoRAs: %w", err)
+}
+
 // tokensService implements the TokensService interface
 type tokensService struct {
 	client *rest.Client
@@ -970,9 +2422,27 @@ func (s *tokensService) Decode(ctx context.Context, req *TokenDecodeRequest) (*T
 	return &response, nil
 }
 
+func (s *tokensService) Inspect(ctx context.Context, text string) ([]TokenInfo, error) {
+	encoded, err := s.Encode(ctx, &TokenEncodeRequest{Text: text})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]TokenInfo, len(encoded.Tokens))
+	for i, id := range encoded.Tokens {
+		decoded, err := s.Decode(ctx, &TokenDecodeRequest{Tokens: []int{id}, DecodeSpecialTokens: true})
+		if err != nil {
+			return nil, err
+		}
+		tokens[i] = TokenInfo{ID: id, Text: decoded.Text}
+	}
+	return tokens, nil
+}
+
 // templatesService implements the TemplatesService interface
 type templatesService struct {
-	client *rest.Client
+	client       *rest.Client
+	unloadMethod string
 }
 
 func (s *templatesService) List(ctx context.Context) (*TemplateList, error) {
@@ -993,7 +2463,7 @@ func (s *templatesService) Switch(ctx context.Context, req *TemplateSwitchReques
 }
 
 func (s *templatesService) Unload(ctx context.Context) error {
-	err := s.client.Delete(ctx, "v1/templates/active", nil)
+	err := unload(ctx, s.client, s.unloadMethod, "v1/templates/active")
 	if err != nil {
 		return fmt.Errorf("failed to unload template: %w", err)
 	}
@@ -1002,7 +2472,8 @@ func (s *templatesService) Unload(ctx context.Context) error {
 
 // samplingService implements the SamplingService interface
 type samplingService struct {
-	client *rest.Client
+	client       *rest.Client
+	unloadMethod string
 }
 
 func (s *samplingService) ListOverrides(ctx context.Context) (*SamplerOverrideListResponse, error) {
@@ -1014,6 +2485,14 @@ func (s *samplingService) ListOverrides(ctx context.Context) (*SamplerOverrideLi
 	return &response, nil
 }
 
+func (s *samplingService) GetActiveOverride(ctx context.Context) (map[string]interface{}, string, error) {
+	response, err := s.ListOverrides(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return response.Overrides, response.SelectedPreset, nil
+}
+
 func (s *samplingService) SwitchOverride(ctx context.Context, req *SamplerOverrideSwitchRequest) error {
 	err := s.client.Post(ctx, "v1/sampler/overrides/switch", req, nil)
 	if err != nil {
@@ -1023,7 +2502,7 @@ func (s *samplingService) SwitchOverride(ctx context.Context, req *SamplerOverri
 }
 
 func (s *samplingService) UnloadOverride(ctx context.Context) error {
-	err := s.client.Delete(ctx, "v1/sampler/overrides/active", nil)
+	err := unload(ctx, s.client, s.unloadMethod, "v1/sampler/overrides/active")
 	if err != nil {
 		return fmt.Errorf("failed to unload sampler override: %w", err)
 	}
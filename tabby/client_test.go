@@ -0,0 +1,1271 @@
+package tabby
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient creates a client pointed at a test server running the given handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc, options ...Option) Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewClient(append([]Option{WithBaseURL(server.URL)}, options...)...)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestLoraService_Swap_RollsBackOnFailure(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		loadCalls []LoraLoadRequest
+		unloads   int
+	)
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/loras/active":
+			writeJSON(w, http.StatusOK, LoraList{
+				Object: "list",
+				Data:   []LoraCard{{ID: "previous-lora", Scaling: 0.5}},
+			})
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1/loras/active":
+			unloads++
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/loras/load":
+			var req LoraLoadRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("Failed to decode load request: %v", err)
+			}
+			loadCalls = append(loadCalls, req)
+			if len(loadCalls) == 1 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"message": "lora not found"})
+				return
+			}
+			writeJSON(w, http.StatusOK, LoraLoadResponse{Success: []string{"previous-lora"}})
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	_, err := client.Lora().Swap(context.Background(), &LoraLoadRequest{
+		Loras: []LoraLoadInfo{{Name: "missing-lora", Scaling: 1.0}},
+	})
+	if err == nil {
+		t.Fatal("Expected an error from a failed swap, got nil")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if unloads != 1 {
+		t.Errorf("Expected 1 unload, got %d", unloads)
+	}
+	if len(loadCalls) != 2 {
+		t.Fatalf("Expected 2 load calls (swap and rollback), got %d", len(loadCalls))
+	}
+
+	rollback := loadCalls[1]
+	if len(rollback.Loras) != 1 || rollback.Loras[0].Name != "previous-lora" || rollback.Loras[0].Scaling != 0.5 {
+		t.Errorf("Expected rollback to reload previous-lora at 0.5, got %+v", rollback.Loras)
+	}
+}
+
+func TestLoraService_Swap_Success(t *testing.T) {
+	loads := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/loras/active":
+			writeJSON(w, http.StatusOK, LoraList{Object: "list"})
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/loras/load":
+			loads++
+			writeJSON(w, http.StatusOK, LoraLoadResponse{Success: []string{"new-lora"}})
+		}
+	})
+
+	resp, err := client.Lora().Swap(context.Background(), &LoraLoadRequest{
+		Loras: []LoraLoadInfo{{Name: "new-lora"}},
+	})
+	if err != nil {
+		t.Fatalf("Swap returned an error: %v", err)
+	}
+	if loads != 1 {
+		t.Errorf("Expected 1 load call, got %d", loads)
+	}
+	if len(resp.Success) != 1 || resp.Success[0] != "new-lora" {
+		t.Errorf("Expected success [new-lora], got %v", resp.Success)
+	}
+}
+
+func TestGenerationServices_StreamOptionsRequireStream(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to be sent")
+	})
+	opts := &StreamOptions{IncludeUsage: true}
+
+	var validationErr *ValidationError
+	_, err := client.Completions().Create(context.Background(), &CompletionRequest{Prompt: "hi", StreamOptions: opts})
+	if !errors.As(err, &validationErr) || validationErr.Field != "stream_options" {
+		t.Errorf("Expected stream_options validation error from completions, got %v", err)
+	}
+	_, err = client.Chat().Create(context.Background(), &ChatCompletionRequest{StreamOptions: opts})
+	if !errors.As(err, &validationErr) || validationErr.Field != "stream_options" {
+		t.Errorf("Expected stream_options validation error from chat, got %v", err)
+	}
+}
+
+func TestEmbeddingsService_CreateOne(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req EmbeddingsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if req.Input != "hello world" {
+			t.Errorf("Expected input %q, got %v", "hello world", req.Input)
+		}
+		writeJSON(w, http.StatusOK, EmbeddingsResponse{
+			Object: "list",
+			Data:   []EmbeddingObject{{Object: "embedding", Embedding: []float64{0.1, 0.2, 0.3}}},
+		})
+	})
+
+	vec, err := client.Embeddings().CreateOne(context.Background(), "hello world")
+	if err != nil {
+		t.Fatalf("CreateOne returned an error: %v", err)
+	}
+
+	want := []float32{0.1, 0.2, 0.3}
+	if len(vec) != len(want) {
+		t.Fatalf("Expected %d dimensions, got %d", len(want), len(vec))
+	}
+	for i := range want {
+		if vec[i] != want[i] {
+			t.Errorf("Dimension %d: expected %v, got %v", i, want[i], vec[i])
+		}
+	}
+}
+
+func TestEmbeddingsService_CreateOne_NoEmbeddings(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, EmbeddingsResponse{Object: "list"})
+	})
+
+	if _, err := client.Embeddings().CreateOne(context.Background(), "hello"); err == nil {
+		t.Fatal("Expected an error when no embeddings are returned, got nil")
+	}
+}
+
+func TestEmbeddingsService_Create_NoEmbeddingModelLoaded(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"detail": "No embedding models are currently loaded.",
+		})
+	})
+
+	_, err := client.Embeddings().Create(context.Background(), &EmbeddingsRequest{Input: "hello"})
+	if !errors.Is(err, ErrNoEmbeddingModelLoaded) {
+		t.Fatalf("Expected ErrNoEmbeddingModelLoaded, got %v", err)
+	}
+}
+
+func TestEmbeddingsService_Create_RejectsEmptyInput(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to be sent for empty input")
+	})
+
+	_, err := client.Embeddings().Create(context.Background(), &EmbeddingsRequest{Input: ""})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+}
+
+func TestChatService_Create_RejectsConflictingTokenFilters(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to be sent for conflicting token filters")
+	})
+
+	_, err := client.Chat().Create(context.Background(), &ChatCompletionRequest{
+		AllowedTokens: []int{5},
+		BannedTokens:  []int{5},
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+}
+
+func TestEmbeddingsService_Create_AutoLoadModel(t *testing.T) {
+	var loaded string
+	var embedCalls int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/embedding/load":
+			var req EmbeddingModelLoadRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("Failed to decode load request: %v", err)
+			}
+			loaded = req.EmbeddingModelName
+			writeJSON(w, http.StatusOK, ModelLoadResponse{Status: "finished"})
+		case "/v1/embeddings":
+			embedCalls++
+			if loaded == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{
+					"detail": "No embedding models are currently loaded.",
+				})
+				return
+			}
+			writeJSON(w, http.StatusOK, EmbeddingsResponse{Object: "list", Data: []EmbeddingObject{{Embedding: []float32{1, 0}}}})
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}, WithAdminKey("admin"), WithAutoLoadEmbeddingModel("nomic-embed"))
+
+	resp, err := client.Embeddings().Create(context.Background(), &EmbeddingsRequest{Input: "hello"})
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if loaded != "nomic-embed" {
+		t.Errorf("Expected nomic-embed to be loaded, got %q", loaded)
+	}
+	if embedCalls != 2 || len(resp.Data) != 1 {
+		t.Errorf("Expected one retry after loading, got %d calls and %+v", embedCalls, resp)
+	}
+}
+
+func TestModelsService_ListAll(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("cursor") == "" {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"object": "list",
+				"data":   []ModelCard{{ID: "model-a"}},
+				"next":   "page2",
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, ModelList{Object: "list", Data: []ModelCard{{ID: "model-b"}}})
+	})
+
+	models, err := client.Models().ListAll(context.Background())
+	if err != nil {
+		t.Fatalf("ListAll returned an error: %v", err)
+	}
+	if len(models) != 2 || models[0].ID != "model-a" || models[1].ID != "model-b" {
+		t.Errorf("Expected [model-a model-b], got %+v", models)
+	}
+}
+
+func TestModelsService_ListKind(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ModelList{Object: "list", Data: []ModelCard{{ID: "model-a"}, {ID: "model-b"}}})
+	})
+
+	drafts, err := client.Models().ListDraft(context.Background())
+	if err != nil {
+		t.Fatalf("ListDraft returned an error: %v", err)
+	}
+	for _, card := range drafts.Data {
+		if card.Kind != ModelKindDraft {
+			t.Errorf("Expected draft kind for %s, got %q", card.ID, card.Kind)
+		}
+	}
+
+	models, err := client.Models().List(context.Background())
+	if err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+	for _, card := range models.Data {
+		if card.Kind != ModelKindPrimary {
+			t.Errorf("Expected primary kind for %s, got %q", card.ID, card.Kind)
+		}
+	}
+}
+
+func TestModelsService_GetCurrent(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    interface{}
+		wantID  string
+		wantErr bool
+	}{
+		{"loaded", http.StatusOK, ModelCard{ID: "model-a"}, "model-a", false},
+		{"unloaded", http.StatusBadRequest, map[string]string{"detail": "No models are currently loaded."}, "", false},
+		{"not found", http.StatusNotFound, map[string]string{"detail": "Not Found"}, "", false},
+		{"error", http.StatusUnauthorized, map[string]string{"detail": "invalid key"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.status, tt.body)
+			})
+
+			card, err := client.Models().GetCurrent(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCurrent returned an error: %v", err)
+			}
+			if tt.wantID == "" {
+				if card != nil {
+					t.Errorf("Expected nil card, got %+v", card)
+				}
+				return
+			}
+			if card == nil || card.ID != tt.wantID {
+				t.Errorf("Expected card %q, got %+v", tt.wantID, card)
+			}
+		})
+	}
+}
+
+func TestNotLoadedErrors(t *testing.T) {
+	getModel := func(c Client) error { _, err := c.Models().Get(context.Background()); return err }
+	getEmbedding := func(c Client) error { _, err := c.Models().GetEmbedding(context.Background()); return err }
+	getLoras := func(c Client) error { _, err := c.Lora().GetActive(context.Background()); return err }
+
+	tests := []struct {
+		name   string
+		call   func(Client) error
+		status int
+		body   interface{}
+		want   error
+	}{
+		{"model not found", getModel, http.StatusNotFound, map[string]string{"detail": "Not Found"}, ErrNoModelLoaded},
+		{"model empty", getModel, http.StatusOK, ModelCard{}, ErrNoModelLoaded},
+		{"model loaded", getModel, http.StatusOK, ModelCard{ID: "model-a"}, nil},
+		{"embedding not found", getEmbedding, http.StatusNotFound, map[string]string{"detail": "Not Found"}, ErrNoEmbeddingModelLoaded},
+		{"embedding empty", getEmbedding, http.StatusOK, ModelCard{}, ErrNoEmbeddingModelLoaded},
+		{"embedding loaded", getEmbedding, http.StatusOK, ModelCard{ID: "embed-a"}, nil},
+		{"lora not found", getLoras, http.StatusNotFound, map[string]string{"detail": "Not Found"}, ErrNoLoraLoaded},
+		{"lora empty", getLoras, http.StatusOK, LoraList{Object: "list"}, ErrNoLoraLoaded},
+		{"lora loaded", getLoras, http.StatusOK, LoraList{Data: []LoraCard{{ID: "lora-a"}}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.status, tt.body)
+			}, WithNotLoadedErrors(true))
+
+			err := tt.call(client)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, err)
+			}
+			if tt.status == http.StatusNotFound && ClassifyError(err) != KindNotFound {
+				t.Errorf("Expected the 404 to remain in the chain, got %v", err)
+			}
+		})
+	}
+
+	// Without the option the empty result is returned as is
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, LoraList{Object: "list"})
+	})
+	if _, err := client.Lora().GetActive(context.Background()); err != nil {
+		t.Errorf("Expected no error without WithNotLoadedErrors, got %v", err)
+	}
+}
+
+func TestModelsService_LoadEmbedding_Validation(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeJSON(w, http.StatusOK, ModelLoadResponse{Status: "finished"})
+	})
+
+	var validationErr *ValidationError
+	_, err := client.Models().LoadEmbedding(context.Background(), &EmbeddingModelLoadRequest{})
+	if !errors.As(err, &validationErr) || validationErr.Field != "embedding_model_name" {
+		t.Fatalf("Expected embedding_model_name validation error, got %v", err)
+	}
+
+	_, err = client.Models().LoadEmbedding(context.Background(), &EmbeddingModelLoadRequest{
+		EmbeddingModelName: "bge",
+		EmbeddingsDevice:   "gpu",
+	})
+	if !errors.As(err, &validationErr) || validationErr.Field != "embeddings_device" {
+		t.Fatalf("Expected embeddings_device validation error, got %v", err)
+	}
+
+	_, err = client.Models().LoadEmbedding(context.Background(), &EmbeddingModelLoadRequest{
+		EmbeddingModelName: "bge",
+		EmbeddingsDevice:   DeviceCUDA,
+	})
+	if err != nil {
+		t.Fatalf("LoadEmbedding returned an error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected only the valid request to be sent, got %d", calls)
+	}
+}
+
+func TestModelsService_Load_EchoesParameters(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/load":
+			writeJSON(w, http.StatusOK, ModelLoadResponse{Status: "finished"})
+		case "/v1/models/current":
+			writeJSON(w, http.StatusOK, ModelCard{
+				ID:         "my-model",
+				Parameters: &ModelCardParameters{MaxSeqLen: 4096, CacheMode: CacheModeQ8},
+			})
+		}
+	})
+
+	resp, err := client.Models().Load(context.Background(), &ModelLoadRequest{
+		ModelName: "my-model",
+		MaxSeqLen: 8192,
+		CacheMode: CacheModeFP16,
+	})
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	if resp.Status != "finished" {
+		t.Errorf("Expected status finished, got %q", resp.Status)
+	}
+	if resp.Model == nil || resp.Model.Parameters == nil {
+		t.Fatalf("Expected loaded model parameters, got %+v", resp.Model)
+	}
+	if params := resp.Model.Parameters; params.MaxSeqLen != 4096 || params.CacheMode != CacheModeQ8 {
+		t.Errorf("Expected effective max_seq_len 4096 and cache_mode Q8, got %+v", params)
+	}
+}
+
+func TestModelsService_LoadWithProgress(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/load":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, update := range []string{
+				`{"module":0,"modules":0,"status":"processing"}`,
+				`{"module":1,"modules":4,"status":"processing"}`,
+				`{"module":2,"modules":0,"status":"processing"}`,
+				`{"module":4,"modules":4,"status":"finished"}`,
+			} {
+				fmt.Fprintf(w, "data: %s\n\n", update)
+			}
+		case "/v1/models/current":
+			writeJSON(w, http.StatusOK, ModelCard{ID: "my-model"})
+		}
+	})
+
+	var percentages []float64
+	var statuses []string
+	resp, err := client.Models().LoadWithProgress(context.Background(), &ModelLoadRequest{ModelName: "my-model"}, func(pct float64, status string) {
+		percentages = append(percentages, pct)
+		statuses = append(statuses, status)
+	})
+	if err != nil {
+		t.Fatalf("LoadWithProgress returned an error: %v", err)
+	}
+
+	// The count is unknown at first, then remembered when an update omits it
+	want := []float64{0, 25, 50, 100}
+	if fmt.Sprint(percentages) != fmt.Sprint(want) {
+		t.Errorf("Expected percentages %v, got %v", want, percentages)
+	}
+	if statuses[len(statuses)-1] != "finished" {
+		t.Errorf("Expected a final status of finished, got %v", statuses)
+	}
+	if resp.Status != "finished" || resp.Model == nil || resp.Model.ID != "my-model" {
+		t.Errorf("Expected the final update with the loaded model, got %+v", resp)
+	}
+}
+
+func TestModelsService_LoadIfNeeded(t *testing.T) {
+	tests := []struct {
+		name       string
+		currentID  string
+		wantLoaded bool
+	}{
+		{"already loaded", "my-model", false},
+		{"different model loaded", "other-model", true},
+		{"no model loaded", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loads := 0
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/models/current":
+					if tt.currentID == "" {
+						writeJSON(w, http.StatusNotFound, map[string]string{"message": "no model loaded"})
+						return
+					}
+					writeJSON(w, http.StatusOK, ModelCard{ID: tt.currentID})
+				case "/v1/models/load":
+					loads++
+					writeJSON(w, http.StatusOK, ModelLoadResponse{Status: "finished"})
+				}
+			})
+
+			resp, loaded, err := client.Models().LoadIfNeeded(context.Background(), &ModelLoadRequest{ModelName: "my-model"})
+			if err != nil {
+				t.Fatalf("LoadIfNeeded returned an error: %v", err)
+			}
+			if loaded != tt.wantLoaded {
+				t.Errorf("Expected loaded=%v, got %v", tt.wantLoaded, loaded)
+			}
+			if tt.wantLoaded && (resp == nil || loads != 1) {
+				t.Errorf("Expected one load with a response, got %d loads and response %v", loads, resp)
+			}
+			if !tt.wantLoaded && (resp != nil || loads != 0) {
+				t.Errorf("Expected no load and nil response, got %d loads and response %v", loads, resp)
+			}
+		})
+	}
+}
+
+func TestModelsService_LoadIfNeeded_LookupError(t *testing.T) {
+	loads := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/current":
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "invalid key"})
+		case "/v1/models/load":
+			loads++
+			writeJSON(w, http.StatusOK, ModelLoadResponse{Status: "finished"})
+		}
+	})
+
+	_, loaded, err := client.Models().LoadIfNeeded(context.Background(), &ModelLoadRequest{ModelName: "my-model"})
+	if ClassifyError(err) != KindAuth {
+		t.Fatalf("Expected an auth error, got %v", err)
+	}
+	if loaded || loads != 0 {
+		t.Errorf("Expected no load after a failed lookup, got loaded=%v and %d loads", loaded, loads)
+	}
+}
+
+func TestChatService_MaxTokensField(t *testing.T) {
+	tests := []struct {
+		name  string
+		field MaxTokensField
+		req   ChatCompletionRequest
+		want  map[string]bool
+	}{
+		{"legacy from max_tokens", MaxTokensFieldLegacy, ChatCompletionRequest{MaxTokens: 64}, map[string]bool{"max_tokens": true}},
+		{"legacy from max_completion_tokens", MaxTokensFieldLegacy, ChatCompletionRequest{MaxCompletionTokens: 64}, map[string]bool{"max_tokens": true}},
+		{"completion", MaxTokensFieldCompletion, ChatCompletionRequest{MaxTokens: 64}, map[string]bool{"max_completion_tokens": true}},
+		{"both", MaxTokensFieldBoth, ChatCompletionRequest{MaxTokens: 64}, map[string]bool{"max_tokens": true, "max_completion_tokens": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &chatService{maxTokensField: tt.field}
+			prepared, err := svc.prepare(&tt.req, false)
+			if err != nil {
+				t.Fatalf("Failed to prepare request: %v", err)
+			}
+			data, err := json.Marshal(prepared)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+
+			var fields map[string]interface{}
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("Failed to unmarshal request: %v", err)
+			}
+
+			for _, key := range []string{"max_tokens", "max_completion_tokens"} {
+				value, present := fields[key]
+				if present != tt.want[key] {
+					t.Errorf("Expected %s present=%v, got JSON %s", key, tt.want[key], data)
+				}
+				if present && value != float64(64) {
+					t.Errorf("Expected %s=64, got %v", key, value)
+				}
+			}
+		})
+	}
+}
+
+func TestChatService_MessageSanitizer(t *testing.T) {
+	req := &ChatCompletionRequest{Messages: []ChatMessage{
+		{Role: ChatMessageRoleUser, Content: "Hello"},
+		{Role: ChatMessageRoleUser, Content: "Again"},
+	}}
+
+	if got, _ := (&chatService{}).prepare(req, false); len(got.Messages) != 2 {
+		t.Errorf("Expected messages untouched without sanitizer, got %+v", got.Messages)
+	}
+
+	got, _ := (&chatService{sanitizeMessages: true}).prepare(req, false)
+	if len(got.Messages) != 1 || got.Messages[0].Content != "Hello\nAgain" {
+		t.Errorf("Expected one merged message, got %+v", got.Messages)
+	}
+	if len(req.Messages) != 2 {
+		t.Errorf("Expected caller's request to be unchanged, got %+v", req.Messages)
+	}
+
+	withEmpty := &ChatCompletionRequest{Messages: []ChatMessage{
+		{Role: ChatMessageRoleSystem, Content: ""},
+		{Role: ChatMessageRoleUser, Content: "Hello"},
+	}}
+	if got, _ := (&chatService{sanitizeMessages: true}).prepare(withEmpty, false); len(got.Messages) != 2 {
+		t.Errorf("Expected the empty message kept by default, got %+v", got.Messages)
+	}
+	got, _ = (&chatService{sanitizeMessages: true, dropEmptyMessages: true}).prepare(withEmpty, false)
+	if len(got.Messages) != 1 || got.Messages[0].Content != "Hello" {
+		t.Errorf("Expected the empty message dropped, got %+v", got.Messages)
+	}
+}
+
+func TestMarshal_MatchesWireBody(t *testing.T) {
+	var body []byte
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			t.Errorf("Failed to read request body: %v", err)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"object": "list"})
+	}, WithMaxTokensField(MaxTokensFieldBoth), WithMessageSanitizer(true))
+
+	ctx := context.Background()
+	chatReq := &ChatCompletionRequest{
+		Messages: []ChatMessage{
+			{Role: ChatMessageRoleUser, Content: "Hello"},
+			{Role: ChatMessageRoleUser, Content: "Again"},
+		},
+		MaxTokens: 32,
+		Stream:    true,
+	}
+	completionReq := &CompletionRequest{Prompt: "Once upon a time", MaxTokens: 16, Temperature: Float64(0)}
+	embeddingsReq := &EmbeddingsRequest{Input: []string{"a", "b"}}
+
+	tests := []struct {
+		name    string
+		marshal func() ([]byte, error)
+		send    func() error
+	}{
+		{"chat", func() ([]byte, error) {
+			return client.Chat().Marshal(&ChatCompletionRequest{Messages: chatReq.Messages, MaxTokens: 32})
+		}, func() error {
+			_, err := client.Chat().Create(ctx, chatReq)
+			return err
+		}},
+		{"completions", func() ([]byte, error) { return client.Completions().Marshal(completionReq) }, func() error {
+			_, err := client.Completions().Create(ctx, completionReq)
+			return err
+		}},
+		{"embeddings", func() ([]byte, error) { return client.Embeddings().Marshal(embeddingsReq) }, func() error {
+			_, err := client.Embeddings().Create(ctx, embeddingsReq)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := tt.marshal()
+			if err != nil {
+				t.Fatalf("Marshal returned an error: %v", err)
+			}
+			if err := tt.send(); err != nil {
+				t.Fatalf("Request returned an error: %v", err)
+			}
+			if string(body) != string(want) {
+				t.Errorf("Marshal output differs from the wire body:\nmarshal: %s\nwire:    %s", want, body)
+			}
+		})
+	}
+}
+
+func TestChatService_EnforceSystemFirst(t *testing.T) {
+	var got []ChatMessage
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		got = req.Messages
+		writeJSON(w, http.StatusOK, ChatCompletionResponse{})
+	}, WithEnforceSystemFirst(true))
+
+	req := &ChatCompletionRequest{Messages: []ChatMessage{
+		{Role: ChatMessageRoleUser, Content: "Hello"},
+		{Role: ChatMessageRoleSystem, Content: "Be brief."},
+	}}
+	if _, err := client.Chat().Create(context.Background(), req); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if len(got) != 2 || got[0].Role != ChatMessageRoleSystem || got[1].Content != "Hello" {
+		t.Errorf("Expected system message first, got %+v", got)
+	}
+	if req.Messages[0].Role != ChatMessageRoleUser {
+		t.Errorf("Expected caller's request to be unchanged, got %+v", req.Messages)
+	}
+
+	req.Messages = append(req.Messages, ChatMessage{Role: ChatMessageRoleSystem, Content: "Be kind."})
+	_, err := client.Chat().CreateStream(context.Background(), req)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "messages" {
+		t.Errorf("Expected messages ValidationError for two system messages, got %v", err)
+	}
+}
+
+func TestCompletionsService_Create_ArrayPrompt(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Prompt []string `json:"prompt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Expected prompt to be sent as an array: %v", err)
+		}
+		choices := make([]CompletionRespChoice, len(req.Prompt))
+		for i, prompt := range req.Prompt {
+			choices[i] = CompletionRespChoice{Index: i, Text: prompt + " done"}
+		}
+		writeJSON(w, http.StatusOK, CompletionResponse{Choices: choices})
+	})
+
+	resp, err := client.Completions().Create(context.Background(), &CompletionRequest{
+		Prompt: []string{"first", "second"},
+	})
+	if err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+
+	groups := resp.ByPromptIndex()
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 prompt groups, got %d", len(groups))
+	}
+	if got := groups[0][0].Text; got != "first done" {
+		t.Errorf("Expected prompt 0 text %q, got %q", "first done", got)
+	}
+	if got := groups[1][0].Text; got != "second done" {
+		t.Errorf("Expected prompt 1 text %q, got %q", "second done", got)
+	}
+}
+
+func TestCreateTimed_ReturnsDuration(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if r.URL.Path == "/v1/chat/completions" {
+			writeJSON(w, http.StatusOK, ChatCompletionResponse{ID: "chat"})
+			return
+		}
+		writeJSON(w, http.StatusOK, CompletionResponse{ID: "cmpl"})
+	})
+
+	resp, elapsed, err := client.Completions().CreateTimed(context.Background(), &CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("CreateTimed returned an error: %v", err)
+	}
+	if resp.ID != "cmpl" || elapsed < delay {
+		t.Errorf("Expected completion after at least %v, got %q in %v", delay, resp.ID, elapsed)
+	}
+
+	chatResp, elapsed, err := client.Chat().CreateTimed(context.Background(), &ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("CreateTimed returned an error: %v", err)
+	}
+	if chatResp.ID != "chat" || elapsed < delay {
+		t.Errorf("Expected chat completion after at least %v, got %q in %v", delay, chatResp.ID, elapsed)
+	}
+}
+
+func TestChatService_CreateStreamCallback(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"c1","model":"m","choices":[{"index":0,"delta":{"role":"assistant"}}]}`,
+			`{"id":"c1","model":"m","choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+			`{"id":"c1","model":"m","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+			`{"id":"c1","model":"m","choices":[{"index":0,"delta":{"content":"!"},"finish_reason":"stop"}]}`,
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	})
+
+	var deltas []string
+	resp, err := client.Chat().CreateStreamCallback(context.Background(), &ChatCompletionRequest{}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("CreateStreamCallback returned an error: %v", err)
+	}
+
+	if strings.Join(deltas, "|") != "Hel|lo|!" {
+		t.Errorf("Expected deltas Hel|lo|!, got %q", deltas)
+	}
+	if resp.ID != "c1" || len(resp.Choices) != 1 {
+		t.Fatalf("Unexpected response: %+v", resp)
+	}
+	choice := resp.Choices[0]
+	if choice.Message.Content != "Hello!" || choice.Message.Role != ChatMessageRoleAssistant || choice.FinishReason != "stop" {
+		t.Errorf("Unexpected assembled choice: %+v", choice)
+	}
+}
+
+func TestChatService_CreateStreamCallback_Abort(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"x\"}}]}\n\n")
+		}
+	})
+
+	errStop := errors.New("stop")
+	calls := 0
+	_, err := client.Chat().CreateStreamCallback(context.Background(), &ChatCompletionRequest{}, func(delta string) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Expected callback error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 callback before aborting, got %d", calls)
+	}
+}
+
+func TestChatService_Continue(t *testing.T) {
+	parts := []string{"The quick", " brown fox", " jumps."}
+	calls := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if calls > 0 {
+			last := req.Messages[len(req.Messages)-1]
+			if last.Role != ChatMessageRoleAssistant || last.Content != strings.Join(parts[:calls], "") {
+				t.Errorf("Expected the partial assistant message last, got %+v", last)
+			}
+			if req.AddGenerationPrompt == nil || *req.AddGenerationPrompt {
+				t.Error("Expected add_generation_prompt to be false on a continuation")
+			}
+		}
+
+		reason := FinishReasonLength
+		if calls == len(parts)-1 {
+			reason = FinishReasonStop
+		}
+		writeJSON(w, http.StatusOK, ChatCompletionResponse{Choices: []ChatCompletionRespChoice{{
+			Message:      ChatMessage{Role: ChatMessageRoleAssistant, Content: parts[calls]},
+			FinishReason: reason,
+		}}})
+		calls++
+	})
+
+	ctx := context.Background()
+	req := &ChatCompletionRequest{Messages: []ChatMessage{{Role: ChatMessageRoleUser, Content: "Finish the pangram."}}}
+	resp, err := client.Chat().Create(ctx, req)
+	if err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+	for resp.NeedsContinuation() {
+		if resp, err = client.Chat().Continue(ctx, req, resp); err != nil {
+			t.Fatalf("Continue returned an error: %v", err)
+		}
+	}
+
+	if msg, _ := resp.FirstMessage(); msg.Content != "The quick brown fox jumps." {
+		t.Errorf("Expected the continued text, got %q", msg.Content)
+	}
+	if calls != 3 || len(req.Messages) != 1 {
+		t.Errorf("Expected 3 calls and an unchanged request, got %d calls and %+v", calls, req.Messages)
+	}
+}
+
+func TestChatService_CountPromptTokens(t *testing.T) {
+	var sent ChatCompletionRequest
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		writeJSON(w, http.StatusOK, ChatCompletionResponse{
+			Usage: &UsageStats{PromptTokens: 42, CompletionTokens: 1, TotalTokens: 43},
+		})
+	})
+
+	req := &ChatCompletionRequest{
+		Messages:  []ChatMessage{{Role: ChatMessageRoleUser, Content: "How long is this?"}},
+		MaxTokens: 500,
+	}
+	count, err := client.Chat().CountPromptTokens(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CountPromptTokens returned an error: %v", err)
+	}
+	if count != 42 {
+		t.Errorf("Expected 42 prompt tokens, got %d", count)
+	}
+	if sent.MaxTokens != 1 || len(sent.Messages) != 1 {
+		t.Errorf("Expected the messages with a one-token limit, got %+v", sent)
+	}
+	if req.MaxTokens != 500 {
+		t.Errorf("Expected the caller's request to be unchanged, got MaxTokens %d", req.MaxTokens)
+	}
+}
+
+func TestChatService_CountPromptTokens_NoUsage(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ChatCompletionResponse{})
+	})
+
+	if _, err := client.Chat().CountPromptTokens(context.Background(), &ChatCompletionRequest{}); err == nil {
+		t.Error("Expected an error when the server reports no usage, got nil")
+	}
+}
+
+func TestTokensService_Inspect(t *testing.T) {
+	vocab := map[int]string{1: "<s>", 9906: "Hello", 1917: " world"}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/tokens/encode":
+			writeJSON(w, http.StatusOK, TokenEncodeResponse{Tokens: []int{1, 9906, 1917}, Length: 3})
+		case "/v1/tokens/decode":
+			var req TokenDecodeRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Tokens) != 1 {
+				t.Errorf("Expected a single token to decode, got %+v (%v)", req, err)
+			}
+			writeJSON(w, http.StatusOK, TokenDecodeResponse{Text: vocab[req.Tokens[0]]})
+		}
+	})
+
+	tokens, err := client.Tokens().Inspect(context.Background(), "Hello world")
+	if err != nil {
+		t.Fatalf("Inspect returned an error: %v", err)
+	}
+
+	got := fmt.Sprint(tokens)
+	want := `[1:"<s>" 9906:"Hello" 1917:" world"]`
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestClient_Generate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content interface{}
+	}{
+		{"string content", "Hello!"},
+		{"structured content", []ChatMessageContent{{Type: "text", Text: "Hel"}, {Type: "text", Text: "lo!"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, http.StatusOK, ChatCompletionResponse{
+					Choices: []ChatCompletionRespChoice{{Message: ChatMessage{Role: ChatMessageRoleAssistant, Content: tt.content}}},
+				})
+			})
+
+			text, err := client.Generate(context.Background(), &ChatCompletionRequest{})
+			if err != nil {
+				t.Fatalf("Generate returned an error: %v", err)
+			}
+			if text != "Hello!" {
+				t.Errorf("Expected %q, got %q", "Hello!", text)
+			}
+		})
+	}
+}
+
+func TestClient_GenerateStream(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"Hel", "lo", "!"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", delta)
+		}
+	})
+
+	var deltas []string
+	text, err := client.GenerateStream(context.Background(), &ChatCompletionRequest{}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateStream returned an error: %v", err)
+	}
+	if text != "Hello!" {
+		t.Errorf("Expected %q, got %q", "Hello!", text)
+	}
+	if len(deltas) != 3 {
+		t.Errorf("Expected 3 deltas, got %q", deltas)
+	}
+}
+
+func TestCreateRaw_SendsBodyVerbatim(t *testing.T) {
+	body := json.RawMessage("{\n  \"prompt\": \"Hello\",  \"max_tokens\": 5\n}")
+
+	var received []byte
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read request body: %v", err)
+		}
+		received = data
+
+		switch r.URL.Path {
+		case "/v1/completions":
+			writeJSON(w, http.StatusOK, CompletionResponse{ID: "cmpl-1", Choices: []CompletionRespChoice{{Text: "world"}}})
+		case "/v1/chat/completions":
+			writeJSON(w, http.StatusOK, ChatCompletionResponse{ID: "chat-1"})
+		}
+	}, WithAPIKey("secret"))
+
+	resp, err := client.Completions().CreateRaw(context.Background(), body)
+	if err != nil {
+		t.Fatalf("CreateRaw returned an error: %v", err)
+	}
+	if string(received) != string(body) {
+		t.Errorf("Expected body sent verbatim:\n%s\ngot:\n%s", body, received)
+	}
+	if resp.ID != "cmpl-1" || resp.Choices[0].Text != "world" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+
+	chatBody := json.RawMessage(`{"messages": [{"role": "user", "content": "hi"}]}`)
+	chatResp, err := client.Chat().CreateRaw(context.Background(), chatBody)
+	if err != nil {
+		t.Fatalf("Chat CreateRaw returned an error: %v", err)
+	}
+	if string(received) != string(chatBody) {
+		t.Errorf("Expected chat body sent verbatim, got %s", received)
+	}
+	if chatResp.ID != "chat-1" {
+		t.Errorf("Unexpected chat response: %+v", chatResp)
+	}
+}
+
+func TestSamplingService_GetActiveOverride(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sampler/overrides" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"selected_preset": "creative",
+			"overrides": {"temperature": {"override": 1.2, "force": false}},
+			"presets": ["creative", "precise"]
+		}`))
+	})
+
+	overrides, preset, err := client.Sampling().GetActiveOverride(context.Background())
+	if err != nil {
+		t.Fatalf("GetActiveOverride returned an error: %v", err)
+	}
+	if preset != "creative" {
+		t.Errorf("Expected preset %q, got %q", "creative", preset)
+	}
+	if _, ok := overrides["temperature"]; !ok || len(overrides) != 1 {
+		t.Errorf("Expected only a temperature override, got %v", overrides)
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	t.Setenv(EnvEndpoint, "http://tabby.example:5000")
+	t.Setenv(EnvAPIKey, "")
+	t.Setenv(EnvAdminKey, "admin-secret")
+	t.Setenv(EnvTimeout, "90s")
+
+	c, ok := NewClientFromEnv().(*clientImpl)
+	if !ok {
+		t.Fatal("Expected *clientImpl")
+	}
+	if c.baseURL != "http://tabby.example:5000" {
+		t.Errorf("Expected base URL from env, got %s", c.baseURL)
+	}
+	if a, ok := c.auth.(*AdminKeyAuthenticator); !ok || a.Key != "admin-secret" {
+		t.Errorf("Expected admin key authenticator from env, got %#v", c.auth)
+	}
+	if c.httpClient.Timeout != 90*time.Second {
+		t.Errorf("Expected timeout 90s, got %v", c.httpClient.Timeout)
+	}
+}
+
+func TestNewClientFromEnv_OptionsOverrideEnv(t *testing.T) {
+	t.Setenv(EnvEndpoint, "http://from-env:5000")
+	t.Setenv(EnvAPIKey, "env-key")
+	t.Setenv(EnvAdminKey, "")
+	t.Setenv(EnvTimeout, "45")
+
+	c := NewClientFromEnv(
+		WithBaseURL("http://explicit:8080"),
+		WithTimeout(5*time.Second),
+	).(*clientImpl)
+
+	if c.baseURL != "http://explicit:8080" {
+		t.Errorf("Expected explicit base URL, got %s", c.baseURL)
+	}
+	if c.httpClient.Timeout != 5*time.Second {
+		t.Errorf("Expected explicit timeout 5s, got %v", c.httpClient.Timeout)
+	}
+	if a, ok := c.auth.(*APIKeyAuthenticator); !ok || a.Key != "env-key" {
+		t.Errorf("Expected API key authenticator from env, got %#v", c.auth)
+	}
+}
+
+func TestNewClientFromEnv_Defaults(t *testing.T) {
+	t.Setenv(EnvEndpoint, "")
+	t.Setenv(EnvAPIKey, "")
+	t.Setenv(EnvAdminKey, "")
+	t.Setenv(EnvTimeout, "not-a-duration")
+
+	c := NewClientFromEnv().(*clientImpl)
+	if c.baseURL != "http://localhost:8080" {
+		t.Errorf("Expected default base URL, got %s", c.baseURL)
+	}
+	if c.auth != nil {
+		t.Errorf("Expected no authenticator, got %#v", c.auth)
+	}
+	if c.httpClient.Timeout != 30*time.Second {
+		t.Errorf("Expected default timeout, got %v", c.httpClient.Timeout)
+	}
+}
+
+func TestModelsService_GetProps_ContextLengthFallback(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/props":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"total_slots": 1})
+		case "/v1/models/current":
+			writeJSON(w, http.StatusOK, ModelCard{ID: "model", Parameters: &ModelCardParameters{MaxSeqLen: 8192}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	props, err := client.Models().GetProps(context.Background())
+	if err != nil {
+		t.Fatalf("GetProps returned an error: %v", err)
+	}
+	if got := props.ContextLength(); got != 8192 {
+		t.Errorf("Expected fallback context length 8192, got %d", got)
+	}
+}
+
+func TestModelsService_GetProps_FallbackError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/props":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"total_slots": 1})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "boom"})
+		}
+	})
+
+	if _, err := client.Models().GetProps(context.Background()); ClassifyError(err) != KindServer {
+		t.Fatalf("Expected the fallback lookup's server error, got %v", err)
+	}
+}
+
+func TestModelsService_WaitForReady(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "model loading"})
+			return
+		}
+		writeJSON(w, http.StatusOK, ModelPropsResponse{TotalSlots: 1})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := client.Models().WaitForReady(ctx, 5*time.Millisecond); err != nil {
+		t.Fatalf("WaitForReady returned an error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 props calls, got %d", calls)
+	}
+}
+
+func TestModelsService_WaitForReady_ZeroInterval(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ModelPropsResponse{TotalSlots: 1})
+	})
+
+	if err := client.Models().WaitForReady(context.Background(), 0); err != nil {
+		t.Fatalf("WaitForReady returned an error: %v", err)
+	}
+}
+
+func TestModelsService_WaitForReady_ContextExpires(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "model loading"})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	err := client.Models().WaitForReady(ctx, 5*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestClient_Close_CancelsInFlightRequest(t *testing.T) {
+	started := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Models().Get(context.Background())
+		errCh <- err
+	}()
+
+	<-started
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Request was not canceled by Close")
+	}
+
+	if _, err := client.Models().Get(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled after Close, got %v", err)
+	}
+}
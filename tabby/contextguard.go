@@ -0,0 +1,133 @@
+package tabby
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// contextGuardCacheTTL is how long WithContextGuard caches the model's
+// context length.
+const contextGuardCacheTTL = time.Minute
+
+// contextGuard fits generation requests into the loaded model's context
+// window; see WithContextGuard. A nil guard leaves requests unchanged.
+type contextGuard struct {
+	models *modelsService
+	tokens *tokensService
+}
+
+// fitCompletion clamps req.MaxTokens to the context left after its prompt.
+// An array prompt is generated per entry, so the longest entry decides,
+// except that a role-tagged prompt is a single prompt, as is an array of
+// token IDs, whether a []int or a []interface{} of numbers decoded from JSON.
+func (g *contextGuard) fitCompletion(ctx context.Context, req *CompletionRequest) error {
+	if g == nil {
+		return nil
+	}
+
+	var prompts []interface{}
+	switch prompt := req.Prompt.(type) {
+	case []string:
+		for _, text := range prompt {
+			prompts = append(prompts, text)
+		}
+	case [][]int:
+		for _, ids := range prompt {
+			prompts = append(prompts, ids)
+		}
+	case []interface{}:
+		prompts = prompt
+		if isTokenIDs(prompt) {
+			prompts = []interface{}{prompt}
+		} else if len(prompt) > 0 {
+			if _, ok := prompt[0].(map[string]interface{}); ok {
+				prompts = []interface{}{prompt}
+			}
+		}
+	default:
+		prompts = []interface{}{prompt}
+	}
+
+	limit, err := g.fit(ctx, prompts, req.MaxTokens)
+	if err != nil {
+		return err
+	}
+	req.MaxTokens = limit
+	return nil
+}
+
+// fitChat clamps whichever of req.MaxTokens and req.MaxCompletionTokens are
+// set to the context left after its messages.
+func (g *contextGuard) fitChat(ctx context.Context, req *ChatCompletionRequest) error {
+	if g == nil {
+		return nil
+	}
+
+	limit, err := g.fit(ctx, []interface{}{req.Messages}, max(req.MaxTokens, req.MaxCompletionTokens))
+	if err != nil {
+		return err
+	}
+	if req.MaxTokens != 0 {
+		req.MaxTokens = limit
+	}
+	if req.MaxCompletionTokens != 0 {
+		req.MaxCompletionTokens = limit
+	}
+	return nil
+}
+
+// fit returns maxTokens clamped to the context remaining after the longest
+// of prompts, or an error wrapping ErrContextLengthExceeded if that prompt
+// alone fills the context. A zero maxTokens, meaning the server default, is
+// returned unchanged, as is any limit when the context length is unknown.
+func (g *contextGuard) fit(ctx context.Context, prompts []interface{}, maxTokens int) (int, error) {
+	props, err := g.models.GetProps(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check context length: %w", err)
+	}
+	contextLength := props.ContextLength()
+	if contextLength <= 0 {
+		return maxTokens, nil
+	}
+
+	used := 0
+	for _, prompt := range prompts {
+		n, err := g.count(ctx, prompt)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check context length: %w", err)
+		}
+		used = max(used, n)
+	}
+
+	remaining := contextLength - used
+	if remaining <= 0 {
+		return 0, fmt.Errorf("%w: prompt uses %d of %d tokens", ErrContextLengthExceeded, used, contextLength)
+	}
+	if maxTokens > remaining {
+		return remaining, nil
+	}
+	return maxTokens, nil
+}
+
+// count returns the number of tokens in prompt. A prompt of token IDs is
+// already tokenized, so only other prompts cost an encode call.
+func (g *contextGuard) count(ctx context.Context, prompt interface{}) (int, error) {
+	switch ids := prompt.(type) {
+	case []int:
+		return len(ids), nil
+	case []interface{}:
+		if isTokenIDs(ids) {
+			return len(ids), nil
+		}
+	}
+
+	encoded, err := g.tokens.Encode(ctx, &TokenEncodeRequest{Text: prompt})
+	if err != nil {
+		return 0, err
+	}
+	if encoded.Length != 0 {
+		return encoded.Length, nil
+	}
+	return len(encoded.Tokens), nil
+}
@@ -0,0 +1,171 @@
+package tabby
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestWithContextGuard(t *testing.T) {
+	var propsCalls, sentMaxTokens int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/props":
+			propsCalls++
+			writeJSON(w, http.StatusOK, ModelPropsResponse{DefaultGenerationSettings: &ModelDefaultGenerationSettings{NCtx: 100}})
+		case "/v1/tokens/encode":
+			var req TokenEncodeRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("Failed to decode encode request: %v", err)
+			}
+			length := 90
+			if req.Text == "too long" {
+				length = 120
+			}
+			writeJSON(w, http.StatusOK, TokenEncodeResponse{Length: length})
+		case "/v1/completions":
+			var req CompletionRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("Failed to decode completion request: %v", err)
+			}
+			sentMaxTokens = req.MaxTokens
+			writeJSON(w, http.StatusOK, CompletionResponse{})
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}, WithContextGuard(true))
+
+	ctx := context.Background()
+	req := &CompletionRequest{Prompt: "fits", MaxTokens: 500}
+	if _, err := client.Completions().Create(ctx, req); err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+	if sentMaxTokens != 10 {
+		t.Errorf("Expected max_tokens clamped to 10, got %d", sentMaxTokens)
+	}
+	if req.MaxTokens != 500 {
+		t.Errorf("Expected caller's request to be unchanged, got %d", req.MaxTokens)
+	}
+
+	sentMaxTokens = 0
+	_, err := client.Completions().Create(ctx, &CompletionRequest{Prompt: "too long", MaxTokens: 5})
+	if !errors.Is(err, ErrContextLengthExceeded) {
+		t.Fatalf("Expected ErrContextLengthExceeded, got %v", err)
+	}
+	if sentMaxTokens != 0 {
+		t.Error("Expected the oversized prompt not to be sent")
+	}
+	if propsCalls != 1 {
+		t.Errorf("Expected the context length to be cached, got %d props calls", propsCalls)
+	}
+}
+
+func TestWithContextGuard_PrivateCache(t *testing.T) {
+	var propsCalls int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/props":
+			propsCalls++
+			writeJSON(w, http.StatusOK, ModelPropsResponse{DefaultGenerationSettings: &ModelDefaultGenerationSettings{NCtx: 100}})
+		case "/v1/tokens/encode":
+			writeJSON(w, http.StatusOK, TokenEncodeResponse{Length: 10})
+		case "/v1/completions":
+			writeJSON(w, http.StatusOK, CompletionResponse{})
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}, WithContextGuard(true))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := client.Models().GetProps(ctx); err != nil {
+			t.Fatalf("GetProps returned an error: %v", err)
+		}
+	}
+	if propsCalls != 2 {
+		t.Errorf("Expected GetProps not to be cached by the guard, got %d props calls", propsCalls)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Completions().Create(ctx, &CompletionRequest{Prompt: "hi", MaxTokens: 5}); err != nil {
+			t.Fatalf("Create returned an error: %v", err)
+		}
+	}
+	if propsCalls != 3 {
+		t.Errorf("Expected the guard to cache the context length, got %d props calls", propsCalls)
+	}
+}
+
+func TestWithContextGuard_TokenIDPrompt(t *testing.T) {
+	var sentMaxTokens int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/props":
+			writeJSON(w, http.StatusOK, ModelPropsResponse{DefaultGenerationSettings: &ModelDefaultGenerationSettings{NCtx: 10}})
+		case "/v1/completions":
+			var req CompletionRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("Failed to decode completion request: %v", err)
+			}
+			sentMaxTokens = req.MaxTokens
+			writeJSON(w, http.StatusOK, CompletionResponse{})
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}, WithContextGuard(true))
+
+	req := &CompletionRequest{Prompt: []int{1, 2, 3, 4, 5, 6}, MaxTokens: 100}
+	if _, err := client.Completions().Create(context.Background(), req); err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+	if sentMaxTokens != 4 {
+		t.Errorf("Expected max_tokens clamped to 4, got %d", sentMaxTokens)
+	}
+}
+
+func TestWithContextGuard_TokenIDForms(t *testing.T) {
+	var decoded []interface{}
+	if err := json.Unmarshal([]byte(`[1, 2, 3, 4, 5, 6]`), &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		prompt interface{}
+		want   int
+	}{
+		{"decoded from JSON", decoded, 4},
+		{"batch of token IDs", [][]int{{1, 2}, {1, 2, 3, 4, 5, 6, 7}}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sentMaxTokens int
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/models/props":
+					writeJSON(w, http.StatusOK, ModelPropsResponse{DefaultGenerationSettings: &ModelDefaultGenerationSettings{NCtx: 10}})
+				case "/v1/completions":
+					var req CompletionRequest
+					if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+						t.Errorf("Failed to decode completion request: %v", err)
+					}
+					sentMaxTokens = req.MaxTokens
+					writeJSON(w, http.StatusOK, CompletionResponse{})
+				default:
+					t.Errorf("Unexpected request to %s", r.URL.Path)
+				}
+			}, WithContextGuard(true))
+
+			req := &CompletionRequest{Prompt: tt.prompt, MaxTokens: 100}
+			if _, err := client.Completions().Create(context.Background(), req); err != nil {
+				t.Fatalf("Create returned an error: %v", err)
+			}
+			if sentMaxTokens != tt.want {
+				t.Errorf("Expected max_tokens clamped to %d, got %d", tt.want, sentMaxTokens)
+			}
+		})
+	}
+}
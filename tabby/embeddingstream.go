@@ -0,0 +1,112 @@
+package tabby
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// embeddingStream yields the embeddings of a large input batch by batch.
+// TabbyAPI does not stream embeddings, so each batch of embeddingBatchSize
+// inputs is a separate Create call, made once the previous batch has been
+// received.
+type embeddingStream struct {
+	ctx     context.Context
+	service *embeddingsService
+	req     EmbeddingsRequest
+	inputs  []string
+
+	next    int // Offset of the next batch to request
+	pending []*EmbeddingObject
+	closed  bool
+}
+
+func (s *embeddingsService) CreateStream(ctx context.Context, req *EmbeddingsRequest) (Stream[*EmbeddingObject], error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	var inputs []string
+	switch input := req.Input.(type) {
+	case string:
+		inputs = []string{input}
+	case []string:
+		inputs = input
+	case []interface{}:
+		// Validate has checked that every entry is a string
+		for _, v := range input {
+			inputs = append(inputs, v.(string))
+		}
+	default:
+		return nil, &ValidationError{Field: "input", Message: fmt.Sprintf("unsupported input type %T", input)}
+	}
+
+	stream := &embeddingStream{ctx: ctx, service: s, req: *req, inputs: inputs}
+	// The first batch is requested up front, so a server that cannot embed
+	// at all fails here rather than on the first Recv
+	if err := stream.fetch(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Recv returns the next embedding, in input order, with Index set to its
+// position in the whole input. It returns io.EOF once every input has been
+// embedded.
+func (s *embeddingStream) Recv() (*EmbeddingObject, error) {
+	if s.closed {
+		return nil, ErrStreamClosed
+	}
+	if len(s.pending) == 0 {
+		if s.next >= len(s.inputs) {
+			return nil, io.EOF
+		}
+		if err := s.fetch(); err != nil {
+			return nil, err
+		}
+	}
+
+	obj := s.pending[0]
+	s.pending = s.pending[1:]
+	return obj, nil
+}
+
+// Close stops the stream; batches not yet requested are never sent.
+func (s *embeddingStream) Close() error {
+	s.closed = true
+	s.pending = nil
+	return nil
+}
+
+// fetch embeds the next batch of inputs into pending.
+func (s *embeddingStream) fetch() error {
+	start := s.next
+	end := min(start+embeddingBatchSize, len(s.inputs))
+
+	req := s.req
+	req.Input = s.inputs[start:end]
+	response, err := s.service.Create(s.ctx, &req)
+	if err != nil {
+		return err
+	}
+
+	// Results are ordered by index, since the server may reorder them
+	data := response.Data
+	sort.SliceStable(data, func(i, j int) bool { return data[i].Index < data[j].Index })
+	if len(data) != end-start {
+		return fmt.Errorf("failed to create embeddings: expected %d embeddings, got %d", end-start, len(data))
+	}
+	batch := make([]*EmbeddingObject, len(data))
+	for i := range data {
+		if data[i].Index != i {
+			return fmt.Errorf("failed to create embeddings: no embedding returned for input[%d]", start+i)
+		}
+		data[i].Index += start
+		batch[i] = &data[i]
+	}
+
+	s.pending = batch
+	s.next = end
+	return nil
+}
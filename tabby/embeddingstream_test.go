@@ -0,0 +1,71 @@
+package tabby
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEmbeddingsService_CreateStream(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			return
+		}
+
+		// Answer in reverse order to check results are yielded by index
+		response := EmbeddingsResponse{Object: ObjectList}
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			var n float64
+			if _, err := fmt.Sscanf(req.Input[i], "text-%f", &n); err != nil {
+				t.Errorf("Unexpected input %q", req.Input[i])
+				return
+			}
+			response.Data = append(response.Data, EmbeddingObject{Embedding: []float64{n}, Index: i})
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+
+	total := embeddingBatchSize + 2
+	inputs := make([]string, total)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("text-%d", i)
+	}
+
+	stream, err := client.Embeddings().CreateStream(context.Background(), &EmbeddingsRequest{Input: inputs})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	defer stream.Close()
+
+	for i := 0; i < total; i++ {
+		obj, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv %d returned an error: %v", i, err)
+		}
+		// The second batch is only requested once the first is consumed
+		if want := int32(1 + i/embeddingBatchSize); atomic.LoadInt32(&requests) != want {
+			t.Fatalf("Expected %d requests after %d embeddings, got %d", want, i+1, requests)
+		}
+		vector, err := obj.AsFloat32()
+		if err != nil {
+			t.Fatalf("AsFloat32 returned an error: %v", err)
+		}
+		if obj.Index != i || len(vector) != 1 || vector[0] != float32(i) {
+			t.Fatalf("Expected embedding %d, got index %d vector %v", i, obj.Index, vector)
+		}
+	}
+
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
@@ -1,8 +1,13 @@
 package tabby
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+
+	apierrors "github.com/pixelsquared/go-tabbyapi/internal/errors"
 )
 
 // Error is the interface implemented by all errors in the TabbyAPI client library.
@@ -223,4 +228,164 @@ var (
 	// ErrStreamClosed is returned when attempting to read from a closed stream.
 	// This typically happens if Recv() is called after Close() or after the stream ends.
 	ErrStreamClosed = &StreamError{Message: "stream closed"}
+
+	// ErrStreamTruncated is returned by CollectCompletionStream when the
+	// stream ended without a finish reason, meaning the output was likely
+	// cut off. The partial response is returned alongside it.
+	ErrStreamTruncated = &StreamError{Message: "stream ended without a finish reason"}
+
+	// ErrNoEmbeddingModelLoaded is returned when an embeddings request is made
+	// while no embedding model is loaded on the server. This is distinct from the
+	// generation model; load one with ModelsService.LoadEmbedding and retry.
+	// Check for it with errors.Is.
+	ErrNoEmbeddingModelLoaded = apierrors.ErrNoEmbeddingModelLoaded
+
+	// ErrNoModelLoaded is returned when a request needs a generation model
+	// while none is loaded on the server. Check for it with errors.Is, or use
+	// ModelsService.GetCurrent to treat it as an empty result.
+	ErrNoModelLoaded = apierrors.ErrNoModelLoaded
+
+	// ErrContextLengthExceeded is returned before sending a generation
+	// request when WithContextGuard finds that the prompt alone fills the
+	// model's context window. Check for it with errors.Is.
+	ErrContextLengthExceeded = errors.New("context length exceeded")
+
+	// ErrNoLoraLoaded is returned by LoraService.GetActive when no LoRA
+	// adapters are loaded and WithNotLoadedErrors is enabled. Check for it
+	// with errors.Is.
+	ErrNoLoraLoaded = errors.New("no LoRA loaded")
+)
+
+// ErrorKind is a coarse classification of errors returned by the client,
+// suitable for a single switch statement in application code.
+type ErrorKind int
+
+const (
+	// KindUnknown is an error that does not fit any other kind.
+	KindUnknown ErrorKind = iota
+
+	// KindAuth is an authentication or permission failure (401, 403).
+	KindAuth
+
+	// KindRateLimit is a rate limiting rejection (429).
+	KindRateLimit
+
+	// KindValidation is an invalid request, rejected either client-side or by the server (400).
+	KindValidation
+
+	// KindNotFound is a missing resource (404).
+	KindNotFound
+
+	// KindServer is a server-side failure (5xx).
+	KindServer
+
+	// KindNetwork is a connection problem or timeout before a response was received.
+	KindNetwork
+
+	// KindStream is a failure while reading a streaming response.
+	KindStream
 )
+
+// String returns a short name for the error kind.
+func (k ErrorKind) String() string {
+	switch k {
+	case KindAuth:
+		return "auth"
+	case KindRateLimit:
+		return "rate_limit"
+	case KindValidation:
+		return "validation"
+	case KindNotFound:
+		return "not_found"
+	case KindServer:
+		return "server"
+	case KindNetwork:
+		return "network"
+	case KindStream:
+		return "stream"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifyError inspects err and its wrapped causes and returns its ErrorKind.
+//
+// This gives callers a single switch instead of chaining errors.As calls:
+//
+//	switch tabby.ClassifyError(err) {
+//	case tabby.KindAuth:
+//	    // refresh credentials
+//	case tabby.KindRateLimit, tabby.KindServer:
+//	    // back off and retry
+//	}
+//
+// A nil error is classified as KindUnknown.
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return KindUnknown
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return KindValidation
+	}
+
+	var coded Error
+	if errors.As(err, &coded) {
+		switch coded.Code() {
+		case "stream_error":
+			return KindStream
+		case "validation_error", "invalid_request":
+			return KindValidation
+		case "authentication_error", "permission_error":
+			return KindAuth
+		case "rate_limit_exceeded":
+			return KindRateLimit
+		case "not_found":
+			return KindNotFound
+		case "server_error":
+			return KindServer
+		case "request_error":
+			return classifyRequestError(err)
+		}
+	}
+
+	if isNetworkError(err) {
+		return KindNetwork
+	}
+	return KindUnknown
+}
+
+// classifyRequestError classifies a RequestError by its status code,
+// falling back to the underlying cause.
+func classifyRequestError(err error) ErrorKind {
+	if isNetworkError(err) {
+		return KindNetwork
+	}
+
+	// HTTPStatusCode reports 500 when no status was set, so read the raw field
+	status := 0
+	var reqErr *RequestError
+	var internalReqErr *apierrors.RequestError
+	if errors.As(err, &reqErr) {
+		status = reqErr.StatusCode
+	} else if errors.As(err, &internalReqErr) {
+		status = internalReqErr.StatusCode
+	}
+
+	switch {
+	case status == http.StatusBadRequest:
+		return KindValidation
+	case status == http.StatusGatewayTimeout:
+		return KindNetwork
+	case status >= 500:
+		return KindServer
+	}
+	return KindUnknown
+}
+
+// isNetworkError reports whether err was caused by a network failure or timeout.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
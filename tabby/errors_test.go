@@ -0,0 +1,45 @@
+package tabby
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	apierrors "github.com/pixelsquared/go-tabbyapi/internal/errors"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"nil", nil, KindUnknown},
+		{"unauthorized", &APIError{StatusCode: http.StatusUnauthorized}, KindAuth},
+		{"forbidden", &APIError{StatusCode: http.StatusForbidden}, KindAuth},
+		{"rate limited", &APIError{StatusCode: http.StatusTooManyRequests}, KindRateLimit},
+		{"bad request", &APIError{StatusCode: http.StatusBadRequest}, KindValidation},
+		{"not found", &APIError{StatusCode: http.StatusNotFound}, KindNotFound},
+		{"server error", &APIError{StatusCode: http.StatusServiceUnavailable}, KindServer},
+		{"validation", &ValidationError{Field: "prompt", Message: "required"}, KindValidation},
+		{"stream", &StreamError{Message: "error reading from stream"}, KindStream},
+		{"stream closed", ErrStreamClosed, KindStream},
+		{"timeout", ErrTimeout, KindNetwork},
+		{"deadline", context.DeadlineExceeded, KindNetwork},
+		{"network", &RequestError{Message: "failed to execute request", Err: &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}}, KindNetwork},
+		{"wrapped internal api error", fmt.Errorf("failed to list models: %w", &apierrors.APIError{StatusCode: http.StatusUnauthorized}), KindAuth},
+		{"wrapped internal request error", fmt.Errorf("failed: %w", &apierrors.RequestError{Message: "failed to execute request", Err: &net.DNSError{Err: "no such host"}}), KindNetwork},
+		{"unmarshal failure", &apierrors.RequestError{Message: "failed to unmarshal response body", StatusCode: http.StatusOK}, KindUnknown},
+		{"plain error", fmt.Errorf("something else"), KindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
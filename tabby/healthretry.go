@@ -0,0 +1,53 @@
+package tabby
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	// healthRetryFactor scales the retry delay up when the server reports
+	// unhealthy, and down when it reports healthy; see WithHealthAwareRetry.
+	healthRetryFactor = 4
+
+	// healthRetryCheckTimeout bounds the health check made before a retry.
+	healthRetryCheckTimeout = 5 * time.Second
+)
+
+// healthAwareRetryPolicy wraps a retry policy, checking the server's health
+// before retrying a request that has met another 503 and scaling the delay
+// by the result.
+type healthAwareRetryPolicy struct {
+	RetryPolicy
+	health HealthService
+}
+
+// ShouldRetryMethod defers to the wrapped policy, which may not implement
+// MethodRetryPolicy itself.
+func (p *healthAwareRetryPolicy) ShouldRetryMethod(method string, resp *http.Response, err error) bool {
+	if mp, ok := p.RetryPolicy.(MethodRetryPolicy); ok {
+		return mp.ShouldRetryMethod(method, resp, err)
+	}
+	return p.RetryPolicy.ShouldRetry(resp, err)
+}
+
+// RetryDelayFor returns the wrapped policy's delay for the retry attempts,
+// starting at 1. From the second retry on, a 503 response triggers a health
+// check: an unhealthy server, or one whose health cannot be checked, gets
+// healthRetryFactor times the delay, and a healthy one, whose 503 was likely
+// a transient blip, a fraction of it.
+func (p *healthAwareRetryPolicy) RetryDelayFor(ctx context.Context, attempts int, resp *http.Response, err error) time.Duration {
+	delay := p.RetryDelay(attempts)
+	if attempts < 2 || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		return delay
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthRetryCheckTimeout)
+	defer cancel()
+	health, checkErr := p.health.Check(ctx)
+	if checkErr != nil || !health.IsHealthy() {
+		return delay * healthRetryFactor
+	}
+	return delay / healthRetryFactor
+}
@@ -0,0 +1,79 @@
+package tabby
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthAwareRetryPolicy_RetryDelayFor(t *testing.T) {
+	var healthy atomic.Bool
+	var checks int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&checks, 1)
+		if healthy.Load() {
+			writeJSON(w, http.StatusOK, HealthCheckResponse{Status: "healthy"})
+			return
+		}
+		writeJSON(w, http.StatusServiceUnavailable, HealthCheckResponse{
+			Status: "unhealthy",
+			Issues: []UnhealthyEvent{{Description: "generation stalled"}},
+		})
+	})
+
+	policy := &healthAwareRetryPolicy{
+		RetryPolicy: &SimpleRetryPolicy{
+			MaxRetryCount:  3,
+			RetryDelayFunc: func(attempts int) time.Duration { return 100 * time.Millisecond },
+			RetryableFunc:  func(resp *http.Response, err error) bool { return true },
+		},
+		health: client.Health(),
+	}
+	unavailable := &http.Response{StatusCode: http.StatusServiceUnavailable}
+	ctx := context.Background()
+
+	// The first retry and other failures use the policy's delay unchecked
+	if d := policy.RetryDelayFor(ctx, 1, unavailable, nil); d != 100*time.Millisecond {
+		t.Errorf("Expected the policy delay on the first retry, got %v", d)
+	}
+	if d := policy.RetryDelayFor(ctx, 2, &http.Response{StatusCode: http.StatusBadGateway}, nil); d != 100*time.Millisecond {
+		t.Errorf("Expected the policy delay for a 502, got %v", d)
+	}
+	if checks != 0 {
+		t.Fatalf("Expected no health checks yet, got %d", checks)
+	}
+
+	if d := policy.RetryDelayFor(ctx, 2, unavailable, nil); d != 400*time.Millisecond {
+		t.Errorf("Expected a longer delay while unhealthy, got %v", d)
+	}
+	healthy.Store(true)
+	if d := policy.RetryDelayFor(ctx, 2, unavailable, nil); d != 25*time.Millisecond {
+		t.Errorf("Expected a shorter delay while healthy, got %v", d)
+	}
+	if checks != 2 {
+		t.Errorf("Expected 2 health checks, got %d", checks)
+	}
+}
+
+func TestWithHealthAwareRetry(t *testing.T) {
+	var requests, checks int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			atomic.AddInt32(&checks, 1)
+			writeJSON(w, http.StatusOK, HealthCheckResponse{Status: "healthy"})
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetryPolicy(fastDefaultRetryPolicy()), WithHealthAwareRetry(true), WithMaxConcurrent(1))
+
+	if _, err := client.Models().List(context.Background()); err == nil {
+		t.Fatal("Expected an error")
+	}
+	// Three retries, the last two preceded by a health check
+	if requests != 4 || checks != 2 {
+		t.Errorf("Expected 4 requests and 2 health checks, got %d and %d", requests, checks)
+	}
+}
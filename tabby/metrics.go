@@ -0,0 +1,69 @@
+package tabby
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// latencyBucketsMS are the upper bounds, in milliseconds, of the latency
+// histogram published by WithExpvarMetrics.
+var latencyBucketsMS = []int64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// expvarMetrics records request metrics into an expvar.Map.
+type expvarMetrics struct {
+	vars    *expvar.Map
+	latency *expvar.Map
+}
+
+// expvarMu serializes looking up and publishing metrics maps, since
+// expvar.Publish panics if two clients publish the same name.
+var expvarMu sync.Mutex
+
+// newExpvarMetrics returns metrics published under name. If name is already
+// published as a map, as by another client with the same prefix, its
+// counters are shared. If it is published as another kind of variable, the
+// metrics are recorded but not published.
+func newExpvarMetrics(name string) *expvarMetrics {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	vars, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		vars = new(expvar.Map)
+		if expvar.Get(name) == nil {
+			expvar.Publish(name, vars)
+		}
+	}
+
+	latency, ok := vars.Get("latency_ms").(*expvar.Map)
+	if !ok {
+		latency = new(expvar.Map)
+		vars.Set("latency_ms", latency)
+	}
+	return &expvarMetrics{vars: vars, latency: latency}
+}
+
+// observe records one HTTP attempt. Transport errors and responses with a
+// status of 400 or above count as errors.
+func (m *expvarMetrics) observe(method string, resp *http.Response, err error, elapsed time.Duration) {
+	m.vars.Add("requests", 1)
+	if err != nil || (resp != nil && resp.StatusCode >= 400) {
+		m.vars.Add("errors", 1)
+	}
+	if resp != nil {
+		m.vars.Add("status_"+strconv.Itoa(resp.StatusCode), 1)
+	}
+
+	// Buckets are cumulative: each counts the attempts at or below its bound
+	ms := elapsed.Milliseconds()
+	for _, bound := range latencyBucketsMS {
+		if ms <= bound {
+			m.latency.Add("le_"+strconv.FormatInt(bound, 10), 1)
+		}
+	}
+	m.latency.Add("le_inf", 1)
+	m.latency.Add("sum", ms)
+}
@@ -0,0 +1,90 @@
+package tabby
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// expvarPrefixes numbers the prefixes handed out by expvarPrefix.
+var expvarPrefixes int64
+
+// expvarPrefix returns an expvar prefix unique to this run of t. expvar
+// names cannot be unpublished, so reusing one across runs, as with
+// -count=2, would accumulate counters from earlier runs.
+func expvarPrefix(t *testing.T) string {
+	return fmt.Sprintf("%s_%d", t.Name(), atomic.AddInt64(&expvarPrefixes, 1))
+}
+
+func TestWithExpvarMetrics(t *testing.T) {
+	prefix := expvarPrefix(t)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models/current" {
+			writeJSON(w, http.StatusOK, ModelCard{ID: "model"})
+			return
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+	}, WithExpvarMetrics(prefix))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := client.Models().Get(ctx); err != nil {
+			t.Fatalf("Get returned an error: %v", err)
+		}
+	}
+	if _, err := client.Lora().List(ctx); err == nil {
+		t.Fatal("Expected an error from the missing endpoint, got nil")
+	}
+
+	vars, ok := expvar.Get(prefix).(*expvar.Map)
+	if !ok {
+		t.Fatalf("Expected an expvar map named %s", prefix)
+	}
+	for key, want := range map[string]string{"requests": "3", "errors": "1", "status_200": "2", "status_404": "1"} {
+		if got := vars.Get(key); got == nil || got.String() != want {
+			t.Errorf("Expected %s = %s, got %v", key, want, got)
+		}
+	}
+
+	latency, ok := vars.Get("latency_ms").(*expvar.Map)
+	if !ok {
+		t.Fatal("Expected a latency_ms histogram")
+	}
+	if got := latency.Get("le_inf"); got == nil || got.String() != "3" {
+		t.Errorf("Expected 3 observations in le_inf, got %v", got)
+	}
+
+	// A second client with the same prefix shares the counters
+	other := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ModelCard{ID: "model"})
+	}, WithExpvarMetrics(prefix))
+	if _, err := other.Models().Get(ctx); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	if got := vars.Get("requests").String(); got != "4" {
+		t.Errorf("Expected 4 requests after the second client, got %s", got)
+	}
+}
+
+func TestWithExpvarMetrics_ConcurrentClients(t *testing.T) {
+	prefix := expvarPrefix(t)
+
+	// Publishing the same name twice would panic
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = NewClient(WithExpvarMetrics(prefix)).Close()
+		}()
+	}
+	wg.Wait()
+
+	if _, ok := expvar.Get(prefix).(*expvar.Map); !ok {
+		t.Fatalf("Expected an expvar map named %s", prefix)
+	}
+}
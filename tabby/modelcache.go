@@ -0,0 +1,121 @@
+package tabby
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pixelsquared/go-tabbyapi/internal/auth"
+)
+
+// modelInfoCache memoizes the current model card and props for a fixed TTL.
+// A nil cache stores nothing, so services can use it unconditionally.
+type modelInfoCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+
+	card        *ModelCard
+	cardExpires time.Time
+
+	props        *ModelPropsResponse
+	propsExpires time.Time
+}
+
+// newModelInfoCache returns a cache holding entries for ttl.
+func newModelInfoCache(ttl time.Duration) *modelInfoCache {
+	return &modelInfoCache{ttl: ttl}
+}
+
+// forContext returns c, or nil if ctx carries its own authenticator. Tenants
+// authenticating per request may be served different model info, so their
+// lookups neither read nor fill the shared cache.
+func (c *modelInfoCache) forContext(ctx context.Context) *modelInfoCache {
+	if _, ok := auth.FromContext(ctx); ok {
+		return nil
+	}
+	return c
+}
+
+// getCard returns a copy of the cached model card, if it has not expired.
+func (c *modelInfoCache) getCard() (*ModelCard, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.card == nil || time.Now().After(c.cardExpires) {
+		return nil, false
+	}
+	return copyModelCard(c.card), true
+}
+
+// putCard caches a copy of card.
+func (c *modelInfoCache) putCard(card *ModelCard) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.card = copyModelCard(card)
+	c.cardExpires = time.Now().Add(c.ttl)
+}
+
+// getProps returns a copy of the cached model props, if they have not
+// expired.
+func (c *modelInfoCache) getProps() (*ModelPropsResponse, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.props == nil || time.Now().After(c.propsExpires) {
+		return nil, false
+	}
+	return copyModelProps(c.props), true
+}
+
+// putProps caches a copy of props.
+func (c *modelInfoCache) putProps(props *ModelPropsResponse) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.props = copyModelProps(props)
+	c.propsExpires = time.Now().Add(c.ttl)
+}
+
+// invalidate drops all cached entries, as after a model load or unload.
+func (c *modelInfoCache) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.card, c.props = nil, nil
+}
+
+// copyModelCard returns a copy of card that shares no pointers with it.
+func copyModelCard(card *ModelCard) *ModelCard {
+	out := *card
+	if card.Parameters != nil {
+		params := *card.Parameters
+		out.Parameters = &params
+	}
+	return &out
+}
+
+// copyModelProps returns a copy of props that shares no pointers with it.
+func copyModelProps(props *ModelPropsResponse) *ModelPropsResponse {
+	out := *props
+	if props.DefaultGenerationSettings != nil {
+		settings := *props.DefaultGenerationSettings
+		out.DefaultGenerationSettings = &settings
+	}
+	return &out
+}
@@ -0,0 +1,93 @@
+package tabby
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithModelInfoCache(t *testing.T) {
+	var propsCalls, currentCalls int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/props":
+			propsCalls++
+			writeJSON(w, http.StatusOK, ModelPropsResponse{
+				TotalSlots:                1,
+				DefaultGenerationSettings: &ModelDefaultGenerationSettings{NCtx: 4096},
+			})
+		case "/v1/models/current":
+			if r.Method == http.MethodDelete {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			currentCalls++
+			writeJSON(w, http.StatusOK, ModelCard{ID: "model"})
+		default:
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+	}, WithModelInfoCache(time.Minute))
+
+	ctx := context.Background()
+	models := client.Models()
+	for i := 0; i < 2; i++ {
+		props, err := models.GetProps(ctx)
+		if err != nil {
+			t.Fatalf("GetProps returned an error: %v", err)
+		}
+		if props.ContextLength() != 4096 {
+			t.Errorf("Expected context length 4096, got %d", props.ContextLength())
+		}
+		if _, err := models.Get(ctx); err != nil {
+			t.Fatalf("Get returned an error: %v", err)
+		}
+	}
+	if propsCalls != 1 || currentCalls != 1 {
+		t.Errorf("Expected one server call each within the TTL, got props=%d current=%d", propsCalls, currentCalls)
+	}
+
+	if err := models.Unload(ctx); err != nil {
+		t.Fatalf("Unload returned an error: %v", err)
+	}
+	if _, err := models.GetProps(ctx); err != nil {
+		t.Fatalf("GetProps returned an error: %v", err)
+	}
+	if propsCalls != 2 {
+		t.Errorf("Expected Unload to invalidate the cache, got %d props calls", propsCalls)
+	}
+}
+
+func TestWithModelInfoCache_SkipsContextAuth(t *testing.T) {
+	var currentCalls int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		currentCalls++
+		writeJSON(w, http.StatusOK, ModelCard{ID: "model for " + r.Header.Get("Authorization")})
+	}, WithModelInfoCache(time.Minute), WithAPIKey("shared"))
+
+	ctx := context.Background()
+	if _, err := client.Models().Get(ctx); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+
+	tenantCtx := ContextWithAuth(ctx, &BearerTokenAuthenticator{Token: "tenant"})
+	for i := 0; i < 2; i++ {
+		card, err := client.Models().Get(tenantCtx)
+		if err != nil {
+			t.Fatalf("Get returned an error: %v", err)
+		}
+		if card.ID != "model for Bearer tenant" {
+			t.Errorf("Expected the tenant's own model info, got %q", card.ID)
+		}
+	}
+	if currentCalls != 3 {
+		t.Errorf("Expected tenant lookups to bypass the cache, got %d calls", currentCalls)
+	}
+
+	if _, err := client.Models().Get(ctx); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	if currentCalls != 3 {
+		t.Errorf("Expected the shared entry to stay cached, got %d calls", currentCalls)
+	}
+}
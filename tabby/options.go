@@ -2,6 +2,10 @@
 package tabby
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
 	"net/http"
 	"time"
 )
@@ -37,17 +41,58 @@ func WithBaseURL(url string) Option {
 func WithHTTPClient(client *http.Client) Option {
 	return func(c *clientImpl) {
 		c.httpClient = client
+		c.customHTTPClient = true
 	}
 }
 
+// WithForceHTTP1 restricts the client's transport to HTTP/1.1.
+//
+// Some proxies buffer server-sent events over HTTP/2, so streams stall until
+// the proxy flushes. Forcing HTTP/1.1 avoids this. The option only applies
+// to the default HTTP client; a client passed to WithHTTPClient is used as is.
+func WithForceHTTP1(force bool) Option {
+	return func(c *clientImpl) {
+		c.forceHTTP1 = force
+	}
+}
+
+// WithDisableKeepAlives closes each connection once its request completes
+// instead of keeping it open for reuse.
+//
+// One-shot tools, such as CLIs that make a request or two and exit, then
+// leave no idle connections behind. Long-running programs should keep the
+// default, as every request pays for a new connection. Like WithForceHTTP1,
+// the option only applies to the default HTTP client.
+func WithDisableKeepAlives(disable bool) Option {
+	return func(c *clientImpl) {
+		c.disableKeepAlives = disable
+	}
+}
+
+// http1Transport returns a copy of the default transport that never
+// negotiates HTTP/2.
+func http1Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = false
+	transport.HTTP2 = nil
+	// A non-nil empty map disables the transport's automatic HTTP/2 upgrade
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	transport.Protocols = protocols
+	return transport
+}
+
 // WithAPIKey sets the API key for standard API authentication.
 //
 // The API key is sent with each request in the X-API-Key header.
 // API keys typically provide read and write access but not administrative
-// capabilities.
+// capabilities. If WithAdminKey is also used, both headers are sent, since
+// TabbyAPI may check either depending on the endpoint.
 func WithAPIKey(key string) Option {
 	return func(c *clientImpl) {
-		c.auth = &APIKeyAuthenticator{Key: key}
+		c.setKeyAuth(&APIKeyAuthenticator{Key: key})
 	}
 }
 
@@ -55,23 +100,77 @@ func WithAPIKey(key string) Option {
 //
 // The admin key is sent with each request in the X-Admin-Key header.
 // Admin keys provide full access to all API operations, including
-// administrative functions like model and LoRA management.
+// administrative functions like model and LoRA management. If WithAPIKey is
+// also used, both headers are sent.
 func WithAdminKey(key string) Option {
 	return func(c *clientImpl) {
-		c.auth = &AdminKeyAuthenticator{Key: key}
+		c.setKeyAuth(&AdminKeyAuthenticator{Key: key})
+	}
+}
+
+// setKeyAuth sets an API or admin key authenticator. A configured key of the
+// other kind is kept so both are sent; any other authenticator is replaced.
+func (c *clientImpl) setKeyAuth(auth Authenticator) {
+	existing := []Authenticator{c.auth}
+	if multi, ok := c.auth.(*MultiAuthenticator); ok {
+		existing = multi.Authenticators
+	}
+
+	var keep []Authenticator
+	for _, a := range existing {
+		if isOtherKey(a, auth) {
+			keep = append(keep, a)
+		}
 	}
+
+	if len(keep) == 0 {
+		c.auth = auth
+		return
+	}
+	c.auth = &MultiAuthenticator{Authenticators: append(keep, auth)}
+}
+
+// isOtherKey reports whether existing is a key authenticator of the opposite
+// kind to auth, so the two can be applied together.
+func isOtherKey(existing, auth Authenticator) bool {
+	switch existing.(type) {
+	case *APIKeyAuthenticator:
+		_, ok := auth.(*AdminKeyAuthenticator)
+		return ok
+	case *AdminKeyAuthenticator:
+		_, ok := auth.(*APIKeyAuthenticator)
+		return ok
+	}
+	return false
 }
 
 // WithBearerToken sets a bearer token for OAuth or JWT authentication.
 //
 // The token is sent with each request in the Authorization header
-// with the Bearer scheme.
+// with the Bearer scheme. It replaces any API or admin key.
 func WithBearerToken(token string) Option {
 	return func(c *clientImpl) {
 		c.auth = &BearerTokenAuthenticator{Token: token}
 	}
 }
 
+// WithAuthenticators applies all of the given authenticators to each request.
+//
+// Use this when more than one credential is required, for example a bearer
+// token for a gateway plus an API key for TabbyAPI behind it:
+//
+//	tabby.WithAuthenticators(
+//	    &tabby.BearerTokenAuthenticator{Token: gatewayToken},
+//	    &tabby.APIKeyAuthenticator{Key: apiKey},
+//	)
+//
+// It replaces any previously configured authentication.
+func WithAuthenticators(authenticators ...Authenticator) Option {
+	return func(c *clientImpl) {
+		c.auth = &MultiAuthenticator{Authenticators: authenticators}
+	}
+}
+
 // WithTimeout sets the timeout duration for all API requests.
 //
 // The timeout includes connection time, any redirects, and reading
@@ -83,6 +182,366 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithBaseContext derives every request made by the client from ctx, so a
+// client-wide deadline or shutdown signal applies alongside each call's own
+// context.
+//
+// A request fails as soon as either context is done: canceling ctx aborts
+// requests in flight, streams included, and makes new requests fail
+// immediately. Values not found in a call's context, such as a tracing span,
+// are looked up in ctx, so they reach the HTTP transport and authenticators.
+// Close still cancels the client independently of ctx.
+func WithBaseContext(ctx context.Context) Option {
+	return func(c *clientImpl) {
+		c.parentCtx = ctx
+	}
+}
+
+// WithMaxConcurrent limits the client to n requests in flight at once, to
+// avoid issuing more parallel generations than a single-GPU server can
+// handle.
+//
+// Further requests block until a slot frees, or fail with an error wrapping
+// the context's error if their context is done first. A stream holds its slot
+// until it is closed, so always close streams. The limit is shared by all
+// services of the client. n <= 0 (the default) means no limit.
+func WithMaxConcurrent(n int) Option {
+	return func(c *clientImpl) {
+		c.maxConcurrent = n
+	}
+}
+
+// WithTokenRateLimit throttles generation requests to roughly
+// tokensPerMinute tokens, using a token bucket that starts full and holds at
+// most one minute of budget.
+//
+// Completion and chat responses are charged the total tokens reported in
+// their Usage; streaming requests are charged their MaxTokens up front since
+// usage is not known until the stream ends, or a full minute of budget when
+// MaxTokens is unset. Once the budget is spent, further generation requests
+// block until it refills, or fail with a *RequestError wrapping the
+// context's error if their context is done first. tokensPerMinute <= 0 (the
+// default) means no limit.
+func WithTokenRateLimit(tokensPerMinute int) Option {
+	return func(c *clientImpl) {
+		if tokensPerMinute <= 0 {
+			c.tokens = nil
+			return
+		}
+		c.tokens = newTokenLimiter(tokensPerMinute)
+	}
+}
+
+// WithRequestDeduplication enables or disables coalescing concurrent
+// identical GET requests. While one is in flight, further GETs to the same
+// URL wait for it and share its result, so only one reaches the server.
+// Other methods are never deduplicated, since they may not be idempotent.
+//
+// The shared request is not canceled when one caller's context is done,
+// only once every waiting caller has given up. Disabled by default.
+func WithRequestDeduplication(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.deduplicate = enabled
+	}
+}
+
+// WithExpvarMetrics publishes request metrics under expvar, so they appear
+// on the /debug/vars endpoint of any server that imports expvar.
+//
+// The metrics are an expvar.Map named prefix with these entries, counted
+// per HTTP attempt (so retries count separately):
+//
+//   - requests: attempts made
+//   - errors: attempts that failed or returned a status of 400 or above
+//   - status_<code>: attempts per response status code
+//   - latency_ms: a cumulative histogram of time to response headers, with
+//     le_<bound> buckets, le_inf, and the sum in milliseconds
+//
+// Clients created with the same prefix share their counters, and may be
+// created concurrently. If prefix is already published as a variable other
+// than an expvar.Map, the metrics are still recorded but cannot be
+// published, so they do not appear on /debug/vars; choose another prefix.
+func WithExpvarMetrics(prefix string) Option {
+	return func(c *clientImpl) {
+		c.metrics = newExpvarMetrics(prefix)
+	}
+}
+
+// WithUnloadMethod sets the HTTP method used by unload operations:
+// ModelsService.Unload and UnloadEmbedding, LoraService.Unload,
+// TemplatesService.Unload, and SamplingService.UnloadOverride.
+//
+// These use DELETE by default. Some TabbyAPI versions expect POST instead;
+// pass http.MethodPost to send a POST with an empty JSON object as the body.
+// Methods other than http.MethodDelete and http.MethodPost are ignored.
+func WithUnloadMethod(method string) Option {
+	return func(c *clientImpl) {
+		if method == http.MethodDelete || method == http.MethodPost {
+			c.unloadMethod = method
+		}
+	}
+}
+
+// MaxTokensField selects which JSON key carries the token limit of a
+// ChatCompletionRequest.
+type MaxTokensField int
+
+const (
+	// MaxTokensFieldLegacy sends the limit as "max_tokens". This is the default
+	// and what TabbyAPI currently understands.
+	MaxTokensFieldLegacy MaxTokensField = iota
+
+	// MaxTokensFieldCompletion sends the limit as "max_completion_tokens",
+	// the key used by newer OpenAI-compatible servers.
+	MaxTokensFieldCompletion
+
+	// MaxTokensFieldBoth sends the limit under both keys.
+	MaxTokensFieldBoth
+)
+
+// WithMaxTokensField sets which key is used to send the chat completion token limit.
+//
+// Whichever of ChatCompletionRequest.MaxTokens or MaxCompletionTokens is set
+// (MaxCompletionTokens wins if both are) is sent under the selected key(s).
+// This future-proofs clients against servers following the OpenAI rename.
+func WithMaxTokensField(field MaxTokensField) Option {
+	return func(c *clientImpl) {
+		c.maxTokensField = field
+	}
+}
+
+// WithUTF8Buffering enables holding back incomplete UTF-8 sequences in
+// completion and chat streams.
+//
+// A multi-byte character can be split across two stream chunks. Consumers
+// that render each delta on its own would then show replacement characters.
+// With buffering enabled, the incomplete bytes at the end of a delta are held
+// back and delivered at the start of the next one, so every delta contains
+// only whole characters. Disabled by default.
+func WithUTF8Buffering(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.stream.utf8Buffering = enabled
+	}
+}
+
+// WithTerminalStreamEvents sets the SSE event types that end completion and
+// chat streams.
+//
+// Some servers signal the end of a stream with a frame such as "event: done"
+// rather than by closing the connection. When Recv receives an event of one
+// of these types it returns io.EOF. Event types are matched
+// case-insensitively. The default is "done" and "end"; calling with no
+// arguments disables terminal event handling.
+func WithTerminalStreamEvents(events ...string) Option {
+	return func(c *clientImpl) {
+		c.stream.terminalEvents = append([]string{}, events...)
+	}
+}
+
+// WithStreamMaxDuration caps the total lifetime of completion and chat
+// streams at d, however steadily they are producing chunks.
+//
+// The deadline is set on the stream's context when the stream is created.
+// Once it passes, Recv returns context.DeadlineExceeded, including a Recv
+// that is blocked waiting for the next chunk, and the connection is closed.
+// d <= 0 (the default) means no limit.
+func WithStreamMaxDuration(d time.Duration) Option {
+	return func(c *clientImpl) {
+		c.stream.maxDuration = d
+	}
+}
+
+// WithStreamBufferSize sets the size of the read buffer used by completion,
+// chat, and raw streams.
+//
+// Streams read the connection line by line and return an event from Recv as
+// soon as its terminating blank line arrives, without waiting for the buffer
+// to fill. A small buffer additionally keeps each read from the connection
+// to a few bytes, for token-by-token UIs behind proxies that deliver tiny
+// chunks; a large one suits servers that send very long lines. Streams with
+// a non-default size do not share pooled buffers. size <= 0 (the default)
+// uses pooled 4 KiB buffers.
+func WithStreamBufferSize(size int) Option {
+	return func(c *clientImpl) {
+		c.stream.bufferSize = size
+	}
+}
+
+// WithEndpointOverride sets the path used for a logical endpoint, for
+// deployments that serve an API at a non-standard path.
+//
+// logicalName is one of EndpointCompletions ("completions"), EndpointChat
+// ("chat"), or EndpointEmbeddings ("embeddings"); other names are ignored.
+// path is relative to the base URL, for example "proxy/v1/chat/completions".
+func WithEndpointOverride(logicalName, path string) Option {
+	return func(c *clientImpl) {
+		if _, ok := defaultEndpoints[logicalName]; !ok {
+			return
+		}
+		if c.endpoints == nil {
+			c.endpoints = make(map[string]string)
+		}
+		c.endpoints[logicalName] = path
+	}
+}
+
+// WithMessageSanitizer enables or disables sanitizing chat messages before
+// they are sent.
+//
+// When enabled, ChatService passes each request's messages through
+// SanitizeMessages, merging adjacent same-role messages. Empty messages are
+// kept unless WithEmptyMessageDropping is also enabled. The caller's request
+// is not modified. Disabled by default.
+func WithMessageSanitizer(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.sanitizeMessages = enabled
+	}
+}
+
+// WithEmptyMessageDropping enables or disables dropping empty messages when
+// WithMessageSanitizer is enabled. It has no effect otherwise. Disabled by
+// default.
+func WithEmptyMessageDropping(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.dropEmptyMessages = enabled
+	}
+}
+
+// WithAutoLoadEmbeddingModel makes EmbeddingsService.Create load the named
+// embedding model when the server reports that none is loaded, then retry
+// the request once.
+//
+// Loading a model is an admin operation, so the client must also be
+// configured with an admin key. It is intended for single-user setups, as
+// concurrent callers that all find no model loaded will each request the
+// load. An empty name disables it, which is the default.
+func WithAutoLoadEmbeddingModel(name string) Option {
+	return func(c *clientImpl) {
+		c.autoLoadEmbeddingModel = name
+	}
+}
+
+// WithModelInfoCache memoizes the results of ModelsService.Get and GetProps
+// for ttl, for callers that look up the context length or current model on
+// every request.
+//
+// The cache is dropped whenever the same client loads or unloads a model.
+// Changes made through another client or directly on the server are only
+// seen once the entry expires. Failed lookups are not cached. A ttl of zero
+// or less disables caching, which is the default.
+func WithModelInfoCache(ttl time.Duration) Option {
+	return func(c *clientImpl) {
+		if ttl <= 0 {
+			c.modelCache = nil
+			return
+		}
+		c.modelCache = newModelInfoCache(ttl)
+	}
+}
+
+// WithEnforceSystemFirst enables or disables moving the system message to
+// the start of chat requests before they are sent.
+//
+// When enabled, ChatService passes each request's messages through
+// EnforceSystemFirst, after WithMessageSanitizer if both are set. A request
+// with more than one system message fails with a *ValidationError unless
+// WithSystemMessageMerging is also enabled. The caller's request is not
+// modified. Disabled by default.
+func WithEnforceSystemFirst(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.enforceSystemFirst = enabled
+	}
+}
+
+// WithSystemMessageMerging enables or disables merging multiple system
+// messages into one when WithEnforceSystemFirst is enabled. It has no effect
+// otherwise. Disabled by default.
+func WithSystemMessageMerging(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.mergeSystemMessages = enabled
+	}
+}
+
+// WithStrictStreamFlag enables or disables strict checking of the Stream
+// field on completion and chat requests.
+//
+// By default Create sends the request with Stream forced to false and
+// CreateStream with it forced to true, whatever the caller set. When strict
+// checking is enabled, a request whose Stream field does not match the
+// method instead fails with a *ValidationError, surfacing code that builds
+// streaming requests but calls Create, or the reverse. Helpers built on
+// CreateStream, such as CreateStreamCallback, then also need Stream set to
+// true. Disabled by default.
+func WithStrictStreamFlag(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.strictStream = enabled
+	}
+}
+
+// WithContextGuard enables or disables fitting generation requests into the
+// loaded model's context window before they are sent.
+//
+// When enabled, Create and CreateStream on the completions and chat services
+// look up the context length with ModelsService.GetProps and count the
+// prompt with TokensService.Encode. A MaxTokens (or MaxCompletionTokens)
+// larger than the context left after the prompt is lowered to fit, and a
+// prompt that fills the context on its own fails with an error wrapping
+// ErrContextLengthExceeded without reaching the generation endpoint. A
+// request that leaves MaxTokens unset is not clamped.
+//
+// Each guarded request costs an extra encode call, except a completion whose
+// prompt is already token IDs. The context length is cached for one minute
+// in a cache private to the guard, dropped when the same client loads or
+// unloads a model; it does not enable WithModelInfoCache for other callers.
+// Marshal does not apply the guard. Disabled by default.
+func WithContextGuard(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.contextGuard = enabled
+	}
+}
+
+// WithNotLoadedErrors enables or disables reporting an empty server slot as
+// a sentinel error from the methods that describe what is currently loaded.
+//
+// When enabled, ModelsService.Get fails with ErrNoModelLoaded, GetEmbedding
+// with ErrNoEmbeddingModelLoaded, and LoraService.GetActive with
+// ErrNoLoraLoaded when the server answers 404 or returns an empty result, so
+// callers can check all three the same way with errors.Is. A 404 response
+// remains in the error chain. By default each method returns whatever the
+// server sent. Disabled by default.
+func WithNotLoadedErrors(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.notLoadedErrors = enabled
+	}
+}
+
+// WithResponseValidation enables or disables checking the object type of
+// generation responses, to catch a request routed to the wrong endpoint or a
+// server that has drifted from the expected API.
+//
+// When enabled, completion responses must have object "text_completion",
+// chat completion responses "chat.completion", and embeddings responses
+// "list"; otherwise the call fails with a *ValidationError for field
+// "object". Streaming responses are not checked. Disabled by default.
+func WithResponseValidation(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.validateResponses = enabled
+	}
+}
+
+// WithRedactedHeaders adds headers to be masked whenever the client surfaces
+// request headers. Currently that is the request of the response passed to
+// the WithBeforeRetry function, resp.Request.Header.
+//
+// X-API-Key, X-Admin-Key, and Authorization are always redacted; use this
+// option for additional credentials, such as a proxy token header. Names are
+// matched case-insensitively.
+func WithRedactedHeaders(names ...string) Option {
+	return func(c *clientImpl) {
+		c.redactedHeaders = append(c.redactedHeaders, names...)
+	}
+}
+
 // RetryPolicy defines how the client should retry failed requests.
 // This interface allows for customizable retry behavior, including
 // determining which requests should be retried, how long to wait between
@@ -118,6 +577,18 @@ type RetryPolicy interface {
 	MaxRetries() int
 }
 
+// MethodRetryPolicy is an optional extension of RetryPolicy for policies that
+// need the HTTP method to decide whether to retry, for example to avoid
+// retrying non-idempotent requests that the server may already have processed.
+//
+// When a RetryPolicy also implements MethodRetryPolicy, the client calls
+// ShouldRetryMethod instead of ShouldRetry.
+type MethodRetryPolicy interface {
+	// ShouldRetryMethod determines if a request with the given HTTP method
+	// should be retried based on the HTTP response and/or error.
+	ShouldRetryMethod(method string, resp *http.Response, err error) bool
+}
+
 // WithRetryPolicy sets the retry policy for the client.
 // This allows customizing how and when failed requests are retried.
 // For most use cases, DefaultRetryPolicy() provides a reasonable default,
@@ -128,6 +599,56 @@ func WithRetryPolicy(policy RetryPolicy) Option {
 	}
 }
 
+// WithStreamConnectRetry sets a retry policy used only when establishing
+// streams, such as ChatService.CreateStream, in place of the policy set with
+// WithRetryPolicy. Other requests keep using the general policy, so stream
+// connections can be retried aggressively while non-streaming POSTs are not
+// retried at all.
+//
+// The policy only covers the initial request; a stream that fails after the
+// response has started is not retried. A MethodRetryPolicy sees the
+// request's method, except that a 5xx response is retried whenever
+// ShouldRetry allows it, since the server has not started generating. Under
+// DefaultRetryPolicy, a streaming POST is therefore retried on 5xx and on
+// connection errors. The same applies when streams use the general policy.
+func WithStreamConnectRetry(policy RetryPolicy) Option {
+	return func(c *clientImpl) {
+		c.streamRetryPolicy = policy
+	}
+}
+
+// WithHealthAwareRetry enables or disables consulting HealthService.Check
+// before retrying a request that keeps failing with 503 Service Unavailable.
+//
+// From the second retry of a request on, a 503 prompts a health check. If
+// the server reports unhealthy, or the check itself fails, the retry waits
+// four times the policy's delay, giving an overloaded or recovering server
+// room. If the server reports healthy, the 503 was likely a transient blip
+// and the retry waits a quarter of the delay. The policies set with
+// WithRetryPolicy and WithStreamConnectRetry still decide whether to retry;
+// without a policy the option has no effect. Health checks are not retried
+// and do not count against WithMaxConcurrent. Disabled by default.
+func WithHealthAwareRetry(enabled bool) Option {
+	return func(c *clientImpl) {
+		c.healthAwareRetry = enabled
+	}
+}
+
+// WithBeforeRetry sets a function called just before the client waits to
+// retry a failed request, for logging or metrics. attempt is the retry about
+// to be made, starting at 1. resp and err are the outcome of the failed
+// attempt; resp may be nil, and its body is discarded once fn returns. The
+// credentials in resp.Request.Header are masked; see WithRedactedHeaders.
+//
+// fn runs on the goroutine making the request and delays the retry until it
+// returns, so it should be quick. It is only called when a retry policy is
+// set and decides to retry.
+func WithBeforeRetry(fn func(attempt int, resp *http.Response, err error)) Option {
+	return func(c *clientImpl) {
+		c.beforeRetry = fn
+	}
+}
+
 // SimpleRetryPolicy provides a basic retry policy implementation that can be
 // configured with custom functions for determining retry conditions, delays
 // between retries, and the maximum number of retry attempts.
@@ -145,6 +666,11 @@ type SimpleRetryPolicy struct {
 	// RetryableFunc determines if a request should be retried based on
 	// the HTTP response and/or error
 	RetryableFunc func(resp *http.Response, err error) bool
+
+	// RetryableMethodFunc optionally determines if a request should be retried
+	// based on its HTTP method as well. When set, it takes precedence over
+	// RetryableFunc for requests made by the client.
+	RetryableMethodFunc func(method string, resp *http.Response, err error) bool
 }
 
 // ShouldRetry implements the RetryPolicy interface by delegating to the
@@ -153,6 +679,15 @@ func (p *SimpleRetryPolicy) ShouldRetry(resp *http.Response, err error) bool {
 	return p.RetryableFunc(resp, err)
 }
 
+// ShouldRetryMethod implements the MethodRetryPolicy interface by delegating to
+// RetryableMethodFunc if set, and to RetryableFunc otherwise.
+func (p *SimpleRetryPolicy) ShouldRetryMethod(method string, resp *http.Response, err error) bool {
+	if p.RetryableMethodFunc != nil {
+		return p.RetryableMethodFunc(method, resp, err)
+	}
+	return p.RetryableFunc(resp, err)
+}
+
 // RetryDelay implements the RetryPolicy interface by delegating to the
 // RetryDelayFunc function provided in the SimpleRetryPolicy struct.
 func (p *SimpleRetryPolicy) RetryDelay(attempts int) time.Duration {
@@ -173,10 +708,17 @@ func (p *SimpleRetryPolicy) MaxRetries() int {
 //   - 2nd retry: ~1.2s
 //   - 3rd retry: ~2.3s
 //
-// - Retries on the following conditions:
+// - Retries idempotent requests (GET, HEAD, OPTIONS, PUT, DELETE) on:
 //   - Any network or connection error
 //   - Any HTTP status code >= 500 (server errors)
 //
+// - Retries POST and other non-idempotent requests only on:
+//   - Errors where the connection could not be established
+//
+// A 5xx response to a non-idempotent request is not retried, because the
+// server may already have processed it (a retried model load, for instance,
+// could trigger a duplicate load).
+//
 // This policy is suitable for most use cases and provides a balance between
 // reliability and responsiveness. For more specific requirements, create a
 // custom RetryPolicy implementation or use SimpleRetryPolicy with tailored
@@ -195,5 +737,32 @@ func DefaultRetryPolicy() RetryPolicy {
 			}
 			return resp.StatusCode >= 500
 		},
+		RetryableMethodFunc: func(method string, resp *http.Response, err error) bool {
+			if !isIdempotentMethod(method) {
+				// The request may have been processed, so only retry if it never left
+				return isConnectionError(err)
+			}
+			if err != nil {
+				return true
+			}
+			return resp.StatusCode >= 500
+		},
 	}
 }
+
+// isIdempotentMethod reports whether repeating a request with the given
+// method has the same effect as sending it once.
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isConnectionError reports whether err occurred while establishing the
+// connection, before any part of the request was sent.
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
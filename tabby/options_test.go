@@ -0,0 +1,638 @@
+package tabby
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastDefaultRetryPolicy returns DefaultRetryPolicy with delays shortened for tests.
+func fastDefaultRetryPolicy() *SimpleRetryPolicy {
+	policy := DefaultRetryPolicy().(*SimpleRetryPolicy)
+	policy.RetryDelayFunc = func(attempts int) time.Duration { return time.Millisecond }
+	return policy
+}
+
+func TestDefaultRetryPolicy_RetriesGETOnServerError(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, ModelList{Object: "list"})
+	}, WithRetryPolicy(fastDefaultRetryPolicy()))
+
+	if _, err := client.Models().List(context.Background()); err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+func TestWithStreamConnectRetry(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+	}, WithStreamConnectRetry(&SimpleRetryPolicy{
+		MaxRetryCount:  3,
+		RetryDelayFunc: func(attempts int) time.Duration { return time.Millisecond },
+		RetryableFunc:  func(resp *http.Response, err error) bool { return true },
+	}))
+
+	// The first attempt fails and the stream connect policy retries it
+	stream, err := client.Chat().CreateStream(context.Background(), &ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	stream.Close()
+	if calls != 2 {
+		t.Fatalf("Expected 2 calls for CreateStream, got %d", calls)
+	}
+
+	// A non-streaming POST uses the general policy, which is unset
+	if _, err := client.Chat().Create(context.Background(), &ChatCompletionRequest{}); err == nil {
+		t.Fatal("Expected an error from Create, got nil")
+	}
+	if calls != 3 {
+		t.Errorf("Expected Create not to be retried, got %d calls in total", calls)
+	}
+}
+
+func TestWithBeforeRetry(t *testing.T) {
+	var calls int32
+	var attempts []int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, ModelList{})
+	}, WithRetryPolicy(fastDefaultRetryPolicy()), WithBeforeRetry(func(attempt int, resp *http.Response, err error) {
+		if resp == nil || resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("Attempt %d: expected the failed 500 response, got %v, %v", attempt, resp, err)
+		}
+		attempts = append(attempts, attempt)
+	}))
+
+	if _, err := client.Models().List(context.Background()); err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+	want := []int{1, 2, 3}
+	if len(attempts) != len(want) {
+		t.Fatalf("Expected attempts %v, got %v", want, attempts)
+	}
+	for i := range want {
+		if attempts[i] != want[i] {
+			t.Errorf("Expected attempts %v, got %v", want, attempts)
+			break
+		}
+	}
+}
+
+func TestDefaultRetryPolicy_DoesNotRetryPOSTOnServerError(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}, WithRetryPolicy(fastDefaultRetryPolicy()))
+
+	if _, err := client.Models().Load(context.Background(), &ModelLoadRequest{ModelName: "m"}); err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	if calls != 1 {
+		t.Errorf("Expected exactly 1 call for a failed POST, got %d", calls)
+	}
+}
+
+func TestDefaultRetryPolicy_RetriesPOSTOnConnectionError(t *testing.T) {
+	policy := fastDefaultRetryPolicy()
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}
+
+	if !policy.ShouldRetryMethod(http.MethodPost, nil, dialErr) {
+		t.Error("Expected POST to be retried on a dial error")
+	}
+	if policy.ShouldRetryMethod(http.MethodPost, nil, readErr) {
+		t.Error("Expected POST not to be retried after the request was sent")
+	}
+	if !policy.ShouldRetryMethod(http.MethodDelete, nil, readErr) {
+		t.Error("Expected DELETE to be retried on any connection error")
+	}
+	if policy.ShouldRetryMethod(http.MethodGet, &http.Response{StatusCode: http.StatusBadRequest}, nil) {
+		t.Error("Expected GET not to be retried on a 4xx response")
+	}
+}
+
+func TestWithForceHTTP1(t *testing.T) {
+	c := NewClient(WithForceHTTP1(true)).(*clientImpl)
+
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", c.httpClient.Transport)
+	}
+	if transport.ForceAttemptHTTP2 {
+		t.Error("Expected ForceAttemptHTTP2 to be false")
+	}
+	if transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Errorf("Expected an empty non-nil TLSNextProto, got %v", transport.TLSNextProto)
+	}
+	if transport.Protocols == nil || !transport.Protocols.HTTP1() || transport.Protocols.HTTP2() {
+		t.Errorf("Expected HTTP/1.1 only, got %v", transport.Protocols)
+	}
+
+	// A custom client is left untouched
+	custom := &http.Client{}
+	c = NewClient(WithForceHTTP1(true), WithHTTPClient(custom)).(*clientImpl)
+	if c.httpClient != custom || custom.Transport != nil {
+		t.Error("Expected custom HTTP client to be used unchanged")
+	}
+}
+
+func TestWithDisableKeepAlives(t *testing.T) {
+	c := NewClient(WithDisableKeepAlives(true)).(*clientImpl)
+
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", c.httpClient.Transport)
+	}
+	if !transport.DisableKeepAlives {
+		t.Error("Expected DisableKeepAlives to be true")
+	}
+
+	// Combined with WithForceHTTP1, both settings apply to one transport
+	c = NewClient(WithDisableKeepAlives(true), WithForceHTTP1(true)).(*clientImpl)
+	transport = c.httpClient.Transport.(*http.Transport)
+	if !transport.DisableKeepAlives || transport.Protocols == nil || transport.Protocols.HTTP2() {
+		t.Error("Expected an HTTP/1.1-only transport without keep-alives")
+	}
+
+	if c := NewClient().(*clientImpl); c.httpClient.Transport != nil {
+		t.Errorf("Expected the default transport without the option, got %T", c.httpClient.Transport)
+	}
+}
+
+func TestWithAPIKeyAndAdminKey_SendsBothHeaders(t *testing.T) {
+	var apiKey, adminKey string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-API-Key")
+		adminKey = r.Header.Get("X-Admin-Key")
+		writeJSON(w, http.StatusOK, ModelCard{ID: "model"})
+	}, WithAPIKey("api-secret"), WithAdminKey("admin-secret"), WithAPIKey("api-secret-2"))
+
+	if _, err := client.Models().Get(context.Background()); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+	if apiKey != "api-secret-2" {
+		t.Errorf("Expected latest API key, got %q", apiKey)
+	}
+	if adminKey != "admin-secret" {
+		t.Errorf("Expected admin key, got %q", adminKey)
+	}
+}
+
+func TestWithBearerToken_ReplacesKeys(t *testing.T) {
+	c := NewClient(WithAPIKey("api"), WithAdminKey("admin"), WithBearerToken("token")).(*clientImpl)
+	if _, ok := c.auth.(*BearerTokenAuthenticator); !ok {
+		t.Errorf("Expected bearer token to replace keys, got %#v", c.auth)
+	}
+}
+
+func TestWithAuthenticators_AppliesAll(t *testing.T) {
+	var headers http.Header
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		writeJSON(w, http.StatusOK, ModelCard{ID: "model"})
+	}, WithAuthenticators(
+		&BearerTokenAuthenticator{Token: "gateway-token"},
+		&APIKeyAuthenticator{Key: "api-secret"},
+		&AdminKeyAuthenticator{Key: "admin-secret"},
+	))
+
+	if _, err := client.Models().Get(context.Background()); err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+
+	want := map[string]string{
+		"Authorization": "Bearer gateway-token",
+		"X-API-Key":     "api-secret",
+		"X-Admin-Key":   "admin-secret",
+	}
+	for name, value := range want {
+		if got := headers.Get(name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestWithEndpointOverride(t *testing.T) {
+	var paths []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/proxy/chat" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{})
+	}, WithEndpointOverride(EndpointChat, "proxy/chat"), WithEndpointOverride("unknown", "ignored"))
+
+	if _, err := client.Chat().Create(context.Background(), &ChatCompletionRequest{}); err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+	stream, err := client.Chat().CreateStream(context.Background(), &ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	stream.Close()
+	if _, err := client.Completions().Create(context.Background(), &CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+
+	want := []string{"/proxy/chat", "/proxy/chat", "/v1/completions"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected paths %v, got %v", want, paths)
+	}
+}
+
+func TestWithMaxConcurrent(t *testing.T) {
+	const limit = 2
+	var inFlight, peak int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		writeJSON(w, http.StatusOK, ModelCard{ID: "model"})
+	}, WithMaxConcurrent(limit))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Models().Get(context.Background()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Get returned an error: %v", err)
+	}
+	if peak > limit {
+		t.Errorf("Expected at most %d concurrent requests, got %d", limit, peak)
+	}
+}
+
+func TestWithMaxConcurrent_WaitRespectsContext(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+	}, WithMaxConcurrent(1))
+
+	// An open stream holds the only slot
+	stream, err := client.Chat().CreateStream(context.Background(), &ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Models().Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded while waiting, got %v", err)
+	}
+
+	// Closing the stream frees the slot
+	stream.Close()
+	if _, err := client.Models().Get(context.Background()); err != nil {
+		t.Errorf("Get returned an error after the slot freed: %v", err)
+	}
+}
+
+func TestWithTokenRateLimit(t *testing.T) {
+	// 100 tokens per second; the first response overspends the full bucket
+	// by 20 tokens, which takes 200ms to refill
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		writeJSON(w, http.StatusOK, CompletionResponse{
+			Choices: []CompletionRespChoice{{Text: "ok"}},
+			Usage:   &UsageStats{PromptTokens: 20, CompletionTokens: 6000, TotalTokens: 6020},
+		})
+	}, WithTokenRateLimit(6000))
+
+	if _, err := client.Completions().Create(context.Background(), &CompletionRequest{Prompt: "a"}); err != nil {
+		t.Fatalf("First Create returned an error: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := client.Completions().Create(context.Background(), &CompletionRequest{Prompt: "b"}); err != nil {
+		t.Fatalf("Second Create returned an error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected the second request to be throttled, it took %v", elapsed)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 requests, got %d", calls)
+	}
+
+	// Without a deadline long enough to refill, the wait is abandoned
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.Completions().Create(ctx, &CompletionRequest{Prompt: "c"})
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a *RequestError wrapping context.DeadlineExceeded, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the throttled request not to be sent, got %d requests", calls)
+	}
+}
+
+func TestWithTokenRateLimit_StreamChargesMaxTokens(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}, WithTokenRateLimit(60))
+
+	// One token per second; MaxTokens overspends the bucket by 60 tokens
+	stream, err := client.Chat().CreateStream(context.Background(), &ChatCompletionRequest{MaxTokens: 120})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Chat().CreateStream(ctx, &ChatCompletionRequest{MaxTokens: 1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the second stream to wait for budget, got %v", err)
+	}
+}
+
+func TestWithTokenRateLimit_UnboundedStreamIsThrottled(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}, WithTokenRateLimit(60))
+
+	// Without MaxTokens the stream is charged the whole bucket
+	stream, err := client.Completions().CreateStream(context.Background(), &CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Completions().CreateStream(ctx, &CompletionRequest{Prompt: "hi"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the second stream to wait for budget, got %v", err)
+	}
+}
+
+func TestWithUnloadMethod(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantMethod string
+		wantBody   string
+	}{
+		{"default", nil, http.MethodDelete, ""},
+		{"post", []Option{WithUnloadMethod(http.MethodPost)}, http.MethodPost, "{}"},
+		{"unsupported", []Option{WithUnloadMethod(http.MethodPatch)}, http.MethodDelete, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+				w.WriteHeader(http.StatusOK)
+			}, tt.opts...)
+
+			ctx := context.Background()
+			unloads := []func() error{
+				func() error { return client.Lora().Unload(ctx) },
+				func() error { return client.Templates().Unload(ctx) },
+				func() error { return client.Sampling().UnloadOverride(ctx) },
+			}
+			for _, unload := range unloads {
+				if err := unload(); err != nil {
+					t.Fatalf("Unload returned an error: %v", err)
+				}
+			}
+
+			want := []string{
+				tt.wantMethod + " /v1/loras/active " + tt.wantBody,
+				tt.wantMethod + " /v1/templates/active " + tt.wantBody,
+				tt.wantMethod + " /v1/sampler/overrides/active " + tt.wantBody,
+			}
+			if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+				t.Errorf("Expected requests:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(requests, "\n"))
+			}
+		})
+	}
+}
+
+func TestWithResponseValidation(t *testing.T) {
+	// The chat endpoint answers with a completion, as a misrouted request would
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ChatCompletionResponse{Object: ObjectTextCompletion})
+	}
+
+	client := newTestClient(t, handler, WithResponseValidation(true))
+	_, err := client.Chat().Create(context.Background(), &ChatCompletionRequest{})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "object" {
+		t.Fatalf("Expected a *ValidationError for field object, got %v", err)
+	}
+
+	// Validation is off by default
+	client = newTestClient(t, handler)
+	if _, err := client.Chat().Create(context.Background(), &ChatCompletionRequest{}); err != nil {
+		t.Errorf("Create returned an error without validation: %v", err)
+	}
+}
+
+func TestWithResponseValidation_MatchingObject(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/completions":
+			writeJSON(w, http.StatusOK, CompletionResponse{Object: ObjectTextCompletion})
+		case "/v1/embeddings":
+			writeJSON(w, http.StatusOK, EmbeddingsResponse{Object: ObjectList})
+		}
+	}, WithResponseValidation(true))
+
+	if _, err := client.Completions().Create(context.Background(), &CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Errorf("Completions Create returned an error: %v", err)
+	}
+	if _, err := client.Embeddings().Create(context.Background(), &EmbeddingsRequest{Input: "hi"}); err != nil {
+		t.Errorf("Embeddings Create returned an error: %v", err)
+	}
+}
+
+func TestWithRequestDeduplication(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		writeJSON(w, http.StatusOK, ModelCard{ID: "model"})
+	}, WithRequestDeduplication(true))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			card, err := client.Models().Get(context.Background())
+			if err != nil {
+				t.Errorf("Get returned an error: %v", err)
+				return
+			}
+			if card.ID != "model" {
+				t.Errorf("Expected model ID %q, got %q", "model", card.ID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls)
+	}
+}
+
+func TestContextWithAuth(t *testing.T) {
+	var keys []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		writeJSON(w, http.StatusOK, ModelCard{ID: "model"})
+	}, WithAPIKey("default-key"))
+
+	ctx := context.Background()
+	tenantCtx := ContextWithAuth(ctx, &APIKeyAuthenticator{Key: "tenant-key"})
+	for _, c := range []context.Context{ctx, tenantCtx, ctx} {
+		if _, err := client.Models().Get(c); err != nil {
+			t.Fatalf("Get returned an error: %v", err)
+		}
+	}
+
+	want := []string{"default-key", "tenant-key", "default-key"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected keys %v, got %v", want, keys)
+	}
+}
+
+func TestContextWithMaxRetries(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithRetryPolicy(fastDefaultRetryPolicy()))
+
+	ctx := ContextWithMaxRetries(context.Background(), 0)
+	if _, err := client.Models().List(ctx); ClassifyError(err) != KindServer {
+		t.Fatalf("Expected a server error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call with retries disabled, got %d", calls)
+	}
+
+	// Without the override the policy's retries apply
+	atomic.StoreInt32(&calls, 0)
+	if _, err := client.Models().List(context.Background()); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if calls <= 1 {
+		t.Errorf("Expected the request to be retried, got %d calls", calls)
+	}
+}
+
+func TestWithStrictStreamFlag(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ChatCompletionResponse{})
+	}, WithStrictStreamFlag(true))
+
+	ctx := context.Background()
+	var validationErr *ValidationError
+	_, err := client.Completions().Create(ctx, &CompletionRequest{Prompt: "Hi", Stream: true})
+	if !errors.As(err, &validationErr) || validationErr.Field != "stream" {
+		t.Errorf("Expected stream ValidationError from Create, got %v", err)
+	}
+	_, err = client.Chat().CreateStream(ctx, &ChatCompletionRequest{Stream: false})
+	if !errors.As(err, &validationErr) || validationErr.Field != "stream" {
+		t.Errorf("Expected stream ValidationError from CreateStream, got %v", err)
+	}
+
+	if _, err := client.Chat().Create(ctx, &ChatCompletionRequest{}); err != nil {
+		t.Errorf("Expected a matching stream flag to be accepted, got %v", err)
+	}
+}
+
+func TestWithBaseContext(t *testing.T) {
+	type traceKey struct{}
+
+	started := make(chan struct{}, 1)
+	var traced atomic.Bool
+	httpClient := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		traced.Store(r.Context().Value(traceKey{}) == "span-1")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	base, cancel := context.WithCancel(context.WithValue(context.Background(), traceKey{}, "span-1"))
+	defer cancel()
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+	}, WithHTTPClient(httpClient), WithBaseContext(base))
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Models().Get(context.Background())
+		errCh <- err
+	}()
+
+	<-started
+	if !traced.Load() {
+		t.Error("Expected the base context's values to reach the transport")
+	}
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("In-flight request was not canceled with the base context")
+	}
+
+	if _, err := client.Models().Get(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled for a new request, got %v", err)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
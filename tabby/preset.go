@@ -0,0 +1,128 @@
+package tabby
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ModelPreset is a named, fully-populated model load configuration, used
+// with ModelsService.LoadPreset so common models are always loaded with the
+// same settings.
+type ModelPreset struct {
+	// Name identifies the preset in the registry
+	Name string
+
+	// Request is the load request the preset expands to
+	Request ModelLoadRequest
+}
+
+// Built-in preset names. The model names they load assume the model
+// directory names published by the ExLlamaV2 quantizers; register a preset
+// under the same name to point at a different directory.
+const (
+	// PresetLlama3Instruct8B loads Llama 3 8B Instruct with its full 8K context
+	PresetLlama3Instruct8B = "llama3-8b"
+
+	// PresetMistral7BInstruct32K loads Mistral 7B Instruct with a 32K context and a
+	// Q4 cache to fit consumer GPUs
+	PresetMistral7BInstruct32K = "mistral-7b-32k"
+)
+
+var (
+	modelPresetsMu sync.RWMutex
+	modelPresets   = map[string]ModelPreset{
+		PresetLlama3Instruct8B: {
+			Name: PresetLlama3Instruct8B,
+			Request: ModelLoadRequest{
+				ModelName:      "Meta-Llama-3-8B-Instruct-exl2",
+				MaxSeqLen:      8192,
+				CacheSize:      8192,
+				CacheMode:      CacheModeFP16,
+				PromptTemplate: "llama3",
+			},
+		},
+		PresetMistral7BInstruct32K: {
+			Name: PresetMistral7BInstruct32K,
+			Request: ModelLoadRequest{
+				ModelName:      "Mistral-7B-Instruct-v0.2-exl2",
+				MaxSeqLen:      32768,
+				CacheSize:      32768,
+				CacheMode:      CacheModeQ4,
+				PromptTemplate: "mistral",
+			},
+		},
+	}
+)
+
+// RegisterModelPreset adds req to the preset registry under name, replacing
+// any existing preset with that name, including built-in ones.
+//
+// The request is copied, so later changes to req do not affect the preset.
+// It returns a *ValidationError if name or req.ModelName is empty or if req
+// fails ModelLoadRequest.Validate.
+func RegisterModelPreset(name string, req *ModelLoadRequest) error {
+	if name == "" {
+		return &ValidationError{Field: "name", Message: "preset name must not be empty"}
+	}
+	if req == nil || req.ModelName == "" {
+		return &ValidationError{Field: "model_name", Message: "preset must name a model"}
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	modelPresetsMu.Lock()
+	defer modelPresetsMu.Unlock()
+	modelPresets[name] = ModelPreset{Name: name, Request: copyModelLoadRequest(req)}
+	return nil
+}
+
+// LookupModelPreset returns the preset registered under name. The returned
+// preset is a copy and may be modified freely.
+func LookupModelPreset(name string) (ModelPreset, bool) {
+	modelPresetsMu.RLock()
+	defer modelPresetsMu.RUnlock()
+
+	preset, ok := modelPresets[name]
+	if !ok {
+		return ModelPreset{}, false
+	}
+	preset.Request = copyModelLoadRequest(&preset.Request)
+	return preset, true
+}
+
+// ModelPresetNames returns the names of all registered presets, sorted.
+func ModelPresetNames() []string {
+	modelPresetsMu.RLock()
+	defer modelPresetsMu.RUnlock()
+
+	names := make([]string, 0, len(modelPresets))
+	for name := range modelPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// copyModelLoadRequest returns a copy of req that shares no slices with it.
+func copyModelLoadRequest(req *ModelLoadRequest) ModelLoadRequest {
+	out := *req
+	if req.GPUSplit != nil {
+		out.GPUSplit = append([]float64(nil), req.GPUSplit...)
+	}
+	return out
+}
+
+// presetLoadRequest returns the load request for the named preset, or a
+// *ValidationError if no such preset is registered.
+func presetLoadRequest(name string) (*ModelLoadRequest, error) {
+	preset, ok := LookupModelPreset(name)
+	if !ok {
+		return nil, &ValidationError{
+			Field:   "preset",
+			Message: fmt.Sprintf("unknown model preset %q", name),
+		}
+	}
+	return &preset.Request, nil
+}
@@ -0,0 +1,105 @@
+package tabby
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRegisterModelPreset_LoadPreset(t *testing.T) {
+	const name = "test-preset"
+	req := &ModelLoadRequest{
+		ModelName: "preset-model",
+		MaxSeqLen: 16384,
+		GPUSplit:  []float64{12, 12},
+		CacheMode: CacheModeQ8,
+	}
+	if err := RegisterModelPreset(name, req); err != nil {
+		t.Fatalf("RegisterModelPreset returned an error: %v", err)
+	}
+	// The registry is global, so leave it as other tests expect it
+	t.Cleanup(func() {
+		modelPresetsMu.Lock()
+		defer modelPresetsMu.Unlock()
+		delete(modelPresets, name)
+	})
+	// The registry keeps its own copy
+	req.GPUSplit[0] = 0
+
+	var loaded ModelLoadRequest
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models/load":
+			if err := json.NewDecoder(r.Body).Decode(&loaded); err != nil {
+				t.Errorf("Failed to decode load request: %v", err)
+			}
+			writeJSON(w, http.StatusOK, ModelLoadResponse{Status: "finished"})
+		case "/v1/models/current":
+			writeJSON(w, http.StatusOK, ModelCard{ID: "preset-model"})
+		}
+	})
+
+	if _, err := client.Models().LoadPreset(context.Background(), name); err != nil {
+		t.Fatalf("LoadPreset returned an error: %v", err)
+	}
+	if loaded.ModelName != "preset-model" || loaded.MaxSeqLen != 16384 || loaded.CacheMode != CacheModeQ8 {
+		t.Errorf("Expected the preset's load request, got %+v", loaded)
+	}
+	if len(loaded.GPUSplit) != 2 || loaded.GPUSplit[0] != 12 {
+		t.Errorf("Expected gpu_split [12 12], got %v", loaded.GPUSplit)
+	}
+}
+
+func TestLoadPreset_Unknown(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL.Path)
+	})
+
+	_, err := client.Models().LoadPreset(context.Background(), "no-such-preset")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "preset" {
+		t.Errorf("Expected a *ValidationError for field preset, got %v", err)
+	}
+}
+
+func TestRegisterModelPreset_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		req    *ModelLoadRequest
+		field  string
+	}{
+		{"empty name", "", &ModelLoadRequest{ModelName: "m"}, "name"},
+		{"nil request", "p", nil, "model_name"},
+		{"missing model", "p", &ModelLoadRequest{}, "model_name"},
+		{"bad cache mode", "p", &ModelLoadRequest{ModelName: "m", CacheMode: "Q3"}, "cache_mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterModelPreset(tt.preset, tt.req)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.field {
+				t.Errorf("Expected a *ValidationError for field %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestBuiltinModelPresets(t *testing.T) {
+	for _, name := range []string{PresetLlama3Instruct8B, PresetMistral7BInstruct32K} {
+		preset, ok := LookupModelPreset(name)
+		if !ok {
+			t.Errorf("Expected built-in preset %s", name)
+			continue
+		}
+		if preset.Request.ModelName == "" || preset.Request.MaxSeqLen == 0 {
+			t.Errorf("Expected preset %s to be fully populated, got %+v", name, preset.Request)
+		}
+		if err := preset.Request.Validate(); err != nil {
+			t.Errorf("Preset %s is invalid: %v", name, err)
+		}
+	}
+}
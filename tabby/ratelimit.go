@@ -0,0 +1,103 @@
+package tabby
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenLimiter is a token bucket measured in generated tokens rather than
+// requests. The bucket refills continuously at the configured rate and holds
+// at most one minute of budget. Usage is charged after the fact, so the
+// balance can go negative; requests then wait until at least one token of
+// budget has accrued again.
+type tokenLimiter struct {
+	mu       sync.Mutex
+	rate     float64 // Tokens per second
+	capacity float64
+	balance  float64
+	last     time.Time
+}
+
+// newTokenLimiter returns a limiter with a full bucket of tokensPerMinute.
+func newTokenLimiter(tokensPerMinute int) *tokenLimiter {
+	return &tokenLimiter{
+		rate:     float64(tokensPerMinute) / 60,
+		capacity: float64(tokensPerMinute),
+		balance:  float64(tokensPerMinute),
+		last:     time.Now(),
+	}
+}
+
+// refill adds the budget accrued since the last call. l.mu must be held.
+func (l *tokenLimiter) refill() {
+	now := time.Now()
+	l.balance += now.Sub(l.last).Seconds() * l.rate
+	if l.balance > l.capacity {
+		l.balance = l.capacity
+	}
+	l.last = now
+}
+
+// wait blocks until the balance holds at least one token or ctx is done. A
+// nil limiter never blocks.
+func (l *tokenLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		l.refill()
+		if l.balance >= 1 {
+			l.mu.Unlock()
+			return nil
+		}
+		// Wake just after the deficit has been refilled
+		delay := time.Duration(((1-l.balance)/l.rate)*float64(time.Second)) + time.Millisecond
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return &RequestError{
+				Message: "request canceled while waiting for token budget",
+				Err:     ctx.Err(),
+			}
+		}
+	}
+}
+
+// consume charges n tokens against the budget. A nil limiter ignores it.
+func (l *tokenLimiter) consume(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.balance -= float64(n)
+}
+
+// consumeStream charges a stream up front, before its usage is known: its
+// maxTokens, or when that is unset a full bucket, since an unbounded stream
+// may generate up to the model's context length.
+func (l *tokenLimiter) consumeStream(maxTokens int) {
+	if l == nil {
+		return
+	}
+	if maxTokens <= 0 {
+		maxTokens = int(l.capacity)
+	}
+	l.consume(maxTokens)
+}
+
+// consumeUsage charges the total tokens reported in usage, if any.
+func (l *tokenLimiter) consumeUsage(usage *UsageStats) {
+	if usage != nil {
+		l.consume(usage.TotalTokens)
+	}
+}
@@ -0,0 +1,41 @@
+package tabby
+
+import "net/http"
+
+// redactedValue replaces the values of sensitive headers.
+const redactedValue = "[REDACTED]"
+
+// defaultRedactedHeaders are always masked when headers are logged or tapped.
+var defaultRedactedHeaders = []string{"X-API-Key", "X-Admin-Key", "Authorization"}
+
+// redactHeaders returns a copy of h with the values of sensitive headers
+// masked. It is the single place headers surfaced to callers should pass
+// through, so credentials never leave the process in clear text. The
+// original header is not modified.
+func (c *clientImpl) redactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	if redacted == nil {
+		return nil
+	}
+
+	for _, name := range append(defaultRedactedHeaders, c.redactedHeaders...) {
+		key := http.CanonicalHeaderKey(name)
+		if _, ok := redacted[key]; ok {
+			redacted[key] = []string{redactedValue}
+		}
+	}
+	return redacted
+}
+
+// redactedBeforeRetry calls the WithBeforeRetry function with the failed
+// response's request headers redacted, since callers commonly log it.
+func (c *clientImpl) redactedBeforeRetry(attempt int, resp *http.Response, err error) {
+	if resp != nil && resp.Request != nil {
+		req := *resp.Request
+		req.Header = c.redactHeaders(resp.Request.Header)
+		respCopy := *resp
+		respCopy.Request = &req
+		resp = &respCopy
+	}
+	c.beforeRetry(attempt, resp, err)
+}
@@ -0,0 +1,70 @@
+package tabby
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	c := NewClient(WithRedactedHeaders("x-proxy-token")).(*clientImpl)
+
+	h := http.Header{}
+	h.Set("X-API-Key", "api-secret")
+	h.Set("X-Admin-Key", "admin-secret")
+	h.Set("Authorization", "Bearer token-secret")
+	h.Set("X-Proxy-Token", "proxy-secret")
+	h.Set("Content-Type", "application/json")
+
+	redacted := c.redactHeaders(h)
+
+	for _, name := range []string{"X-API-Key", "X-Admin-Key", "Authorization", "X-Proxy-Token"} {
+		if got := redacted.Get(name); got != redactedValue {
+			t.Errorf("Expected %s to be redacted, got %q", name, got)
+		}
+	}
+	if got := redacted.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected Content-Type to be preserved, got %q", got)
+	}
+	if got := h.Get("X-API-Key"); got != "api-secret" {
+		t.Errorf("Expected original header to be unchanged, got %q", got)
+	}
+}
+
+func TestWithRedactedHeaders_BeforeRetry(t *testing.T) {
+	var calls int32
+	var seen http.Header
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" || r.Header.Get("X-Proxy-Token") != "proxy" {
+			t.Errorf("Expected credentials to reach the server, got %v", r.Header)
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, ModelList{})
+	},
+		WithAPIKey("secret"),
+		WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			// A RoundTripper must not modify the request it is given
+			r = r.Clone(r.Context())
+			r.Header.Set("X-Proxy-Token", "proxy")
+			return http.DefaultTransport.RoundTrip(r)
+		})}),
+		WithRedactedHeaders("X-Proxy-Token"),
+		WithRetryPolicy(fastDefaultRetryPolicy()),
+		WithBeforeRetry(func(attempt int, resp *http.Response, err error) {
+			seen = resp.Request.Header
+		}),
+	)
+
+	if _, err := client.Models().List(context.Background()); err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+	for _, name := range []string{"X-API-Key", "X-Proxy-Token"} {
+		if got := seen.Get(name); got != redactedValue {
+			t.Errorf("Expected %s to be redacted, got %q", name, got)
+		}
+	}
+}
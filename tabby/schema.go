@@ -0,0 +1,198 @@
+package tabby
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// ValidateAgainstSchema checks that data is JSON conforming to schema.
+//
+// This is a lightweight validator for checking model output client-side, not
+// a full JSON Schema implementation. It supports the keywords typically used
+// with JSONSchema requests: type (a name or a list of names), properties,
+// required, items, enum, minimum, maximum, minLength, maxLength, minItems,
+// and maxItems. Other keywords are ignored. The schema may be written with Go
+// literals (such as []string for required) or decoded from JSON.
+//
+// The first violation found is returned as a *ValidationError whose Field is
+// a path to the offending value, such as "$.skills[0]".
+func ValidateAgainstSchema(data []byte, schema map[string]interface{}) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &ValidationError{Field: "$", Message: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	return validateSchemaValue("$", value, schema)
+}
+
+// validateSchemaValue validates value at path against schema.
+func validateSchemaValue(path string, value interface{}, schema map[string]interface{}) error {
+	if t, ok := schema["type"]; ok && !matchesSchemaType(value, t) {
+		return &ValidationError{Field: path, Message: fmt.Sprintf("expected type %v, got %s", t, jsonTypeName(value))}
+	}
+
+	if enum, ok := schema["enum"]; ok && !inSchemaEnum(value, enum) {
+		return &ValidationError{Field: path, Message: fmt.Sprintf("value %v is not one of %v", value, enum)}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if min, ok := schemaNumber(schema["minimum"]); ok && v < min {
+			return &ValidationError{Field: path, Message: fmt.Sprintf("%v is less than minimum %v", v, min)}
+		}
+		if max, ok := schemaNumber(schema["maximum"]); ok && v > max {
+			return &ValidationError{Field: path, Message: fmt.Sprintf("%v is greater than maximum %v", v, max)}
+		}
+
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if min, ok := schemaNumber(schema["minLength"]); ok && length < min {
+			return &ValidationError{Field: path, Message: fmt.Sprintf("length %v is less than minLength %v", length, min)}
+		}
+		if max, ok := schemaNumber(schema["maxLength"]); ok && length > max {
+			return &ValidationError{Field: path, Message: fmt.Sprintf("length %v is greater than maxLength %v", length, max)}
+		}
+
+	case []interface{}:
+		count := float64(len(v))
+		if min, ok := schemaNumber(schema["minItems"]); ok && count < min {
+			return &ValidationError{Field: path, Message: fmt.Sprintf("%v items is fewer than minItems %v", count, min)}
+		}
+		if max, ok := schemaNumber(schema["maxItems"]); ok && count > max {
+			return &ValidationError{Field: path, Message: fmt.Sprintf("%v items is more than maxItems %v", count, max)}
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchemaValue(fmt.Sprintf("%s[%d]", path, i), item, items); err != nil {
+					return err
+				}
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				return &ValidationError{Field: path + "." + name, Message: "required property is missing"}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			// Sorted so the reported violation is deterministic
+			names := make([]string, 0, len(properties))
+			for name := range properties {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				prop, present := v[name]
+				propMap, isMap := properties[name].(map[string]interface{})
+				if !present || !isMap {
+					continue
+				}
+				if err := validateSchemaValue(path+"."+name, prop, propMap); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesSchemaType reports whether value has the JSON Schema type t, which
+// is a type name or a list of names.
+func matchesSchemaType(value interface{}, t interface{}) bool {
+	names := schemaStrings(t)
+	if name, ok := t.(string); ok {
+		names = []string{name}
+	}
+	for _, name := range names {
+		actual := jsonTypeName(value)
+		if actual == name {
+			return true
+		}
+		// JSON Schema integers are numbers without a fractional part
+		if name == "integer" && actual == "number" {
+			if f := value.(float64); f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON Schema type name of a decoded JSON value.
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// inSchemaEnum reports whether value equals one of the enum entries,
+// comparing through JSON so Go literals match decoded values.
+func inSchemaEnum(value interface{}, enum interface{}) bool {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	var entries []interface{}
+	raw, err := json.Marshal(enum)
+	if err != nil || json.Unmarshal(raw, &entries) != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if candidate, err := json.Marshal(entry); err == nil && string(candidate) == string(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaNumber converts a numeric schema keyword to float64.
+func schemaNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// schemaStrings converts a list-of-strings schema keyword, written either as
+// []string or as decoded JSON, to []string.
+func schemaStrings(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		names := make([]string, 0, len(list))
+		for _, item := range list {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
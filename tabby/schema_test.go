@@ -0,0 +1,59 @@
+package tabby
+
+import (
+	"errors"
+	"testing"
+)
+
+// personSchema mirrors the schema used in the JSON schema examples.
+var personSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{"type": "string", "minLength": 1},
+		"age":  map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 150},
+		"skills": map[string]interface{}{
+			"type":     "array",
+			"items":    map[string]interface{}{"type": "string"},
+			"maxItems": 3,
+		},
+	},
+	"required": []string{"name", "age", "skills"},
+}
+
+func TestValidateAgainstSchema(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantField string
+	}{
+		{"conforming", `{"name":"Ada","age":36,"skills":["math","poetry"]}`, ""},
+		{"missing required", `{"name":"Ada","skills":[]}`, "$.age"},
+		{"wrong type", `{"name":"Ada","age":"36","skills":[]}`, "$.age"},
+		{"fractional integer", `{"name":"Ada","age":36.5,"skills":[]}`, "$.age"},
+		{"above maximum", `{"name":"Ada","age":200,"skills":[]}`, "$.age"},
+		{"bad array item", `{"name":"Ada","age":36,"skills":["math",7]}`, "$.skills[1]"},
+		{"too many items", `{"name":"Ada","age":36,"skills":["a","b","c","d"]}`, "$.skills"},
+		{"too short", `{"name":"","age":36,"skills":[]}`, "$.name"},
+		{"not an object", `["Ada"]`, "$"},
+		{"invalid JSON", `{"name":`, "$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAgainstSchema([]byte(tt.data), personSchema)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected *ValidationError, got %v", err)
+			}
+			if validationErr.Field != tt.wantField {
+				t.Errorf("Expected field %q, got %q (%v)", tt.wantField, validationErr.Field, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package tabby
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// schemaStream validates the first choice's content against a schema as it
+// streams, closing the wrapped stream at the first violation.
+type schemaStream struct {
+	ChatCompletionStream
+	scanner *schemaScanner
+	err     error
+}
+
+// Recv receives the next chunk and feeds its first-choice content to the
+// scanner. Once a violation is found the wrapped stream is closed and every
+// later call returns the same *ValidationError. At the end of the stream the
+// content must form a complete value, or a *ValidationError is returned in
+// place of io.EOF.
+func (s *schemaStream) Recv() (*ChatCompletionStreamResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	chunk, err := s.ChatCompletionStream.Recv()
+	if err == io.EOF {
+		if err := s.scanner.finish(); err != nil {
+			s.err = err
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 || choice.Delta == nil || choice.Delta.Content == "" {
+			continue
+		}
+		if err := s.scanner.write(choice.Delta.Content); err != nil {
+			s.err = err
+			_ = s.ChatCompletionStream.Close()
+			return nil, err
+		}
+	}
+	return chunk, nil
+}
+
+// Lexing states for the scalar currently being read.
+const (
+	lexNone = iota
+	lexString
+	lexNumber
+	lexLiteral
+)
+
+// Container states: what a frame expects next.
+const (
+	objKeyOrEnd = iota
+	objKey
+	objColon
+	objValue
+	objCommaOrEnd
+	arrValueOrEnd
+	arrValue
+	arrCommaOrEnd
+)
+
+// scanFrame is an open object or array.
+type scanFrame struct {
+	path   string
+	schema map[string]interface{}
+	start  int // Offset of the opening bracket
+	state  int
+	key    string // Most recent object key
+	index  int    // Next array index
+}
+
+// schemaScanner checks JSON against a schema while it is still being
+// written. Each value's type is checked as soon as its first byte arrives,
+// and each completed value is checked in full with validateSchemaValue, so a
+// violation is reported as early as the text allows. Malformed JSON is
+// reported as a violation too.
+type schemaScanner struct {
+	schema map[string]interface{}
+	buf    []byte
+	stack  []scanFrame
+
+	lex     int
+	escaped bool
+	isKey   bool
+	start   int // Offset of the current scalar
+	path    string
+	scalar  map[string]interface{}
+
+	done bool
+	err  error
+}
+
+func newSchemaScanner(schema map[string]interface{}) *schemaScanner {
+	return &schemaScanner{schema: schema}
+}
+
+// write scans text, returning the first violation found so far.
+func (s *schemaScanner) write(text string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	offset := len(s.buf)
+	s.buf = append(s.buf, text...)
+	for i := offset; i < len(s.buf); i++ {
+		if err := s.step(i); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// finish reports whether the text written forms one complete value.
+func (s *schemaScanner) finish() error {
+	if s.err != nil {
+		return s.err
+	}
+	// A number or literal at the very end has no delimiter to close it
+	if s.lex == lexNumber || s.lex == lexLiteral {
+		s.lex = lexNone
+		if err := s.endScalar(len(s.buf)); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	if !s.done {
+		s.err = &ValidationError{Field: "$", Message: "JSON value is incomplete"}
+	}
+	return s.err
+}
+
+// step scans the byte at offset i.
+func (s *schemaScanner) step(i int) error {
+	c := s.buf[i]
+	switch s.lex {
+	case lexString:
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case c == '\\':
+			s.escaped = true
+		case c == '"':
+			s.lex = lexNone
+			return s.endScalar(i + 1)
+		}
+		return nil
+
+	case lexNumber, lexLiteral:
+		if isLiteralByte(c) {
+			return nil
+		}
+		// The delimiter ends the scalar and is then scanned itself
+		s.lex = lexNone
+		if err := s.endScalar(i); err != nil {
+			return err
+		}
+	}
+
+	if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+		return nil
+	}
+	if len(s.stack) == 0 {
+		if s.done {
+			return s.syntaxError("$", c, i)
+		}
+		return s.beginValue(i, "$", s.schema)
+	}
+
+	f := &s.stack[len(s.stack)-1]
+	switch f.state {
+	case objKeyOrEnd, objKey:
+		if c == '}' && f.state == objKeyOrEnd {
+			return s.closeContainer(i)
+		}
+		if c != '"' {
+			return s.syntaxError(f.path, c, i)
+		}
+		s.lex, s.isKey, s.start = lexString, true, i
+
+	case objColon:
+		if c != ':' {
+			return s.syntaxError(f.path, c, i)
+		}
+		f.state = objValue
+
+	case objValue:
+		f.state = objCommaOrEnd
+		properties, _ := f.schema["properties"].(map[string]interface{})
+		propSchema, _ := properties[f.key].(map[string]interface{})
+		return s.beginValue(i, f.path+"."+f.key, propSchema)
+
+	case arrValueOrEnd, arrValue:
+		if c == ']' && f.state == arrValueOrEnd {
+			return s.closeContainer(i)
+		}
+		f.state = arrCommaOrEnd
+		items, _ := f.schema["items"].(map[string]interface{})
+		path := fmt.Sprintf("%s[%d]", f.path, f.index)
+		f.index++
+		return s.beginValue(i, path, items)
+
+	case objCommaOrEnd, arrCommaOrEnd:
+		end := byte('}')
+		next := objKey
+		if f.state == arrCommaOrEnd {
+			end, next = ']', arrValue
+		}
+		switch c {
+		case ',':
+			f.state = next
+		case end:
+			return s.closeContainer(i)
+		default:
+			return s.syntaxError(f.path, c, i)
+		}
+	}
+	return nil
+}
+
+// beginValue starts the value whose first byte is at offset i, checking its
+// type against schema before any more of it arrives.
+func (s *schemaScanner) beginValue(i int, path string, schema map[string]interface{}) error {
+	c := s.buf[i]
+	var name string
+	switch {
+	case c == '{':
+		name = "object"
+	case c == '[':
+		name = "array"
+	case c == '"':
+		name = "string"
+	case c == 't' || c == 'f':
+		name = "boolean"
+	case c == 'n':
+		name = "null"
+	case c == '-' || (c >= '0' && c <= '9'):
+		// Whether it is an integer is checked once the number is complete
+		name = "number"
+	default:
+		return s.syntaxError(path, c, i)
+	}
+	if t, ok := schema["type"]; ok && !schemaAllowsType(t, name) {
+		return &ValidationError{Field: path, Message: fmt.Sprintf("expected type %v, got %s", t, name)}
+	}
+
+	switch name {
+	case "object":
+		s.stack = append(s.stack, scanFrame{path: path, schema: schema, start: i, state: objKeyOrEnd})
+	case "array":
+		s.stack = append(s.stack, scanFrame{path: path, schema: schema, start: i, state: arrValueOrEnd})
+	case "string":
+		s.lex, s.isKey = lexString, false
+	case "number":
+		s.lex = lexNumber
+	default:
+		s.lex = lexLiteral
+	}
+	s.start, s.path, s.scalar = i, path, schema
+	return nil
+}
+
+// endScalar completes the scalar ending just before offset end.
+func (s *schemaScanner) endScalar(end int) error {
+	raw := s.buf[s.start:end]
+	if s.isKey {
+		s.isKey = false
+		f := &s.stack[len(s.stack)-1]
+		if err := json.Unmarshal(raw, &f.key); err != nil {
+			return &ValidationError{Field: f.path, Message: fmt.Sprintf("invalid JSON: %v", err)}
+		}
+		f.state = objColon
+		return nil
+	}
+
+	if err := s.validateRaw(s.path, raw, s.scalar); err != nil {
+		return err
+	}
+	s.done = len(s.stack) == 0
+	return nil
+}
+
+// closeContainer completes the innermost object or array, whose closing
+// bracket is at offset i.
+func (s *schemaScanner) closeContainer(i int) error {
+	f := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	if err := s.validateRaw(f.path, s.buf[f.start:i+1], f.schema); err != nil {
+		return err
+	}
+	s.done = len(s.stack) == 0
+	return nil
+}
+
+// validateRaw decodes a complete value and validates it against schema.
+func (s *schemaScanner) validateRaw(path string, raw []byte, schema map[string]interface{}) error {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return &ValidationError{Field: path, Message: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	return validateSchemaValue(path, value, schema)
+}
+
+func (s *schemaScanner) syntaxError(path string, c byte, offset int) error {
+	return &ValidationError{Field: path, Message: fmt.Sprintf("invalid JSON: unexpected %q at offset %d", c, offset)}
+}
+
+// isLiteralByte reports whether c can continue a number or a literal.
+func isLiteralByte(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || c == '+' || c == '-' || c == '.' || c == 'E'
+}
+
+// schemaAllowsType reports whether the JSON Schema type t, a type name or a
+// list of names, admits a value of the given type. A number is admitted by
+// "integer" until it is known to have a fractional part.
+func schemaAllowsType(t interface{}, name string) bool {
+	names := schemaStrings(t)
+	if single, ok := t.(string); ok {
+		names = []string{single}
+	}
+	for _, allowed := range names {
+		if allowed == name || (allowed == "integer" && name == "number") {
+			return true
+		}
+	}
+	return false
+}
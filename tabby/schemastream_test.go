@@ -0,0 +1,105 @@
+package tabby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSchemaScanner(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantField string // Empty for valid input
+		failAt    int    // Bytes written when the violation is reported; 0 means at finish
+	}{
+		{"valid", `{"name": "Ada", "age": 36, "skills": ["a", "b\"c"]}`, "", 0},
+		{"wrong type at start", `{"name": 42, "age": 1, "skills": []}`, "$.name", len(`{"name": 4`)},
+		{"wrong item type", `{"skills": ["a", 1], "name": "x", "age": 1}`, "$.skills[1]", len(`{"skills": ["a", 1`)},
+		{"fractional integer", `{"age": 3.5, "name": "x", "skills": []}`, "$.age", len(`{"age": 3.5,`)},
+		{"below minimum", `{"age": -1, "name": "x", "skills": []}`, "$.age", len(`{"age": -1,`)},
+		{"missing required", `{"name": "Ada", "age": 36}`, "$.skills", len(`{"name": "Ada", "age": 36}`)},
+		{"malformed", `{"name" "Ada"}`, "$", len(`{"name" "`)},
+		{"incomplete", `{"name": "Ada", "age": 36, "skills": []`, "$", 0},
+		{"trailing data", `{"name": "Ada", "age": 36, "skills": []} {`, "$", len(`{"name": "Ada", "age": 36, "skills": []} {`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := newSchemaScanner(personSchema)
+			var err error
+			written := 0
+			// Feed one byte at a time to find exactly where the violation is caught
+			for written < len(tt.input) && err == nil {
+				err = scanner.write(tt.input[written : written+1])
+				written++
+			}
+			if err == nil {
+				err = scanner.finish()
+				written = 0
+			}
+
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Expected valid input, got %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+				t.Fatalf("Expected ValidationError for %s, got %v", tt.wantField, err)
+			}
+			if written != tt.failAt {
+				t.Errorf("Expected violation after %d bytes, got %d", tt.failAt, written)
+			}
+		})
+	}
+}
+
+func TestChatService_CreateStreamSchema_AbortsEarly(t *testing.T) {
+	disconnected := make(chan struct{})
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{`{"name": `, `42`} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", delta)
+		}
+		w.(http.Flusher).Flush()
+
+		// Keep generating until the client goes away
+		select {
+		case <-r.Context().Done():
+			close(disconnected)
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	stream, err := client.Chat().CreateStreamSchema(context.Background(), &ChatCompletionRequest{}, personSchema)
+	if err != nil {
+		t.Fatalf("CreateStreamSchema returned an error: %v", err)
+	}
+	defer stream.Close()
+
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	var validationErr *ValidationError
+	if err == io.EOF || !errors.As(err, &validationErr) || validationErr.Field != "$.name" {
+		t.Fatalf("Expected ValidationError for $.name, got %v", err)
+	}
+	if _, again := stream.Recv(); again != err {
+		t.Errorf("Expected the violation to be returned again, got %v", again)
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the stream to be closed on the violation")
+	}
+}
@@ -0,0 +1,65 @@
+package tabby
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// embeddingBatchSize is the number of corpus texts sent per embeddings
+// request by RankBySimilarity.
+const embeddingBatchSize = 64
+
+// ScoredText is a corpus entry ranked by RankBySimilarity.
+type ScoredText struct {
+	// Text is the corpus entry
+	Text string
+
+	// Index is the entry's position in the corpus
+	Index int
+
+	// Score is the cosine similarity between the entry and the query, from
+	// -1 to 1 with higher meaning more similar
+	Score float64
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b. It
+// returns 0 if the vectors differ in length or either has zero magnitude.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// rankByScore scores each corpus vector against query and returns the topK
+// best matches, highest score first. Ties keep corpus order. topK <= 0
+// returns every entry.
+func rankByScore(query []float32, corpus []string, vectors [][]float32, topK int) ([]ScoredText, error) {
+	results := make([]ScoredText, len(corpus))
+	for i, vector := range vectors {
+		if len(vector) != len(query) {
+			return nil, fmt.Errorf("embedding for corpus[%d] has %d dimensions, query has %d", i, len(vector), len(query))
+		}
+		results[i] = ScoredText{Text: corpus[i], Index: i, Score: CosineSimilarity(query, vector)}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if topK > 0 && topK < len(results) {
+		results = results[:topK]
+	}
+	return results, nil
+}
@@ -0,0 +1,98 @@
+package tabby
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"identical", []float32{1, 2}, []float32{1, 2}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite", []float32{1, 0}, []float32{-2, 0}, -1},
+		{"length mismatch", []float32{1}, []float32{1, 0}, 0},
+		{"zero vector", []float32{0, 0}, []float32{1, 0}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEmbeddingsService_RankBySimilarity(t *testing.T) {
+	vectors := map[string][]float64{
+		"cats":    {1, 0, 0},
+		"kittens": {0.9, 0.1, 0},
+		"dogs":    {0.5, 0.5, 0},
+		"taxes":   {0, 0, 1},
+	}
+
+	var requests int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req struct {
+			Input interface{} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+
+		var inputs []string
+		switch input := req.Input.(type) {
+		case string:
+			inputs = []string{input}
+		case []interface{}:
+			for _, item := range input {
+				inputs = append(inputs, item.(string))
+			}
+		}
+
+		// Answer in reverse order to check results are placed by index
+		response := EmbeddingsResponse{Object: ObjectList}
+		for i := len(inputs) - 1; i >= 0; i-- {
+			response.Data = append(response.Data, EmbeddingObject{Embedding: vectors[inputs[i]], Index: i})
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+
+	corpus := []string{"taxes", "dogs", "kittens"}
+	results, err := client.Embeddings().RankBySimilarity(context.Background(), "cats", corpus, 2)
+	if err != nil {
+		t.Fatalf("RankBySimilarity returned an error: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %+v", results)
+	}
+	if results[0].Text != "kittens" || results[0].Index != 2 || results[1].Text != "dogs" || results[1].Index != 1 {
+		t.Errorf("Expected kittens then dogs, got %+v", results)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("Expected scores in descending order, got %+v", results)
+	}
+	if requests != 2 {
+		t.Errorf("Expected one query and one corpus request, got %d", requests)
+	}
+}
+
+func TestEmbeddingsService_RankBySimilarity_EmptyCorpus(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL.Path)
+	})
+
+	results, err := client.Embeddings().RankBySimilarity(context.Background(), "query", nil, 3)
+	if err != nil || results != nil {
+		t.Errorf("Expected no results and no error, got %v, %v", results, err)
+	}
+}
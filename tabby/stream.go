@@ -0,0 +1,668 @@
+package tabby
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// streamChannelBuffer is the capacity of the item channel returned by StreamChannel.
+const streamChannelBuffer = 16
+
+// StreamChannel drains a stream into a channel for range-over-channel consumption.
+//
+// Items are delivered on the first returned channel. When the stream ends, any
+// terminal error other than io.EOF is sent on the error channel, then both
+// channels are closed. If ctx is canceled, the producer stops, reports the
+// context error, and closes the stream. The stream is always closed once the
+// producer exits, so callers do not need to close it themselves.
+//
+// Example:
+//
+//	items, errs := tabby.StreamChannel(ctx, stream)
+//	for item := range items {
+//	    fmt.Print(item.Choices[0].Text)
+//	}
+//	if err := <-errs; err != nil {
+//	    log.Fatal(err)
+//	}
+func StreamChannel[T any](ctx context.Context, stream Stream[T]) (<-chan T, <-chan error) {
+	items := make(chan T, streamChannelBuffer)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+
+	// Close the stream on cancellation so a blocked Recv returns
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = stream.Close()
+		case <-done:
+		}
+	}()
+
+	go func() {
+		defer close(errs)
+		defer close(items)
+		defer close(done)
+		defer stream.Close()
+
+		for {
+			item, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() != nil {
+					errs <- ctx.Err()
+				} else if err != io.EOF {
+					errs <- err
+				}
+				return
+			}
+
+			select {
+			case items <- item:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return items, errs
+}
+
+// CollectCompletionStream reads a completion stream to the end and assembles
+// the chunks into a single CompletionResponse, concatenating each choice's
+// text. The stream is closed before returning.
+//
+// A stream that ends cleanly carries a finish reason for every choice. If the
+// stream reaches EOF while any choice still lacks one, the output was most
+// likely cut off (for example by a dropped connection), and the assembled
+// response is returned together with ErrStreamTruncated.
+func CollectCompletionStream(stream CompletionStream) (*CompletionResponse, error) {
+	defer stream.Close()
+
+	response := &CompletionResponse{Object: ObjectTextCompletion}
+	var texts []*strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		response.ID, response.Created, response.Model = chunk.ID, chunk.Created, chunk.Model
+		for _, choice := range chunk.Choices {
+			if choice.Index < 0 {
+				continue
+			}
+			response.Choices, texts = growChoices(response.Choices, texts, choice.Index, func(i int) CompletionRespChoice {
+				return CompletionRespChoice{Index: i}
+			})
+
+			texts[choice.Index].WriteString(choice.Text)
+			if choice.FinishReason != "" {
+				response.Choices[choice.Index].FinishReason = choice.FinishReason
+			}
+		}
+	}
+
+	truncated := len(response.Choices) == 0
+	for i := range response.Choices {
+		response.Choices[i].Text = texts[i].String()
+		if response.Choices[i].FinishReason == "" {
+			truncated = true
+		}
+	}
+	if truncated {
+		return response, ErrStreamTruncated
+	}
+	return response, nil
+}
+
+// growChoices extends the assembled choices of a stream, and the builders
+// collecting their text, to cover index. Streamed choices may arrive in any
+// order, so missing choices in between are created too, each by newChoice.
+// The builders are pointers, since a strings.Builder must not be copied
+// once written to.
+func growChoices[C any](choices []C, texts []*strings.Builder, index int, newChoice func(index int) C) ([]C, []*strings.Builder) {
+	for len(choices) <= index {
+		choices = append(choices, newChoice(len(choices)))
+		texts = append(texts, &strings.Builder{})
+	}
+	return choices, texts
+}
+
+// CollectChatStream reads a chat completion stream to the end and assembles
+// the chunks into a single ChatCompletionResponse, concatenating each
+// choice's content. The stream is closed before returning.
+func CollectChatStream(stream ChatCompletionStream) (*ChatCompletionResponse, error) {
+	defer stream.Close()
+	return assembleChatStream(stream, nil)
+}
+
+// CollectChatStreamWithSchema is CollectChatStream followed by a check of the
+// first choice's content against schema with ValidateAgainstSchema, catching
+// models that drift from a JSONSchema sent with the request. If the content
+// does not conform, the assembled response is returned together with the
+// validation error.
+func CollectChatStreamWithSchema(stream ChatCompletionStream, schema map[string]interface{}) (*ChatCompletionResponse, error) {
+	response, err := CollectChatStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, ok := response.FirstMessage()
+	if !ok {
+		return response, &ValidationError{Field: "$", Message: "stream returned no choices"}
+	}
+	if err := ValidateAgainstSchema([]byte(contentText(msg.Content)), schema); err != nil {
+		return response, err
+	}
+	return response, nil
+}
+
+// assembleChatStream reads stream until EOF and assembles the chunks into a
+// response, calling onDelta (if non-nil) with each content delta of the
+// first choice. It does not close the stream.
+func assembleChatStream(stream ChatCompletionStream, onDelta func(delta string) error) (*ChatCompletionResponse, error) {
+	response := &ChatCompletionResponse{Object: ObjectChatCompletion}
+	var contents []*strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		response.ID, response.Created, response.Model = chunk.ID, chunk.Created, chunk.Model
+		for _, choice := range chunk.Choices {
+			if choice.Index < 0 {
+				continue
+			}
+			response.Choices, contents = growChoices(response.Choices, contents, choice.Index, func(i int) ChatCompletionRespChoice {
+				return ChatCompletionRespChoice{Index: i, Message: ChatMessage{Role: ChatMessageRoleAssistant}}
+			})
+			assembled := &response.Choices[choice.Index]
+
+			if choice.FinishReason != "" {
+				assembled.FinishReason = choice.FinishReason
+			}
+			if choice.Logprobs != nil {
+				if assembled.Logprobs == nil {
+					assembled.Logprobs = &ChatCompletionLogprobs{}
+				}
+				assembled.Logprobs.Content = append(assembled.Logprobs.Content, choice.Logprobs.Content...)
+			}
+			if choice.Delta == nil {
+				continue
+			}
+			if choice.Delta.Role != "" {
+				assembled.Message.Role = choice.Delta.Role
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+
+			contents[choice.Index].WriteString(choice.Delta.Content)
+			if choice.Index == 0 && onDelta != nil {
+				if err := onDelta(choice.Delta.Content); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for i := range response.Choices {
+		response.Choices[i].Message.Content = contents[i].String()
+	}
+	return response, nil
+}
+
+// TimedStream wraps a stream and records when each item is received, for
+// profiling generation latency. It implements Stream, so it can be passed
+// anywhere the wrapped stream could.
+//
+// The clock starts when the TimedStream is created, so create it right after
+// CreateStream returns for TimeToFirstToken to include the server's prompt
+// processing time.
+//
+// Example:
+//
+//	timed := tabby.NewTimedStream(stream)
+//	for {
+//	    if _, err := timed.Recv(); err != nil {
+//	        break
+//	    }
+//	}
+//	fmt.Println(timed.TimeToFirstToken(), timed.InterTokenLatencies())
+type TimedStream[T any] struct {
+	stream   Stream[T]
+	now      func() time.Time
+	start    time.Time
+	received []time.Time
+}
+
+// NewTimedStream wraps stream and starts the clock. The caller closes the
+// returned TimedStream instead of the wrapped stream.
+func NewTimedStream[T any](stream Stream[T]) *TimedStream[T] {
+	return newTimedStream(stream, time.Now)
+}
+
+func newTimedStream[T any](stream Stream[T], now func() time.Time) *TimedStream[T] {
+	return &TimedStream[T]{stream: stream, now: now, start: now()}
+}
+
+// Recv receives the next item from the wrapped stream, recording its arrival
+// time on success.
+func (s *TimedStream[T]) Recv() (T, error) {
+	item, err := s.stream.Recv()
+	if err == nil {
+		s.received = append(s.received, s.now())
+	}
+	return item, err
+}
+
+// Close closes the wrapped stream.
+func (s *TimedStream[T]) Close() error {
+	return s.stream.Close()
+}
+
+// TimeToFirstToken returns the time from creating the TimedStream to the
+// first received item, or zero if nothing has been received.
+func (s *TimedStream[T]) TimeToFirstToken() time.Duration {
+	if len(s.received) == 0 {
+		return 0
+	}
+	return s.received[0].Sub(s.start)
+}
+
+// InterTokenLatencies returns the intervals between consecutive received
+// items. It has one entry fewer than the number of items received.
+func (s *TimedStream[T]) InterTokenLatencies() []time.Duration {
+	if len(s.received) < 2 {
+		return nil
+	}
+	latencies := make([]time.Duration, len(s.received)-1)
+	for i := 1; i < len(s.received); i++ {
+		latencies[i-1] = s.received[i].Sub(s.received[i-1])
+	}
+	return latencies
+}
+
+// ChatEventType identifies the kind of a ChatEvent.
+type ChatEventType string
+
+const (
+	// ChatEventRole announces the role of a choice's message. It is always
+	// delivered before that choice's first content event.
+	ChatEventRole ChatEventType = "role"
+
+	// ChatEventContent carries a piece of message text.
+	ChatEventContent ChatEventType = "content"
+
+	// ChatEventFinish reports why generation of a choice ended.
+	ChatEventFinish ChatEventType = "finish"
+)
+
+// ChatEvent is a single typed event from a chat completion stream. Only the
+// payload field matching Type is set.
+type ChatEvent struct {
+	Type  ChatEventType
+	Index int // Choice index
+
+	Role         ChatMessageRole // Set for ChatEventRole
+	Content      string          // Set for ChatEventContent
+	FinishReason string          // Set for ChatEventFinish
+}
+
+// ChatEvents adapts a chat completion stream into a stream of typed events,
+// so consumers do not need to inspect Delta fields.
+//
+// Each chunk is split into role, content, and finish events in that order.
+// If a server sends content for a choice without first sending its role, an
+// assistant role event is emitted ahead of it. Closing the returned stream
+// closes the wrapped one.
+//
+// Example:
+//
+//	events := tabby.ChatEvents(stream)
+//	defer events.Close()
+//	for {
+//	    event, err := events.Recv()
+//	    if err != nil {
+//	        break
+//	    }
+//	    switch event.Type {
+//	    case tabby.ChatEventRole:
+//	        fmt.Printf("[%s] ", event.Role)
+//	    case tabby.ChatEventContent:
+//	        fmt.Print(event.Content)
+//	    }
+//	}
+func ChatEvents(stream ChatCompletionStream) Stream[ChatEvent] {
+	return &chatEventStream{stream: stream, roles: make(map[int]bool)}
+}
+
+// chatEventStream implements the stream returned by ChatEvents.
+type chatEventStream struct {
+	stream  ChatCompletionStream
+	pending []ChatEvent
+	roles   map[int]bool // Choice indexes whose role has been emitted
+}
+
+func (s *chatEventStream) Recv() (ChatEvent, error) {
+	for len(s.pending) == 0 {
+		chunk, err := s.stream.Recv()
+		if err != nil {
+			return ChatEvent{}, err
+		}
+		for _, choice := range chunk.Choices {
+			s.split(choice)
+		}
+	}
+
+	event := s.pending[0]
+	s.pending = s.pending[1:]
+	return event, nil
+}
+
+// split queues the events carried by one streamed choice.
+func (s *chatEventStream) split(choice ChatCompletionStreamChoice) {
+	if delta := choice.Delta; delta != nil {
+		if delta.Role != "" || (delta.Content != "" && !s.roles[choice.Index]) {
+			role := delta.Role
+			if role == "" {
+				role = ChatMessageRoleAssistant
+			}
+			s.roles[choice.Index] = true
+			s.pending = append(s.pending, ChatEvent{Type: ChatEventRole, Index: choice.Index, Role: role})
+		}
+		if delta.Content != "" {
+			s.pending = append(s.pending, ChatEvent{Type: ChatEventContent, Index: choice.Index, Content: delta.Content})
+		}
+	}
+	if choice.FinishReason != "" {
+		s.pending = append(s.pending, ChatEvent{Type: ChatEventFinish, Index: choice.Index, FinishReason: choice.FinishReason})
+	}
+}
+
+func (s *chatEventStream) Close() error {
+	return s.stream.Close()
+}
+
+// JSONStreamDecoder accumulates the text of a completion stream that
+// generates JSON (for example with CompletionRequest.JSONSchema) and decodes
+// it once the top-level object or array is complete.
+//
+// Completion is detected by scanning for the balanced closing brace or
+// bracket of the first JSON value, ignoring brackets inside strings. Text
+// after the terminus is kept but not decoded.
+//
+// Example:
+//
+//	dec := tabby.NewJSONStreamDecoder(stream)
+//	for {
+//	    if _, err := dec.Next(); err != nil {
+//	        break
+//	    }
+//	}
+//	var result MyType
+//	if !dec.UnmarshalWhenComplete(&result) {
+//	    log.Fatalf("incomplete JSON: %s", dec.Text())
+//	}
+type JSONStreamDecoder struct {
+	stream CompletionStream
+	text   strings.Builder
+
+	// Scanner state for the first JSON value
+	start    int
+	end      int
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+// NewJSONStreamDecoder creates a JSONStreamDecoder reading from stream. The
+// caller remains responsible for closing the stream.
+func NewJSONStreamDecoder(stream CompletionStream) *JSONStreamDecoder {
+	return &JSONStreamDecoder{stream: stream, start: -1, end: -1}
+}
+
+// Next receives the next chunk from the stream, appends the text of its
+// first choice, and returns that fragment. It returns io.EOF when the stream
+// ends.
+func (d *JSONStreamDecoder) Next() (string, error) {
+	chunk, err := d.stream.Recv()
+	if err != nil {
+		return "", err
+	}
+	if len(chunk.Choices) == 0 {
+		return "", nil
+	}
+
+	fragment := chunk.Choices[0].Text
+	d.scan(fragment)
+	d.text.WriteString(fragment)
+	return fragment, nil
+}
+
+// scan advances the scanner over fragment, which is appended at the current
+// end of the accumulated text.
+func (d *JSONStreamDecoder) scan(fragment string) {
+	if d.end >= 0 {
+		return
+	}
+
+	offset := d.text.Len()
+	for i := 0; i < len(fragment); i++ {
+		c := fragment[i]
+		if d.start < 0 {
+			if c == '{' || c == '[' {
+				d.start = offset + i
+				d.depth = 1
+			}
+			continue
+		}
+
+		if d.inString {
+			switch {
+			case d.escaped:
+				d.escaped = false
+			case c == '\\':
+				d.escaped = true
+			case c == '"':
+				d.inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			d.inString = true
+		case '{', '[':
+			d.depth++
+		case '}', ']':
+			d.depth--
+			if d.depth == 0 {
+				d.end = offset + i + 1
+				return
+			}
+		}
+	}
+}
+
+// Text returns all text accumulated so far.
+func (d *JSONStreamDecoder) Text() string {
+	return d.text.String()
+}
+
+// Complete reports whether the first JSON value in the text is balanced.
+func (d *JSONStreamDecoder) Complete() bool {
+	return d.end >= 0
+}
+
+// UnmarshalWhenComplete decodes the first JSON value into v if it is
+// complete, reporting whether v was populated. It returns false while the
+// value is still being generated or if the balanced text is not valid JSON.
+func (d *JSONStreamDecoder) UnmarshalWhenComplete(v interface{}) bool {
+	if !d.Complete() {
+		return false
+	}
+	return json.Unmarshal([]byte(d.text.String()[d.start:d.end]), v) == nil
+}
+
+// utf8Carry holds back incomplete UTF-8 sequences at the end of JSON string
+// values in stream events and prepends them to the value at the same JSON
+// path in the next event, so a character split across two chunks is
+// delivered whole instead of as replacement characters.
+//
+// Both raw multi-byte sequences and a trailing high surrogate escape
+// ("\ud83d" awaiting its "\ude00") are carried over. Bytes still held when the
+// stream ends are dropped, since they never formed a valid character.
+type utf8Carry struct {
+	pending map[string][]byte
+}
+
+func newUTF8Carry() *utf8Carry {
+	return &utf8Carry{pending: make(map[string][]byte)}
+}
+
+// jsonFrame tracks the position within one JSON object or array.
+type jsonFrame struct {
+	array     bool
+	index     int
+	key       string
+	expectKey bool
+}
+
+// apply rewrites the JSON event data, moving incomplete trailing sequences
+// of string values into the carry and prepending previously held ones.
+func (c *utf8Carry) apply(data []byte) []byte {
+	out := make([]byte, 0, len(data)+8)
+	var stack []jsonFrame
+
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch b {
+		case '{':
+			stack = append(stack, jsonFrame{expectKey: true})
+		case '[':
+			stack = append(stack, jsonFrame{array: true})
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if n := len(stack); n > 0 {
+				if stack[n-1].array {
+					stack[n-1].index++
+				} else {
+					stack[n-1].expectKey = true
+				}
+			}
+		case ':':
+			if n := len(stack); n > 0 {
+				stack[n-1].expectKey = false
+			}
+		case '"':
+			end := stringEnd(data, i+1)
+			value := data[i+1 : end]
+			i = end
+
+			if n := len(stack); n > 0 && !stack[n-1].array && stack[n-1].expectKey {
+				stack[n-1].key = string(value)
+				out = append(out, '"')
+				out = append(out, value...)
+				out = append(out, '"')
+				continue
+			}
+
+			path := jsonPath(stack)
+			if held, ok := c.pending[path]; ok {
+				value = append(append([]byte{}, held...), value...)
+				delete(c.pending, path)
+			}
+			complete, held := splitIncompleteUTF8(value)
+			if len(held) > 0 {
+				c.pending[path] = append([]byte{}, held...)
+			}
+
+			out = append(out, '"')
+			out = append(out, complete...)
+			out = append(out, '"')
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// stringEnd returns the index of the quote closing the JSON string starting
+// at start, or len(data) if it is unterminated.
+func stringEnd(data []byte, start int) int {
+	for i := start; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(data)
+}
+
+// jsonPath renders the current position as a key such as "choices.0.delta.content".
+func jsonPath(stack []jsonFrame) string {
+	var sb strings.Builder
+	for i, frame := range stack {
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		if frame.array {
+			sb.WriteString(strconv.Itoa(frame.index))
+		} else {
+			sb.WriteString(frame.key)
+		}
+	}
+	return sb.String()
+}
+
+// splitIncompleteUTF8 splits the raw contents of a JSON string into the
+// complete part and a trailing incomplete UTF-8 sequence or unpaired high
+// surrogate escape.
+func splitIncompleteUTF8(value []byte) (complete, held []byte) {
+	// A trailing \uD800-\uDBFF escape needs its low surrogate from the next chunk
+	if n := len(value); n >= 6 && value[n-6] == '\\' && (value[n-5] == 'u' || value[n-5] == 'U') {
+		if r, err := strconv.ParseUint(string(value[n-4:]), 16, 32); err == nil && r >= 0xD800 && r <= 0xDBFF && !escapedBackslash(value, n-6) {
+			return value[:n-6], value[n-6:]
+		}
+	}
+
+	// Find the start of the last rune and hold it back if it is incomplete
+	for i := len(value) - 1; i >= 0 && i >= len(value)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(value[i]) {
+			if value[i] >= utf8.RuneSelf && !utf8.FullRune(value[i:]) {
+				return value[:i], value[i:]
+			}
+			break
+		}
+	}
+	return value, nil
+}
+
+// escapedBackslash reports whether the backslash at pos is itself escaped
+// by an odd number of preceding backslashes.
+func escapedBackslash(value []byte, pos int) bool {
+	count := 0
+	for i := pos - 1; i >= 0 && value[i] == '\\'; i-- {
+		count++
+	}
+	return count%2 == 1
+}
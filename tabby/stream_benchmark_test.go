@@ -0,0 +1,40 @@
+package tabby
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// BenchmarkStream_Setup compares opening and closing a stream, which takes
+// its read buffer from streamReaderPool, with allocating a fresh buffer per
+// stream.
+func BenchmarkStream_Setup(b *testing.B) {
+	newResponse := func() *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("data: {}\n\n")),
+		}
+	}
+
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			stream := newGenericStream[struct{}](context.Background(), newResponse())
+			stream.Close()
+		}
+	})
+
+	b.Run("Unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			resp := newResponse()
+			_ = bufio.NewReader(resp.Body)
+			resp.Body.Close()
+		}
+	})
+}
@@ -0,0 +1,830 @@
+package tabby
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestStream creates a stream of T reading SSE data from body.
+func newTestStream[T any](ctx context.Context, body io.ReadCloser) *GenericStream[T] {
+	return newGenericStream[T](ctx, &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       body,
+	})
+}
+
+type testItem struct {
+	N int `json:"n"`
+}
+
+func TestStreamChannel_DrainsStream(t *testing.T) {
+	var sse bytes.Buffer
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&sse, "data: {\"n\":%d}\n\n", i)
+	}
+
+	ctx := context.Background()
+	stream := newTestStream[testItem](ctx, io.NopCloser(&sse))
+	items, errs := StreamChannel[testItem](ctx, stream)
+
+	var got []int
+	for item := range items {
+		got = append(got, item.N)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("Expected 5 items, got %d", len(got))
+	}
+	for i, n := range got {
+		if n != i {
+			t.Errorf("Item %d: expected %d, got %d", i, i, n)
+		}
+	}
+}
+
+func TestStreamChannel_ReportsStreamError(t *testing.T) {
+	ctx := context.Background()
+	stream := newTestStream[testItem](ctx, io.NopCloser(bytes.NewBufferString("data: not-json\n\n")))
+	items, errs := StreamChannel[testItem](ctx, stream)
+
+	for range items {
+		t.Error("Expected no items")
+	}
+
+	var streamErr *StreamError
+	if err := <-errs; !errors.As(err, &streamErr) {
+		t.Errorf("Expected *StreamError, got %v", err)
+	}
+}
+
+func TestStreamChannel_ContextCancel(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newTestStream[testItem](context.Background(), pr)
+	items, errs := StreamChannel[testItem](ctx, stream)
+
+	go func() {
+		_, _ = pw.Write([]byte("data: {\"n\":1}\n\n"))
+	}()
+
+	if item := <-items; item.N != 1 {
+		t.Fatalf("Expected first item 1, got %d", item.N)
+	}
+
+	// The producer is now blocked in Recv waiting for more data
+	cancel()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Producer did not exit after context cancel")
+	}
+
+	if _, ok := <-items; ok {
+		t.Error("Expected items channel to be closed")
+	}
+
+	// The stream should have been closed, so writes fail
+	if _, err := pw.Write([]byte("data: {}\n\n")); err == nil {
+		t.Error("Expected write to a closed stream to fail")
+	}
+}
+
+func TestGenericStream_DeliversEventOnBlankLine(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	stream := newTestStream[testItem](context.Background(), pr)
+	defer stream.Close()
+
+	for i := 1; i <= 2; i++ {
+		results := make(chan testItem, 1)
+		go func() {
+			item, err := stream.Recv()
+			if err != nil {
+				t.Errorf("Recv returned an error: %v", err)
+			}
+			results <- item
+		}()
+
+		// The data line alone must not complete the event
+		if _, err := fmt.Fprintf(pw, "data: {\"n\":%d}\n", i); err != nil {
+			t.Fatalf("Failed to write data line: %v", err)
+		}
+		select {
+		case item := <-results:
+			t.Fatalf("Event %d delivered before its blank line: %+v", i, item)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		// The blank line completes the event even though the pipe stays open
+		if _, err := pw.Write([]byte("\n")); err != nil {
+			t.Fatalf("Failed to write blank line: %v", err)
+		}
+		select {
+		case item := <-results:
+			if item.N != i {
+				t.Errorf("Expected item %d, got %d", i, item.N)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Event %d not delivered after its blank line", i)
+		}
+	}
+}
+
+func TestJSONStreamDecoder_DecodesFragments(t *testing.T) {
+	fragments := []string{`Here: {"name": "Ada`, `", "tags": ["x", "}"`, `], "n": {"v": 2`, `}}`, ` trailing`}
+	var sse bytes.Buffer
+	for _, fragment := range fragments {
+		data, _ := json.Marshal(CompletionStreamResponse{Choices: []CompletionStreamChoice{{Text: fragment}}})
+		fmt.Fprintf(&sse, "data: %s\n\n", data)
+	}
+
+	dec := NewJSONStreamDecoder(newTestStream[*CompletionStreamResponse](context.Background(), io.NopCloser(&sse)))
+
+	var result struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+		N    struct {
+			V int `json:"v"`
+		} `json:"n"`
+	}
+	for i := 0; ; i++ {
+		_, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next returned an error: %v", err)
+		}
+		if i < 3 && dec.UnmarshalWhenComplete(&result) {
+			t.Fatalf("Expected incomplete JSON after fragment %d, got %q", i, dec.Text())
+		}
+	}
+
+	if !dec.UnmarshalWhenComplete(&result) {
+		t.Fatalf("Expected complete JSON, got %q", dec.Text())
+	}
+	if result.Name != "Ada" || len(result.Tags) != 2 || result.Tags[1] != "}" || result.N.V != 2 {
+		t.Errorf("Unexpected decoded value: %+v", result)
+	}
+}
+
+func TestGenericStream_DataLines(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []int
+	}{
+		{"one line per event", "data: {\"n\":1}\n\ndata: {\"n\":2}\n\n", []int{1, 2}},
+		{"missing separator", "data: {\"n\":1}\ndata: {\"n\":2}\n\ndata: {\"n\":3}\n\n", []int{1, 2, 3}},
+		{"one document over two lines", "data: {\"n\":\ndata: 4}\n\n", []int{4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := newTestStream[testItem](context.Background(), io.NopCloser(strings.NewReader(tt.body)))
+			defer stream.Close()
+
+			var got []int
+			for {
+				item, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Recv returned an error: %v", err)
+				}
+				got = append(got, item.N)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected items %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGenericStream_ErrorFrame(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"error\":{\"message\":\"CUDA out of memory\",\"trace\":\"...\"}}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"x\"}}]}\n\n")
+	})
+
+	stream, err := client.Chat().CreateStream(context.Background(), &ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	defer stream.Close()
+
+	chunk, err := stream.Recv()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError from the first Recv, got %v (chunk %+v)", err, chunk)
+	}
+	if apiErr.Message != "CUDA out of memory" || apiErr.StatusCode != http.StatusOK {
+		t.Errorf("Expected the frame's message with status 200, got %+v", apiErr)
+	}
+
+	// The stream ends at the error
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the error frame, got %v", err)
+	}
+
+	// Callers assembling the stream see the error rather than an empty response
+	if _, err := client.Chat().CreateStreamCallback(context.Background(), &ChatCompletionRequest{}, nil); !errors.As(err, &apiErr) {
+		t.Errorf("Expected CreateStreamCallback to return an *APIError, got %v", err)
+	}
+}
+
+func TestParseStreamErrorFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		status int
+	}{
+		{"chunk", `{"choices":[{"delta":{"content":"the \"error\" was"}}]}`, 0},
+		{"null error", `{"error":null,"choices":[]}`, 0},
+		{"error frame", `{"error":{"message":"boom"}}`, http.StatusOK},
+		{"error frame with code", `{"error":{"message":"busy","code":503}}`, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := parseStreamErrorFrame([]byte(tt.data), http.StatusOK)
+			if tt.status == 0 {
+				if apiErr != nil {
+					t.Errorf("Expected no error, got %v", apiErr)
+				}
+				return
+			}
+			if apiErr == nil || apiErr.StatusCode != tt.status {
+				t.Errorf("Expected an error with status %d, got %v", tt.status, apiErr)
+			}
+		})
+	}
+}
+
+func TestWithStreamMaxDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+	}{
+		{"steady stream", 5 * time.Millisecond},
+		{"stalled stream", time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.(http.Flusher).Flush()
+				for {
+					select {
+					case <-r.Context().Done():
+						return
+					case <-time.After(tt.interval):
+						fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"text\":\"x\"}]}\n\n")
+						w.(http.Flusher).Flush()
+					}
+				}
+			}, WithStreamMaxDuration(50*time.Millisecond))
+
+			start := time.Now()
+			stream, err := client.Completions().CreateStream(context.Background(), &CompletionRequest{Prompt: "hi"})
+			if err != nil {
+				t.Fatalf("CreateStream returned an error: %v", err)
+			}
+			defer stream.Close()
+
+			for {
+				if _, err = stream.Recv(); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Expected the stream to end near its max duration, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestGenericStream_NoContent(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	stream, err := client.Completions().CreateStream(context.Background(), &CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	defer stream.Close()
+
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Expected io.EOF for 204 No Content, got %v", err)
+	}
+}
+
+func TestGenericStream_NoContentIgnoresBody(t *testing.T) {
+	stream := newGenericStream[testItem](context.Background(), &http.Response{
+		StatusCode: http.StatusNoContent,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewBufferString("data: {\"n\":1}\n\n")),
+	})
+	defer stream.Close()
+
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Expected io.EOF for 204 No Content, got %v", err)
+	}
+}
+
+func TestCreateStream_RetriesConnect(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"text\":\"hi\"}]}\n\n")
+	}, WithRetryPolicy(&SimpleRetryPolicy{
+		MaxRetryCount:  2,
+		RetryDelayFunc: func(attempts int) time.Duration { return time.Millisecond },
+		RetryableFunc:  func(resp *http.Response, err error) bool { return err != nil || resp.StatusCode >= 500 },
+	}))
+
+	stream, err := client.Completions().CreateStream(context.Background(), &CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	defer stream.Close()
+
+	chunk, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv returned an error: %v", err)
+	}
+	if chunk.Choices[0].Text != "hi" {
+		t.Errorf("Expected text %q, got %q", "hi", chunk.Choices[0].Text)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 connect attempts, got %d", calls)
+	}
+}
+
+func TestCreateStream_GzipEncodedBody(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// A compressing proxy ignores the client's Accept-Encoding
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, "data: {\"choices\":[{\"text\":\"Hello\"}]}\n\n")
+		fmt.Fprint(zw, "data: {\"choices\":[{\"text\":\" world\"}]}\n\n")
+		zw.Close()
+	})
+
+	stream, err := client.Completions().CreateStream(context.Background(), &CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	defer stream.Close()
+
+	var text strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv returned an error: %v", err)
+		}
+		text.WriteString(chunk.Choices[0].Text)
+	}
+	if text.String() != "Hello world" {
+		t.Errorf("Expected %q, got %q", "Hello world", text.String())
+	}
+}
+
+func TestRawStream(t *testing.T) {
+	type progress struct {
+		Step  int    `json:"step"`
+		Label string `json:"label"`
+	}
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/custom/progress" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("X-API-Key") != "key" {
+			t.Errorf("Expected the client's API key, got %q", r.Header.Get("X-API-Key"))
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["job"] != "index" {
+			t.Errorf("Unexpected body %v: %v", body, err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"step\":1,\"label\":\"scan\"}\n\n")
+		fmt.Fprint(w, "data: {\"step\":2,\"label\":\"embed\"}\n\n")
+	}, WithAPIKey("key"))
+
+	stream, err := RawStream[progress](context.Background(), client, http.MethodPost, "/v1/custom/progress", map[string]string{"job": "index"})
+	if err != nil {
+		t.Fatalf("RawStream returned an error: %v", err)
+	}
+	defer stream.Close()
+
+	var labels []string
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv returned an error: %v", err)
+		}
+		if event.Step != len(labels)+1 {
+			t.Errorf("Expected step %d, got %d", len(labels)+1, event.Step)
+		}
+		labels = append(labels, event.Label)
+	}
+	if strings.Join(labels, ",") != "scan,embed" {
+		t.Errorf("Expected events scan and embed, got %v", labels)
+	}
+}
+
+func TestGenericStream_UTF8Buffering(t *testing.T) {
+	emoji := "\U0001F600"
+	tests := []struct {
+		name   string
+		chunks []string
+	}{
+		{"raw bytes", []string{"Hi " + emoji[:2], emoji[2:] + "!"}},
+		{"surrogate escapes", []string{`Hi \ud83d`, `\ude00!`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sse bytes.Buffer
+			for _, chunk := range tt.chunks {
+				fmt.Fprintf(&sse, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"%s\"}}]}\n\n", chunk)
+			}
+			stream := newTestStream[*ChatCompletionStreamResponse](context.Background(), io.NopCloser(&sse)).
+				configure(streamConfig{utf8Buffering: true})
+			defer stream.Close()
+
+			var deltas []string
+			for {
+				chunk, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Recv returned an error: %v", err)
+				}
+				deltas = append(deltas, chunk.Choices[0].Delta.Content)
+			}
+
+			if len(deltas) != 2 || deltas[0] != "Hi " || deltas[1] != emoji+"!" {
+				t.Errorf("Expected deltas [%q %q], got %q", "Hi ", emoji+"!", deltas)
+			}
+		})
+	}
+}
+
+func TestGenericStream_TerminalEvent(t *testing.T) {
+	body := "data: {\"n\":1}\n\nevent: done\ndata: {}\n\ndata: {\"n\":2}\n\n"
+
+	tests := []struct {
+		name   string
+		config streamConfig
+		want   []int
+	}{
+		{"default", streamConfig{}, []int{1}},
+		{"custom", streamConfig{terminalEvents: []string{"finish"}}, []int{1, 0, 2}},
+		{"disabled", streamConfig{terminalEvents: []string{}}, []int{1, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := newTestStream[testItem](context.Background(), io.NopCloser(bytes.NewBufferString(body))).
+				configure(tt.config)
+			defer stream.Close()
+
+			var got []int
+			for {
+				item, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Recv returned an error: %v", err)
+				}
+				got = append(got, item.N)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected items %v, got %v", tt.want, got)
+			}
+
+			// The stream stays finished after the terminal event
+			if _, err := stream.Recv(); err != io.EOF {
+				t.Errorf("Expected io.EOF after the stream ended, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTimedStream_RecordsLatencies(t *testing.T) {
+	var sse bytes.Buffer
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(&sse, "data: {\"n\":%d}\n\n", i)
+	}
+	stream := newTestStream[testItem](context.Background(), io.NopCloser(&sse))
+
+	clock := time.Unix(0, 0)
+	timed := newTimedStream[testItem](stream, func() time.Time { return clock })
+	defer timed.Close()
+
+	gaps := []time.Duration{300 * time.Millisecond, 20 * time.Millisecond, 35 * time.Millisecond, 15 * time.Millisecond}
+	for _, gap := range gaps {
+		clock = clock.Add(gap)
+		if _, err := timed.Recv(); err != nil {
+			t.Fatalf("Recv returned an error: %v", err)
+		}
+	}
+	clock = clock.Add(time.Second)
+	if _, err := timed.Recv(); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+
+	if got := timed.TimeToFirstToken(); got != gaps[0] {
+		t.Errorf("Expected TTFT %v, got %v", gaps[0], got)
+	}
+	latencies := timed.InterTokenLatencies()
+	if len(latencies) != len(gaps)-1 {
+		t.Fatalf("Expected %d latencies, got %v", len(gaps)-1, latencies)
+	}
+	for i, latency := range latencies {
+		if latency != gaps[i+1] {
+			t.Errorf("Latency %d: expected %v, got %v", i, gaps[i+1], latency)
+		}
+	}
+}
+
+func TestChatEvents_RoleBeforeContent(t *testing.T) {
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"role":"assistant"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"Hi"}},{"index":1,"delta":{"content":"Yo"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"!"},"finish_reason":"stop"}]}`,
+	}
+	var sse bytes.Buffer
+	for _, chunk := range chunks {
+		fmt.Fprintf(&sse, "data: %s\n\n", chunk)
+	}
+	stream := newTestStream[*ChatCompletionStreamResponse](context.Background(), io.NopCloser(&sse))
+
+	events := ChatEvents(stream)
+	defer events.Close()
+
+	var got []string
+	for {
+		event, err := events.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv returned an error: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%d:%s:%s%s%s", event.Index, event.Type, event.Role, event.Content, event.FinishReason))
+	}
+
+	// Choice 1 never sent a role, so an assistant role is synthesized ahead of its content
+	want := []string{
+		"0:role:assistant",
+		"0:content:Hi",
+		"1:role:assistant",
+		"1:content:Yo",
+		"0:content:!",
+		"0:finish:stop",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+}
+
+func TestCollectCompletionStream(t *testing.T) {
+	tests := []struct {
+		name          string
+		chunks        []string
+		wantText      string
+		wantTruncated bool
+	}{
+		{
+			name: "clean",
+			chunks: []string{
+				`{"id":"c1","model":"m","choices":[{"index":0,"text":"Hel"}]}`,
+				`{"id":"c1","model":"m","choices":[{"index":0,"text":"lo","finish_reason":"stop"}]}`,
+			},
+			wantText: "Hello",
+		},
+		{
+			name: "truncated",
+			chunks: []string{
+				`{"id":"c1","model":"m","choices":[{"index":0,"text":"Hel"}]}`,
+				`{"id":"c1","model":"m","choices":[{"index":0,"text":"lo"}]}`,
+			},
+			wantText:      "Hello",
+			wantTruncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sse bytes.Buffer
+			for _, chunk := range tt.chunks {
+				fmt.Fprintf(&sse, "data: %s\n\n", chunk)
+			}
+			stream := newTestStream[*CompletionStreamResponse](context.Background(), io.NopCloser(&sse))
+
+			resp, err := CollectCompletionStream(stream)
+			if tt.wantTruncated {
+				if !errors.Is(err, ErrStreamTruncated) {
+					t.Fatalf("Expected ErrStreamTruncated, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("CollectCompletionStream returned an error: %v", err)
+			}
+
+			if resp == nil || resp.ID != "c1" || len(resp.Choices) != 1 {
+				t.Fatalf("Unexpected response: %+v", resp)
+			}
+			if resp.Choices[0].Text != tt.wantText {
+				t.Errorf("Expected text %q, got %q", tt.wantText, resp.Choices[0].Text)
+			}
+			if _, err := stream.Recv(); err != ErrStreamClosed {
+				t.Errorf("Expected the stream to be closed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCollectCompletionStream_InterleavedChoices(t *testing.T) {
+	var sse bytes.Buffer
+	for _, chunk := range []string{
+		`{"id":"c1","choices":[{"index":0,"text":"a"}]}`,
+		`{"id":"c1","choices":[{"index":2,"text":"x"}]}`,
+		`{"id":"c1","choices":[{"index":0,"text":"b","finish_reason":"stop"}]}`,
+		`{"id":"c1","choices":[{"index":1,"text":"m","finish_reason":"stop"},{"index":2,"text":"y","finish_reason":"stop"}]}`,
+	} {
+		fmt.Fprintf(&sse, "data: %s\n\n", chunk)
+	}
+	stream := newTestStream[*CompletionStreamResponse](context.Background(), io.NopCloser(&sse))
+
+	resp, err := CollectCompletionStream(stream)
+	if err != nil {
+		t.Fatalf("CollectCompletionStream returned an error: %v", err)
+	}
+	want := []string{"ab", "m", "xy"}
+	if len(resp.Choices) != len(want) {
+		t.Fatalf("Expected %d choices, got %+v", len(want), resp.Choices)
+	}
+	for i, text := range want {
+		if resp.Choices[i].Index != i || resp.Choices[i].Text != text {
+			t.Errorf("Choice %d: expected %q, got %+v", i, text, resp.Choices[i])
+		}
+	}
+}
+
+func TestCollectChatStreamWithSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		deltas  []string
+		wantErr bool
+	}{
+		{"conforming", []string{`{"name":"Ada",`, `"age":36,"skills":[]}`}, false},
+		{"drifted", []string{`{"name":"Ada",`, `"skills":[]}`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sse bytes.Buffer
+			for _, delta := range tt.deltas {
+				chunk, _ := json.Marshal(ChatCompletionStreamResponse{
+					Choices: []ChatCompletionStreamChoice{{Delta: &Delta{Content: delta}}},
+				})
+				fmt.Fprintf(&sse, "data: %s\n\n", chunk)
+			}
+			stream := newTestStream[*ChatCompletionStreamResponse](context.Background(), io.NopCloser(&sse))
+
+			resp, err := CollectChatStreamWithSchema(stream, personSchema)
+			var validationErr *ValidationError
+			if tt.wantErr != errors.As(err, &validationErr) {
+				t.Fatalf("Expected validation error=%v, got %v", tt.wantErr, err)
+			}
+			if msg, ok := resp.FirstMessage(); !ok || msg.Content != strings.Join(tt.deltas, "") {
+				t.Errorf("Expected assembled content, got %+v", resp)
+			}
+		})
+	}
+}
+
+func TestWithStreamBufferSize_DeliversEventBeforeMore(t *testing.T) {
+	for _, size := range []int{0, 16} {
+		t.Run(fmt.Sprintf("size %d", size), func(t *testing.T) {
+			release := make(chan struct{})
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"text\":\"first\"}]}\n\n")
+				w.(http.Flusher).Flush()
+
+				// Block until the client has received the first event
+				select {
+				case <-release:
+				case <-r.Context().Done():
+					return
+				}
+				fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"text\":\"second\"}]}\n\n")
+			}, WithStreamBufferSize(size))
+
+			stream, err := client.Completions().CreateStream(context.Background(), &CompletionRequest{Prompt: "hi"})
+			if err != nil {
+				t.Fatalf("CreateStream returned an error: %v", err)
+			}
+			defer stream.Close()
+
+			received := make(chan string, 1)
+			go func() {
+				chunk, err := stream.Recv()
+				if err != nil {
+					t.Errorf("Recv returned an error: %v", err)
+					received <- ""
+					return
+				}
+				received <- chunk.Choices[0].Text
+			}()
+
+			select {
+			case text := <-received:
+				if text != "first" {
+					t.Errorf("Expected the first chunk, got %q", text)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("First event not delivered while the server was blocked")
+			}
+			close(release)
+
+			chunk, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("Recv returned an error: %v", err)
+			}
+			if chunk.Choices[0].Text != "second" {
+				t.Errorf("Expected the second chunk, got %q", chunk.Choices[0].Text)
+			}
+		})
+	}
+}
+
+func TestCreateStream_DefaultPolicyRetriesConnectOnServerError(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"text\":\"hi\"}]}\n\n")
+	}, WithRetryPolicy(fastDefaultRetryPolicy()))
+
+	stream, err := client.Chat().CreateStream(context.Background(), &ChatCompletionRequest{
+		Messages: []ChatMessage{{Role: ChatMessageRoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateStream returned an error: %v", err)
+	}
+	defer stream.Close()
+
+	if calls != 2 {
+		t.Errorf("Expected the 503 connect attempt to be retried, got %d attempts", calls)
+	}
+}
@@ -0,0 +1,34 @@
+package tabby
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is the data a template sees when rendered with RenderTemplate.
+type TemplateData struct {
+	Messages []ChatMessage
+	Vars     map[string]interface{}
+}
+
+// RenderTemplate applies a prompt template to messages client-side, for
+// previewing or debugging what a prompt will look like.
+//
+// The template is parsed with Go's text/template, so it uses Go syntax
+// ({{range .Messages}}{{.Role}}: {{.Content}}{{end}}) rather than the Jinja2
+// syntax TabbyAPI's server-side templates are written in. Messages and vars
+// are available as .Messages and .Vars. The result is a preview only; the
+// server renders its own template when a request is sent.
+func RenderTemplate(text string, messages []ChatMessage, vars map[string]interface{}) (string, error) {
+	tmpl, err := template.New("prompt").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, TemplateData{Messages: messages, Vars: vars}); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return b.String(), nil
+}
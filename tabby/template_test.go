@@ -0,0 +1,27 @@
+package tabby
+
+import "testing"
+
+func TestRenderTemplate(t *testing.T) {
+	text := "{{range .Messages}}<|{{.Role}}|>{{.Content}}\n{{end}}<|assistant|>{{.Vars.suffix}}"
+	messages := []ChatMessage{
+		{Role: ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: ChatMessageRoleUser, Content: "Hello"},
+	}
+
+	got, err := RenderTemplate(text, messages, map[string]interface{}{"suffix": "!"})
+	if err != nil {
+		t.Fatalf("RenderTemplate returned an error: %v", err)
+	}
+
+	want := "<|system|>Be brief.\n<|user|>Hello\n<|assistant|>!"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestRenderTemplate_ParseError(t *testing.T) {
+	if _, err := RenderTemplate("{{ for message in messages }}", nil, nil); err == nil {
+		t.Fatal("Expected an error for a non-Go template, got nil")
+	}
+}
@@ -2,7 +2,14 @@
 package tabby
 
 import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // Authenticator provides authentication for API requests.
@@ -63,6 +70,27 @@ func (a *BearerTokenAuthenticator) Apply(req *http.Request) {
 	req.Header.Set("Authorization", "Bearer "+a.Token)
 }
 
+// MultiAuthenticator applies several authenticators to each request.
+// This supports deployments that check more than one credential, such as a
+// gateway requiring a bearer token in front of TabbyAPI checking X-API-Key.
+//
+// Authenticators are applied in order, so if two set the same header, the
+// later one wins.
+type MultiAuthenticator struct {
+	// Authenticators are the authenticators to apply, in order
+	Authenticators []Authenticator
+}
+
+// Apply implements the Authenticator interface by applying each
+// authenticator in turn.
+func (a *MultiAuthenticator) Apply(req *http.Request) {
+	for _, auth := range a.Authenticators {
+		if auth != nil {
+			auth.Apply(req)
+		}
+	}
+}
+
 // Stream is a generic interface for Server-Sent Events (SSE) streams.
 // It provides methods to receive items from the stream and to close the stream
 // when it's no longer needed.
@@ -76,6 +104,10 @@ type Stream[T any] interface {
 	// This method is designed to be called in a loop until an error is returned.
 	// The returned error can be either io.EOF (end of stream), ErrStreamClosed
 	// (stream was closed), or any other error that occurred while reading from the stream.
+	//
+	// If the server answered the streaming request with 204 No Content, there
+	// is nothing to stream and the first call returns io.EOF without reading
+	// the body.
 	Recv() (T, error)
 
 	// Close releases resources associated with the stream.
@@ -120,7 +152,55 @@ const (
 type ChatMessage struct {
 	Role    ChatMessageRole `json:"role"`
 	Content interface{}     `json:"content"` // String or array of ChatMessageContent
-	// Additional fields may be added later
+
+	// ToolCallID links a tool-role message carrying a tool's result to the
+	// tool call it answers.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// UnmarshalJSON decodes a chat message, preserving structured content.
+//
+// String content decodes to a string. Array content decodes to
+// []ChatMessageContent rather than []interface{}, so multi-modal responses
+// can be inspected without type assertions on generic maps.
+func (m *ChatMessage) UnmarshalJSON(data []byte) error {
+	type chatMessageAlias ChatMessage
+	var raw struct {
+		chatMessageAlias
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = ChatMessage(raw.chatMessageAlias)
+
+	content := raw.Content
+	if len(content) == 0 || string(content) == "null" {
+		m.Content = nil
+		return nil
+	}
+
+	switch content[0] {
+	case '"':
+		var text string
+		if err := json.Unmarshal(content, &text); err != nil {
+			return err
+		}
+		m.Content = text
+	case '[':
+		var parts []ChatMessageContent
+		if err := json.Unmarshal(content, &parts); err != nil {
+			return err
+		}
+		m.Content = parts
+	default:
+		var v interface{}
+		if err := json.Unmarshal(content, &v); err != nil {
+			return err
+		}
+		m.Content = v
+	}
+	return nil
 }
 
 // ChatMessageContent represents a part of a message content
@@ -135,20 +215,477 @@ type ChatImageURL struct {
 	URL string `json:"url"`
 }
 
+// SanitizeMessages returns a copy of messages with adjacent messages of the
+// same role merged, for chat templates that reject consecutive turns from
+// one role. Empty messages are dropped if dropEmpty is true.
+//
+// Merged string content is joined with a newline. If either message carries
+// []ChatMessageContent, string content is converted to a text part and the
+// parts are concatenated. Messages with any other content type are never
+// merged. A message is empty if its content is nil, "", or an empty slice;
+// when dropEmpty is false, empty messages are kept as they are and never
+// merged with their neighbours. Tool results are never treated as empty, and
+// results for different tool calls are never merged.
+func SanitizeMessages(messages []ChatMessage, dropEmpty bool) []ChatMessage {
+	result := make([]ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if isEmptyMessage(msg) {
+			if !dropEmpty {
+				result = append(result, msg)
+			}
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].Role == msg.Role && result[n-1].ToolCallID == msg.ToolCallID && !isEmptyMessage(result[n-1]) {
+			if merged, ok := mergeContent(result[n-1].Content, msg.Content); ok {
+				result[n-1].Content = merged
+				continue
+			}
+		}
+		result = append(result, msg)
+	}
+	return result
+}
+
+// EnforceSystemFirst returns a copy of messages with the system message
+// moved to index 0, for chat templates that only honor a leading system
+// prompt. The order of the other messages is kept.
+//
+// If there is more than one system message, it returns a *ValidationError
+// unless merge is true, in which case they are merged in order into a single
+// leading message as SanitizeMessages would merge them. Messages without a
+// system message are returned unchanged.
+func EnforceSystemFirst(messages []ChatMessage, merge bool) ([]ChatMessage, error) {
+	var system *ChatMessage
+	count := 0
+	rest := make([]ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role != ChatMessageRoleSystem {
+			rest = append(rest, msg)
+			continue
+		}
+
+		count++
+		if system == nil {
+			first := msg
+			system = &first
+			continue
+		}
+		if !merge {
+			continue
+		}
+		merged, ok := mergeContent(system.Content, msg.Content)
+		if !ok {
+			return nil, &ValidationError{
+				Field:   "messages",
+				Message: "system messages have content that cannot be merged",
+			}
+		}
+		system.Content = merged
+	}
+
+	if count > 1 && !merge {
+		return nil, &ValidationError{
+			Field:   "messages",
+			Message: fmt.Sprintf("found %d system messages, expected at most one", count),
+		}
+	}
+	if system == nil {
+		return append([]ChatMessage(nil), messages...), nil
+	}
+	return append([]ChatMessage{*system}, rest...), nil
+}
+
+// isEmptyMessage reports whether msg is empty as SanitizeMessages treats it.
+func isEmptyMessage(msg ChatMessage) bool {
+	return isEmptyContent(msg.Content) && msg.ToolCallID == ""
+}
+
+// isEmptyContent reports whether message content carries nothing to send.
+func isEmptyContent(content interface{}) bool {
+	switch c := content.(type) {
+	case nil:
+		return true
+	case string:
+		return c == ""
+	case []ChatMessageContent:
+		return len(c) == 0
+	}
+	return false
+}
+
+// mergeContent joins two message contents, reporting false if either has a
+// type that cannot be merged.
+func mergeContent(a, b interface{}) (interface{}, bool) {
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return as + "\n" + bs, true
+		}
+	}
+
+	aParts, ok := contentParts(a)
+	if !ok {
+		return nil, false
+	}
+	bParts, ok := contentParts(b)
+	if !ok {
+		return nil, false
+	}
+	// Copy so the caller's slices are never appended to in place
+	merged := make([]ChatMessageContent, 0, len(aParts)+len(bParts))
+	return append(append(merged, aParts...), bParts...), true
+}
+
+// contentParts converts string or structured content to content parts.
+func contentParts(content interface{}) ([]ChatMessageContent, bool) {
+	switch c := content.(type) {
+	case string:
+		return []ChatMessageContent{{Type: "text", Text: c}}, true
+	case []ChatMessageContent:
+		return c, true
+	}
+	return nil, false
+}
+
+// contentText returns the text of message content: the string itself, or the
+// concatenated text parts of structured content.
+func contentText(content interface{}) string {
+	if text, ok := content.(string); ok {
+		return text
+	}
+	parts, _ := contentParts(content)
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+// Float64 returns a pointer to v, for optional request fields such as
+// Temperature where zero is a meaningful value distinct from unset.
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// Bool returns a pointer to v, for optional request fields such as
+// AddGenerationPrompt where false is distinct from unset.
+func Bool(v bool) *bool {
+	return &v
+}
+
 // CompletionRequest matches the TabbyAPI completion request schema
 type CompletionRequest struct {
-	Prompt      string      `json:"prompt"`
+	Prompt      interface{} `json:"prompt"` // String, array of strings, []int or [][]int token IDs, or []PromptMessage
 	MaxTokens   int         `json:"max_tokens,omitempty"`
-	Temperature float64     `json:"temperature,omitempty"`
-	TopP        float64     `json:"top_p,omitempty"`
+	Temperature *float64    `json:"temperature,omitempty"` // nil uses the server default; use Float64 to set
+	TopP        *float64    `json:"top_p,omitempty"`
 	TopK        int         `json:"top_k,omitempty"`
 	Stream      bool        `json:"stream,omitempty"`
 	Stop        []string    `json:"stop,omitempty"`
 	Model       string      `json:"model,omitempty"`
 	JSONSchema  interface{} `json:"json_schema,omitempty"`
+
+	// SkipQueue asks the server to bypass the generation queue for priority
+	// handling. TabbyAPI typically only honors this for admin-authenticated requests.
+	SkipQueue bool `json:"skip_queue,omitempty"`
+
+	// StreamOptions configures streaming responses. It is only valid with
+	// CreateStream; Create rejects a request that sets it.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+
+	// IncludeStopStrInOutput asks the server to keep the matched stop string
+	// at the end of the output instead of stripping it.
+	IncludeStopStrInOutput bool `json:"include_stop_str_in_output,omitempty"`
+
+	// BannedTokens and BannedStrings keep the listed token IDs and strings
+	// out of the output. AllowedTokens and AllowedStrings restrict
+	// generation to the listed token IDs and strings. An entry may not
+	// appear in both an allow list and the matching ban list.
+	BannedTokens   []int    `json:"banned_tokens,omitempty"`
+	BannedStrings  []string `json:"banned_strings,omitempty"`
+	AllowedTokens  []int    `json:"allowed_tokens,omitempty"`
+	AllowedStrings []string `json:"allowed_strings,omitempty"`
 	// Additional parameters will be added as needed
 }
 
+// PromptMessage is one role-tagged entry of a completion prompt, as built by
+// RolePrompt.
+type PromptMessage struct {
+	Role    ChatMessageRole `json:"role"`
+	Content string          `json:"content"`
+}
+
+// RolePrompt converts messages into a role-tagged completion prompt, for
+// TabbyAPI setups that accept an array of role objects as the prompt of an
+// instruct model on the completions endpoint. Content other than a string
+// is reduced to its text parts.
+//
+// The server formats such a prompt as it sees fit, and most setups reject
+// it. Prefer ChatService, which applies the model's chat template, unless
+// the server is known to accept this form.
+func RolePrompt(messages ...ChatMessage) []PromptMessage {
+	prompt := make([]PromptMessage, len(messages))
+	for i, msg := range messages {
+		prompt[i] = PromptMessage{Role: msg.Role, Content: contentText(msg.Content)}
+	}
+	return prompt
+}
+
+// Validate checks that Prompt is a string, a non-empty array of strings or
+// token IDs, or a non-empty role-tagged prompt, either as built by RolePrompt
+// or as decoded from JSON. It returns a *ValidationError for field "prompt"
+// otherwise, so malformed prompts are rejected without a round trip.
+func (r *CompletionRequest) Validate() error {
+	switch prompt := r.Prompt.(type) {
+	case nil:
+		return &ValidationError{Field: "prompt", Message: "prompt is required"}
+	case string:
+		return nil
+	case []string:
+		if len(prompt) == 0 {
+			return &ValidationError{Field: "prompt", Message: "prompt must not be empty"}
+		}
+	case []int:
+		if len(prompt) == 0 {
+			return &ValidationError{Field: "prompt", Message: "prompt must not be empty"}
+		}
+	case [][]int:
+		if len(prompt) == 0 {
+			return &ValidationError{Field: "prompt", Message: "prompt must not be empty"}
+		}
+		for i, ids := range prompt {
+			if len(ids) == 0 {
+				return &ValidationError{Field: "prompt", Message: fmt.Sprintf("prompt[%d] must not be empty", i)}
+			}
+		}
+	case []PromptMessage:
+		if len(prompt) == 0 {
+			return &ValidationError{Field: "prompt", Message: "prompt must not be empty"}
+		}
+		for i, msg := range prompt {
+			if msg.Role == "" {
+				return &ValidationError{Field: "prompt", Message: fmt.Sprintf("prompt[%d] must have a role", i)}
+			}
+		}
+	case []interface{}:
+		if len(prompt) == 0 {
+			return &ValidationError{Field: "prompt", Message: "prompt must not be empty"}
+		}
+		for i, item := range prompt {
+			switch item := item.(type) {
+			case string, float64, int:
+			case map[string]interface{}:
+				role, _ := item["role"].(string)
+				_, hasContent := item["content"].(string)
+				if role == "" || !hasContent {
+					return &ValidationError{Field: "prompt", Message: fmt.Sprintf("prompt[%d] must have a string role and content", i)}
+				}
+			default:
+				return &ValidationError{Field: "prompt", Message: fmt.Sprintf("prompt[%d] has unsupported type %T", i, item)}
+			}
+		}
+	default:
+		return &ValidationError{Field: "prompt", Message: fmt.Sprintf("unsupported prompt type %T", prompt)}
+	}
+	return nil
+}
+
+// ToChat converts r to an equivalent chat completion request, for moving
+// from raw completions to chat while keeping the same sampler settings.
+//
+// The prompt becomes a user message, preceded by a system message when
+// systemPrompt is non-empty. An array prompt becomes one user message per
+// entry, except that the entries of a role-tagged prompt keep their roles.
+// Sampling, stop, schema, and token filter settings are copied, with
+// slices, pointers, and the maps and slices of a decoded JSONSchema
+// duplicated so the two requests can be changed independently. Stream and
+// StreamOptions are not copied, since the chat service sets them per call.
+//
+// A prompt of token IDs has no text to put in a message, so it fails with a
+// *ValidationError; decode it with TokensService.Decode first.
+func (r *CompletionRequest) ToChat(systemPrompt string) (*ChatCompletionRequest, error) {
+	switch prompt := r.Prompt.(type) {
+	case []int, [][]int:
+		return nil, &ValidationError{Field: "prompt", Message: "token ID prompts cannot be converted to chat messages"}
+	case []interface{}:
+		if isTokenIDs(prompt) {
+			return nil, &ValidationError{Field: "prompt", Message: "token ID prompts cannot be converted to chat messages"}
+		}
+	}
+
+	var messages []ChatMessage
+	if systemPrompt != "" {
+		messages = append(messages, ChatMessage{Role: ChatMessageRoleSystem, Content: systemPrompt})
+	}
+	switch prompt := r.Prompt.(type) {
+	case string:
+		messages = append(messages, ChatMessage{Role: ChatMessageRoleUser, Content: prompt})
+	case []string:
+		for _, text := range prompt {
+			messages = append(messages, ChatMessage{Role: ChatMessageRoleUser, Content: text})
+		}
+	case []PromptMessage:
+		for _, msg := range prompt {
+			messages = append(messages, ChatMessage{Role: msg.Role, Content: msg.Content})
+		}
+	case []interface{}:
+		for _, item := range prompt {
+			if obj, ok := item.(map[string]interface{}); ok {
+				role, _ := obj["role"].(string)
+				content, _ := obj["content"].(string)
+				messages = append(messages, ChatMessage{Role: ChatMessageRole(role), Content: content})
+				continue
+			}
+			messages = append(messages, ChatMessage{Role: ChatMessageRoleUser, Content: fmt.Sprint(item)})
+		}
+	case nil:
+	default:
+		messages = append(messages, ChatMessage{Role: ChatMessageRoleUser, Content: fmt.Sprint(prompt)})
+	}
+
+	return &ChatCompletionRequest{
+		Messages:               messages,
+		MaxTokens:              r.MaxTokens,
+		Temperature:            copyFloat64(r.Temperature),
+		TopP:                   copyFloat64(r.TopP),
+		TopK:                   r.TopK,
+		Stop:                   append([]string(nil), r.Stop...),
+		Model:                  r.Model,
+		JSONSchema:             copyJSONValue(r.JSONSchema),
+		SkipQueue:              r.SkipQueue,
+		IncludeStopStrInOutput: r.IncludeStopStrInOutput,
+		BannedTokens:           append([]int(nil), r.BannedTokens...),
+		BannedStrings:          append([]string(nil), r.BannedStrings...),
+		AllowedTokens:          append([]int(nil), r.AllowedTokens...),
+		AllowedStrings:         append([]string(nil), r.AllowedStrings...),
+	}, nil
+}
+
+// copyFloat64 returns a new pointer holding *v, or nil if v is nil.
+func copyFloat64(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	return Float64(*v)
+}
+
+// isTokenIDs reports whether prompt is a non-empty array of numbers, the
+// form a []int prompt takes once decoded from JSON.
+func isTokenIDs(prompt []interface{}) bool {
+	if len(prompt) == 0 {
+		return false
+	}
+	for _, item := range prompt {
+		switch item.(type) {
+		case float64, int:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// copyJSONValue returns a deep copy of v's maps and slices, as produced by
+// decoding JSON into an interface{}. Other values, such as a schema given
+// as a struct, are returned as is.
+func copyJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = copyJSONValue(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = copyJSONValue(value)
+		}
+		return out
+	case json.RawMessage:
+		return append(json.RawMessage(nil), v...)
+	}
+	return v
+}
+
+// StreamOptions configures a streaming completion or chat completion
+type StreamOptions struct {
+	// IncludeUsage asks the server to send token usage statistics in the stream.
+	IncludeUsage bool `json:"include_usage,omitempty"`
+}
+
+// validateStreamFlag rejects a request whose Stream flag does not match the
+// method it was passed to. It only applies with WithStrictStreamFlag.
+func validateStreamFlag(strict, stream, want bool) error {
+	if !strict || stream == want {
+		return nil
+	}
+	if want {
+		return &ValidationError{Field: "stream", Message: "stream must be true for CreateStream; use Create for non-streaming requests"}
+	}
+	return &ValidationError{Field: "stream", Message: "stream must be false for Create; use CreateStream for streaming requests"}
+}
+
+// validateStreamOptions rejects StreamOptions on a non-streaming request.
+func validateStreamOptions(stream bool, opts *StreamOptions) error {
+	if opts != nil && !stream {
+		return &ValidationError{
+			Field:   "stream_options",
+			Message: "stream_options is only allowed when stream is true",
+		}
+	}
+	return nil
+}
+
+// Object types of TabbyAPI responses, checked by WithResponseValidation.
+const (
+	ObjectTextCompletion = "text_completion"
+	ObjectChatCompletion = "chat.completion"
+	ObjectList           = "list"
+)
+
+// checkObjectType returns a *ValidationError if enabled and the response
+// object type got is not want.
+func checkObjectType(enabled bool, got, want string) error {
+	if !enabled || got == want {
+		return nil
+	}
+	return &ValidationError{
+		Field:   "object",
+		Message: fmt.Sprintf("expected response object %q, got %q", want, got),
+	}
+}
+
+// validateTokenFilters rejects token filters that contradict each other,
+// where a token ID or string is both allowed and banned.
+func validateTokenFilters(allowedTokens, bannedTokens []int, allowedStrings, bannedStrings []string) error {
+	banned := make(map[int]bool, len(bannedTokens))
+	for _, token := range bannedTokens {
+		banned[token] = true
+	}
+	for _, token := range allowedTokens {
+		if banned[token] {
+			return &ValidationError{
+				Field:   "allowed_tokens",
+				Message: fmt.Sprintf("token %d is both allowed and banned", token),
+			}
+		}
+	}
+
+	bannedStr := make(map[string]bool, len(bannedStrings))
+	for _, str := range bannedStrings {
+		bannedStr[str] = true
+	}
+	for _, str := range allowedStrings {
+		if bannedStr[str] {
+			return &ValidationError{
+				Field:   "allowed_strings",
+				Message: fmt.Sprintf("string %q is both allowed and banned", str),
+			}
+		}
+	}
+	return nil
+}
+
 // CompletionResponse represents a response to a completion request
 type CompletionResponse struct {
 	ID      string                 `json:"id"`
@@ -159,12 +696,55 @@ type CompletionResponse struct {
 	Usage   *UsageStats            `json:"usage,omitempty"`
 }
 
+// ByPromptIndex groups the response choices by their Index.
+//
+// When CompletionRequest.Prompt is a []string, TabbyAPI generates for each
+// prompt and sets each choice's Index to the position of its prompt, so the
+// choices for prompt i are found under key i. Choices keep their response
+// order within each group.
+func (r *CompletionResponse) ByPromptIndex() map[int][]CompletionRespChoice {
+	groups := make(map[int][]CompletionRespChoice)
+	for _, choice := range r.Choices {
+		groups[choice.Index] = append(groups[choice.Index], choice)
+	}
+	return groups
+}
+
+// FirstText returns the text of the first choice, reporting false if the
+// response has no choices.
+func (r *CompletionResponse) FirstText() (string, bool) {
+	if len(r.Choices) == 0 {
+		return "", false
+	}
+	return r.Choices[0].Text, true
+}
+
+// NeedsContinuation reports whether the first choice stopped because it
+// reached the token limit, so its text is likely cut off mid-thought.
+func (r *CompletionResponse) NeedsContinuation() bool {
+	return len(r.Choices) > 0 && r.Choices[0].FinishReason == FinishReasonLength
+}
+
+// Finish reasons reported on response choices.
+const (
+	// FinishReasonStop means generation ended naturally or on a stop string
+	FinishReasonStop = "stop"
+
+	// FinishReasonLength means generation ended at the token limit
+	FinishReasonLength = "length"
+)
+
 // CompletionRespChoice represents a choice in a completion response
 type CompletionRespChoice struct {
 	Text         string              `json:"text"`
 	Index        int                 `json:"index"`
 	FinishReason string              `json:"finish_reason,omitempty"`
 	LogProbs     *CompletionLogProbs `json:"logprobs,omitempty"`
+
+	// MatchedStop is the stop string or token that ended generation, as
+	// reported by TabbyAPI, for telling apart several Stop entries. It is
+	// empty when generation ended for another reason.
+	MatchedStop string `json:"stop_str,omitempty"`
 }
 
 // CompletionLogProbs represents log probabilities for a completion
@@ -175,6 +755,47 @@ type CompletionLogProbs struct {
 	TextOffset    []int                `json:"text_offset"`
 }
 
+// LogProbEntry is the log probability information for one generated token,
+// as returned by CompletionLogProbs.Entries.
+type LogProbEntry struct {
+	Token   string
+	LogProb float64
+	Offset  int // Offset of the token in the generated text
+
+	// TopAlternatives maps the most likely tokens at this position to their
+	// log probabilities. It is nil when top logprobs were not requested.
+	TopAlternatives map[string]float64
+}
+
+// Entries zips the parallel slices of lp into one entry per token. TextOffset
+// and TopLogProbs may be omitted, leaving the matching fields zero, but any
+// slice that is present must have one element per token; otherwise an error
+// is returned.
+func (lp *CompletionLogProbs) Entries() ([]LogProbEntry, error) {
+	n := len(lp.Tokens)
+	if len(lp.TokenLogProbs) != n {
+		return nil, fmt.Errorf("logprobs have %d tokens but %d token_logprobs", n, len(lp.TokenLogProbs))
+	}
+	if len(lp.TextOffset) != 0 && len(lp.TextOffset) != n {
+		return nil, fmt.Errorf("logprobs have %d tokens but %d text_offset entries", n, len(lp.TextOffset))
+	}
+	if len(lp.TopLogProbs) != 0 && len(lp.TopLogProbs) != n {
+		return nil, fmt.Errorf("logprobs have %d tokens but %d top_logprobs entries", n, len(lp.TopLogProbs))
+	}
+
+	entries := make([]LogProbEntry, n)
+	for i, token := range lp.Tokens {
+		entries[i] = LogProbEntry{Token: token, LogProb: lp.TokenLogProbs[i]}
+		if len(lp.TextOffset) != 0 {
+			entries[i].Offset = lp.TextOffset[i]
+		}
+		if len(lp.TopLogProbs) != 0 {
+			entries[i].TopAlternatives = lp.TopLogProbs[i]
+		}
+	}
+	return entries, nil
+}
+
 // CompletionStreamResponse represents a streaming completion response
 type CompletionStreamResponse struct {
 	ID      string                   `json:"id"`
@@ -195,13 +816,55 @@ type CompletionStreamChoice struct {
 type ChatCompletionRequest struct {
 	Messages    []ChatMessage `json:"messages"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	TopP        float64       `json:"top_p,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"` // nil uses the server default; use Float64 to set
+	TopP        *float64      `json:"top_p,omitempty"`
 	TopK        int           `json:"top_k,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
 	Stop        []string      `json:"stop,omitempty"`
 	Model       string        `json:"model,omitempty"`
 	JSONSchema  interface{}   `json:"json_schema,omitempty"`
+
+	// MaxCompletionTokens is the newer OpenAI name for MaxTokens. Either field
+	// may be set; the client sends the key(s) selected by WithMaxTokensField.
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+
+	// SkipQueue asks the server to bypass the generation queue for priority
+	// handling. TabbyAPI typically only honors this for admin-authenticated requests.
+	SkipQueue bool `json:"skip_queue,omitempty"`
+
+	// Logprobs requests log probabilities for each generated token.
+	Logprobs bool `json:"logprobs,omitempty"`
+
+	// TopLogprobs is the number of most likely alternatives to return for
+	// each token position. Requires Logprobs.
+	TopLogprobs int `json:"top_logprobs,omitempty"`
+
+	// AddGenerationPrompt controls whether the prompt template appends the
+	// assistant turn header after the messages. Set it to false to continue
+	// a partial assistant message as a prefix. nil uses the server default;
+	// use Bool to set.
+	AddGenerationPrompt *bool `json:"add_generation_prompt,omitempty"`
+
+	// TemplateVars are extra variables passed to the prompt template when the
+	// server renders this request's messages.
+	TemplateVars map[string]interface{} `json:"template_vars,omitempty"`
+
+	// StreamOptions configures streaming responses. It is only valid with
+	// CreateStream; Create rejects a request that sets it.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+
+	// IncludeStopStrInOutput asks the server to keep the matched stop string
+	// at the end of the output instead of stripping it.
+	IncludeStopStrInOutput bool `json:"include_stop_str_in_output,omitempty"`
+
+	// BannedTokens and BannedStrings keep the listed token IDs and strings
+	// out of the output. AllowedTokens and AllowedStrings restrict
+	// generation to the listed token IDs and strings. An entry may not
+	// appear in both an allow list and the matching ban list.
+	BannedTokens   []int    `json:"banned_tokens,omitempty"`
+	BannedStrings  []string `json:"banned_strings,omitempty"`
+	AllowedTokens  []int    `json:"allowed_tokens,omitempty"`
+	AllowedStrings []string `json:"allowed_strings,omitempty"`
 	// Additional parameters will be added as needed
 }
 
@@ -215,11 +878,45 @@ type ChatCompletionResponse struct {
 	Usage   *UsageStats                `json:"usage,omitempty"`
 }
 
+// FirstMessage returns the message of the first choice, reporting false if
+// the response has no choices.
+func (r *ChatCompletionResponse) FirstMessage() (ChatMessage, bool) {
+	if len(r.Choices) == 0 {
+		return ChatMessage{}, false
+	}
+	return r.Choices[0].Message, true
+}
+
+// NeedsContinuation reports whether the first choice stopped because it
+// reached the token limit. ChatService.Continue picks up where it left off.
+func (r *ChatCompletionResponse) NeedsContinuation() bool {
+	return len(r.Choices) > 0 && r.Choices[0].FinishReason == FinishReasonLength
+}
+
 // ChatCompletionRespChoice represents a choice in a chat completion response
 type ChatCompletionRespChoice struct {
-	Index        int         `json:"index"`
-	Message      ChatMessage `json:"message"`
-	FinishReason string      `json:"finish_reason,omitempty"`
+	Index        int                     `json:"index"`
+	Message      ChatMessage             `json:"message"`
+	FinishReason string                  `json:"finish_reason,omitempty"`
+	Logprobs     *ChatCompletionLogprobs `json:"logprobs,omitempty"`
+
+	// MatchedStop is the stop string or token that ended generation, as
+	// reported by TabbyAPI, for telling apart several Stop entries. It is
+	// empty when generation ended for another reason.
+	MatchedStop string `json:"stop_str,omitempty"`
+}
+
+// ChatCompletionLogprobs represents log probabilities for a chat completion choice
+type ChatCompletionLogprobs struct {
+	Content []ChatCompletionLogprob `json:"content"`
+}
+
+// ChatCompletionLogprob represents the log probability of a single token,
+// along with the most likely alternatives at that position
+type ChatCompletionLogprob struct {
+	Token       string                  `json:"token"`
+	Logprob     float64                 `json:"logprob"`
+	TopLogprobs []ChatCompletionLogprob `json:"top_logprobs,omitempty"`
 }
 
 // ChatCompletionStreamResponse represents a streaming chat completion response
@@ -233,9 +930,10 @@ type ChatCompletionStreamResponse struct {
 
 // ChatCompletionStreamChoice represents a streaming chat choice
 type ChatCompletionStreamChoice struct {
-	Index        int    `json:"index"`
-	Delta        *Delta `json:"delta"`
-	FinishReason string `json:"finish_reason,omitempty"`
+	Index        int                     `json:"index"`
+	Delta        *Delta                  `json:"delta"`
+	FinishReason string                  `json:"finish_reason,omitempty"`
+	Logprobs     *ChatCompletionLogprobs `json:"logprobs,omitempty"`
 }
 
 // Delta represents a delta in a streaming chat response
@@ -258,6 +956,39 @@ type EmbeddingsRequest struct {
 	EncodingFormat string      `json:"encoding_format,omitempty"`
 }
 
+// Validate checks that Input is a non-empty string or a non-empty array of
+// non-empty strings. It returns a *ValidationError for field "input"
+// otherwise, so empty requests are rejected without a round trip.
+func (r *EmbeddingsRequest) Validate() error {
+	switch input := r.Input.(type) {
+	case nil:
+		return &ValidationError{Field: "input", Message: "input is required"}
+	case string:
+		if input == "" {
+			return &ValidationError{Field: "input", Message: "input must not be empty"}
+		}
+	case []string:
+		if len(input) == 0 {
+			return &ValidationError{Field: "input", Message: "input must not be empty"}
+		}
+		for i, s := range input {
+			if s == "" {
+				return &ValidationError{Field: "input", Message: fmt.Sprintf("input[%d] must not be empty", i)}
+			}
+		}
+	case []interface{}:
+		if len(input) == 0 {
+			return &ValidationError{Field: "input", Message: "input must not be empty"}
+		}
+		for i, v := range input {
+			if s, ok := v.(string); !ok || s == "" {
+				return &ValidationError{Field: "input", Message: fmt.Sprintf("input[%d] must be a non-empty string", i)}
+			}
+		}
+	}
+	return nil
+}
+
 // EmbeddingsResponse represents a response to an embeddings request
 type EmbeddingsResponse struct {
 	Object string            `json:"object"`
@@ -273,6 +1004,49 @@ type EmbeddingObject struct {
 	Index     int         `json:"index"`
 }
 
+// AsFloat32 decodes the embedding into a float32 vector.
+//
+// The server returns embeddings either as a JSON array of numbers (the default
+// "float" encoding format) or as a base64 string of little-endian float32 values
+// (the "base64" encoding format). Both representations are handled.
+func (e *EmbeddingObject) AsFloat32() ([]float32, error) {
+	switch v := e.Embedding.(type) {
+	case []float32:
+		return v, nil
+	case []float64:
+		out := make([]float32, len(v))
+		for i, f := range v {
+			out[i] = float32(f)
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]float32, len(v))
+		for i, item := range v {
+			f, ok := item.(float64)
+			if !ok {
+				return nil, fmt.Errorf("embedding element %d has unexpected type %T", i, item)
+			}
+			out[i] = float32(f)
+		}
+		return out, nil
+	case string:
+		data, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 embedding: %w", err)
+		}
+		if len(data)%4 != 0 {
+			return nil, fmt.Errorf("base64 embedding has invalid length %d", len(data))
+		}
+		out := make([]float32, len(data)/4)
+		for i := range out {
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported embedding type %T", e.Embedding)
+	}
+}
+
 // UsageInfo represents token usage for embeddings
 type UsageInfo struct {
 	PromptTokens int `json:"prompt_tokens"`
@@ -286,19 +1060,47 @@ type ModelCard struct {
 	Created    int64                `json:"created"`
 	OwnedBy    string               `json:"owned_by"`
 	Parameters *ModelCardParameters `json:"parameters,omitempty"`
+
+	// Kind records which endpoint the card was listed from. It is set by
+	// ModelsService rather than sent by the server, and is empty for cards
+	// decoded elsewhere.
+	Kind ModelKind `json:"-"`
 }
 
+// IsHubReference reports whether the card's ID has the "org/name" form of a
+// Hugging Face Hub repository. Other IDs name a model in the server's local
+// model directory.
+func (c *ModelCard) IsHubReference() bool {
+	org, name, ok := strings.Cut(c.ID, "/")
+	return ok && org != "" && name != "" && !strings.Contains(name, "/")
+}
+
+// ModelKind identifies the role of a model card: a primary model, a draft
+// model for speculative decoding, or an embedding model.
+type ModelKind string
+
+const (
+	// ModelKindPrimary marks cards from List, ListAll, and Get
+	ModelKindPrimary ModelKind = "primary"
+
+	// ModelKindDraft marks cards from ListDraft
+	ModelKindDraft ModelKind = "draft"
+
+	// ModelKindEmbedding marks cards from ListEmbedding and GetEmbedding
+	ModelKindEmbedding ModelKind = "embedding"
+)
+
 // ModelCardParameters represents model parameters
 type ModelCardParameters struct {
-	MaxSeqLen      int     `json:"max_seq_len,omitempty"`
-	RopeScale      float64 `json:"rope_scale,omitempty"`
-	RopeAlpha      float64 `json:"rope_alpha,omitempty"`
-	MaxBatchSize   int     `json:"max_batch_size,omitempty"`
-	CacheSize      int     `json:"cache_size,omitempty"`
-	CacheMode      string  `json:"cache_mode,omitempty"`
-	ChunkSize      int     `json:"chunk_size,omitempty"`
-	PromptTemplate string  `json:"prompt_template,omitempty"`
-	UseVision      bool    `json:"use_vision,omitempty"`
+	MaxSeqLen      int       `json:"max_seq_len,omitempty"`
+	RopeScale      float64   `json:"rope_scale,omitempty"`
+	RopeAlpha      float64   `json:"rope_alpha,omitempty"`
+	MaxBatchSize   int       `json:"max_batch_size,omitempty"`
+	CacheSize      int       `json:"cache_size,omitempty"`
+	CacheMode      CacheMode `json:"cache_mode,omitempty"`
+	ChunkSize      int       `json:"chunk_size,omitempty"`
+	PromptTemplate string    `json:"prompt_template,omitempty"`
+	UseVision      bool      `json:"use_vision,omitempty"`
 }
 
 // ModelList represents a list of models
@@ -307,6 +1109,24 @@ type ModelList struct {
 	Data   []ModelCard `json:"data"`
 }
 
+// setKind tags every card in the list with kind.
+func (l *ModelList) setKind(kind ModelKind) {
+	for i := range l.Data {
+		l.Data[i].Kind = kind
+	}
+}
+
+// ByOwner returns the cards whose OwnedBy matches owner, ignoring case.
+func (l *ModelList) ByOwner(owner string) []ModelCard {
+	var owned []ModelCard
+	for _, card := range l.Data {
+		if strings.EqualFold(card.OwnedBy, owner) {
+			owned = append(owned, card)
+		}
+	}
+	return owned
+}
+
 // ModelLoadRequest represents a request to load a model
 type ModelLoadRequest struct {
 	ModelName      string      `json:"model_name"`
@@ -315,18 +1135,74 @@ type ModelLoadRequest struct {
 	RopeAlpha      interface{} `json:"rope_alpha,omitempty"` // float64 or "auto"
 	GPUSplit       []float64   `json:"gpu_split,omitempty"`
 	CacheSize      int         `json:"cache_size,omitempty"`
-	CacheMode      string      `json:"cache_mode,omitempty"`
+	CacheMode      CacheMode   `json:"cache_mode,omitempty"`
 	ChunkSize      int         `json:"chunk_size,omitempty"`
 	PromptTemplate string      `json:"prompt_template,omitempty"`
 	// Additional parameters may be added later
 }
 
+// CacheMode is the KV cache precision used when loading a model. It is a
+// string type, so modes added by newer servers still decode from responses.
+type CacheMode string
+
+const (
+	// CacheModeFP16 stores the cache at full 16-bit precision
+	CacheModeFP16 CacheMode = "FP16"
+
+	// CacheModeQ8 stores the cache quantized to 8 bits
+	CacheModeQ8 CacheMode = "Q8"
+
+	// CacheModeQ6 stores the cache quantized to 6 bits
+	CacheModeQ6 CacheMode = "Q6"
+
+	// CacheModeQ4 stores the cache quantized to 4 bits
+	CacheModeQ4 CacheMode = "Q4"
+)
+
+// Valid reports whether m is one of the cache modes supported by TabbyAPI.
+func (m CacheMode) Valid() bool {
+	switch m {
+	case CacheModeFP16, CacheModeQ8, CacheModeQ6, CacheModeQ4:
+		return true
+	}
+	return false
+}
+
+// Validate checks that CacheMode, if set, is a supported cache mode. It
+// returns a *ValidationError for field "cache_mode" otherwise.
+func (r *ModelLoadRequest) Validate() error {
+	if r.CacheMode != "" && !r.CacheMode.Valid() {
+		return &ValidationError{
+			Field:   "cache_mode",
+			Message: fmt.Sprintf("unsupported cache mode %q (expected FP16, Q8, Q6, or Q4)", r.CacheMode),
+		}
+	}
+	return nil
+}
+
 // ModelLoadResponse represents a response to a model load request
 type ModelLoadResponse struct {
 	ModelType string `json:"model_type"`
 	Module    int    `json:"module"`
 	Modules   int    `json:"modules"`
 	Status    string `json:"status"`
+
+	// Model is the loaded model as reported by the server after a successful
+	// ModelsService.Load, so the effective parameters (such as MaxSeqLen and
+	// CacheMode, which may differ from those requested) can be confirmed. It
+	// is nil for streamed progress updates and if the lookup failed.
+	Model *ModelCard `json:"-"`
+}
+
+// Progress returns the number of modules loaded, the total, and the
+// percentage loaded. Modules is 0 until the server knows the total, so pct
+// is 0 rather than NaN until then, and it never exceeds 100.
+func (r *ModelLoadResponse) Progress() (done, total int, pct float64) {
+	done, total = r.Module, r.Modules
+	if total <= 0 {
+		return done, total, 0
+	}
+	return done, total, math.Min(float64(done)/float64(total)*100, 100)
 }
 
 // ModelPropsResponse represents a response to a model props request
@@ -334,6 +1210,22 @@ type ModelPropsResponse struct {
 	TotalSlots                int                             `json:"total_slots"`
 	ChatTemplate              string                          `json:"chat_template"`
 	DefaultGenerationSettings *ModelDefaultGenerationSettings `json:"default_generation_settings"`
+
+	// maxSeqLen is the loaded model's MaxSeqLen, fetched by GetProps when
+	// the props carry no context length
+	maxSeqLen int
+}
+
+// ContextLength returns the model's context length in tokens.
+//
+// It prefers DefaultGenerationSettings.NCtx. If the settings are missing or
+// report zero, it falls back to the loaded model's MaxSeqLen as looked up by
+// ModelsService.GetProps, and returns 0 if neither is known.
+func (r *ModelPropsResponse) ContextLength() int {
+	if r.DefaultGenerationSettings != nil && r.DefaultGenerationSettings.NCtx > 0 {
+		return r.DefaultGenerationSettings.NCtx
+	}
+	return r.maxSeqLen
 }
 
 // ModelDefaultGenerationSettings represents default generation settings
@@ -343,8 +1235,47 @@ type ModelDefaultGenerationSettings struct {
 
 // EmbeddingModelLoadRequest represents a request to load an embedding model
 type EmbeddingModelLoadRequest struct {
-	EmbeddingModelName string `json:"embedding_model_name"`
-	EmbeddingsDevice   string `json:"embeddings_device,omitempty"`
+	EmbeddingModelName string           `json:"embedding_model_name"`
+	EmbeddingsDevice   EmbeddingsDevice `json:"embeddings_device,omitempty"`
+}
+
+// EmbeddingsDevice is the device an embedding model is loaded on
+type EmbeddingsDevice string
+
+const (
+	// DeviceCPU loads the embedding model on the CPU (the server default)
+	DeviceCPU EmbeddingsDevice = "cpu"
+
+	// DeviceCUDA loads the embedding model on a CUDA GPU
+	DeviceCUDA EmbeddingsDevice = "cuda"
+
+	// DeviceAuto lets the server choose the device
+	DeviceAuto EmbeddingsDevice = "auto"
+)
+
+// Valid reports whether d is one of the devices supported by TabbyAPI.
+func (d EmbeddingsDevice) Valid() bool {
+	switch d {
+	case DeviceCPU, DeviceCUDA, DeviceAuto:
+		return true
+	}
+	return false
+}
+
+// Validate checks that EmbeddingModelName is set and that EmbeddingsDevice,
+// if set, is a supported device. It returns a *ValidationError otherwise, so
+// typos such as "gpu" are caught before the request is sent.
+func (r *EmbeddingModelLoadRequest) Validate() error {
+	if r.EmbeddingModelName == "" {
+		return &ValidationError{Field: "embedding_model_name", Message: "embedding model name is required"}
+	}
+	if r.EmbeddingsDevice != "" && !r.EmbeddingsDevice.Valid() {
+		return &ValidationError{
+			Field:   "embeddings_device",
+			Message: fmt.Sprintf("unsupported device %q (expected cpu, cuda, or auto)", r.EmbeddingsDevice),
+		}
+	}
+	return nil
 }
 
 // DownloadRequest represents a request to download a model
@@ -358,6 +1289,33 @@ type DownloadRequest struct {
 	Exclude    []string `json:"exclude,omitempty"`
 }
 
+// WithSafetensorsOnly restricts the download to safetensors weights and JSON
+// config files, skipping duplicate weight formats such as .bin or .pt. It
+// replaces any existing Include patterns and returns r for chaining.
+func (r *DownloadRequest) WithSafetensorsOnly() *DownloadRequest {
+	r.Include = []string{"*.safetensors", "*.json"}
+	return r
+}
+
+// Validate checks that every Include and Exclude pattern is non-empty. It
+// returns a *ValidationError naming the offending field otherwise.
+func (r *DownloadRequest) Validate() error {
+	if err := validatePatterns("include", r.Include); err != nil {
+		return err
+	}
+	return validatePatterns("exclude", r.Exclude)
+}
+
+// validatePatterns rejects empty or blank glob patterns.
+func validatePatterns(field string, patterns []string) error {
+	for i, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("%s[%d] must not be empty", field, i)}
+		}
+	}
+	return nil
+}
+
 // DownloadResponse represents a response to a download request
 type DownloadResponse struct {
 	DownloadPath string `json:"download_path"`
@@ -370,6 +1328,10 @@ type LoraCard struct {
 	Created int64   `json:"created"`
 	OwnedBy string  `json:"owned_by"`
 	Scaling float64 `json:"scaling,omitempty"`
+
+	// BaseModel is the model the adapter was trained for, when the server
+	// reports it
+	BaseModel string `json:"base_model,omitempty"`
 }
 
 // LoraList represents a list of LoRA adapters
@@ -378,6 +1340,20 @@ type LoraList struct {
 	Data   []LoraCard `json:"data"`
 }
 
+// CompatibleWith returns the adapters usable with the model modelID, such as
+// the ID from ModelsService.Get. Base models are compared case-insensitively.
+// Adapters without a reported BaseModel are included, since their
+// compatibility cannot be ruled out.
+func (l *LoraList) CompatibleWith(modelID string) []LoraCard {
+	var compatible []LoraCard
+	for _, card := range l.Data {
+		if card.BaseModel == "" || strings.EqualFold(card.BaseModel, modelID) {
+			compatible = append(compatible, card)
+		}
+	}
+	return compatible
+}
+
 // LoraLoadInfo represents information for loading a LoRA adapter
 type LoraLoadInfo struct {
 	Name    string  `json:"name"`
@@ -423,6 +1399,18 @@ type TokenDecodeResponse struct {
 	Text string `json:"text"`
 }
 
+// TokenInfo pairs a token ID with the text it decodes to, as returned by
+// TokensService.Inspect
+type TokenInfo struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+// String formats the token as its ID and quoted text, such as 9906:"Hello".
+func (t TokenInfo) String() string {
+	return fmt.Sprintf("%d:%q", t.ID, t.Text)
+}
+
 // TemplateList represents a list of templates
 type TemplateList struct {
 	Object string   `json:"object"`
@@ -441,6 +1429,58 @@ type SamplerOverrideListResponse struct {
 	Presets        []string               `json:"presets"`
 }
 
+// overrideValue returns the value of an override. TabbyAPI reports each
+// override either as a bare value or as an object whose "override" key holds
+// the value alongside flags such as "force"; both forms are accepted.
+func (r *SamplerOverrideListResponse) overrideValue(key string) (interface{}, bool) {
+	v, ok := r.Overrides[key]
+	if !ok {
+		return nil, false
+	}
+	if obj, isObj := v.(map[string]interface{}); isObj {
+		v, ok = obj["override"]
+	}
+	return v, ok
+}
+
+// GetFloat returns the numeric override for key.
+func (r *SamplerOverrideListResponse) GetFloat(key string) (float64, bool) {
+	v, ok := r.overrideValue(key)
+	if !ok {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// GetInt returns the override for key as an int. JSON decodes numbers as
+// float64, so a value is accepted only if it has no fractional part.
+func (r *SamplerOverrideListResponse) GetInt(key string) (int, bool) {
+	f, ok := r.GetFloat(key)
+	if !ok || f != math.Trunc(f) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// GetString returns the string override for key.
+func (r *SamplerOverrideListResponse) GetString(key string) (string, bool) {
+	v, ok := r.overrideValue(key)
+	if !ok {
+		return "", false
+	}
+	str, ok := v.(string)
+	return str, ok
+}
+
 // SamplerOverrideSwitchRequest represents a request to switch sampler overrides
 type SamplerOverrideSwitchRequest struct {
 	Preset    string                 `json:"preset,omitempty"`
@@ -453,12 +1493,99 @@ type HealthCheckResponse struct {
 	Issues []UnhealthyEvent `json:"issues,omitempty"`
 }
 
+// IsHealthy reports whether the server is healthy with no reported issues.
+func (r *HealthCheckResponse) IsHealthy() bool {
+	return r.Status == "healthy" && len(r.Issues) == 0
+}
+
+// IsDegraded reports whether the server reports a healthy status but still
+// lists issues, meaning it is serving requests with problems.
+func (r *HealthCheckResponse) IsDegraded() bool {
+	return r.Status == "healthy" && len(r.Issues) > 0
+}
+
+// WorstIssue returns the most significant reported issue, or nil if there are none.
+//
+// The server does not rank issues by severity, so the most recent one is
+// considered the worst. Issues with unparseable times rank below those with
+// valid times; ties keep the later entry in the list.
+func (r *HealthCheckResponse) WorstIssue() *UnhealthyEvent {
+	var worst *UnhealthyEvent
+	var worstTime time.Time
+	for i := range r.Issues {
+		issue := &r.Issues[i]
+		t, err := issue.ParsedTime()
+		if err != nil {
+			t = time.Time{}
+		}
+		if worst == nil || !t.Before(worstTime) {
+			worst, worstTime = issue, t
+		}
+	}
+	return worst
+}
+
+// overloadIssueMarkers are lowercase fragments of issue descriptions that
+// indicate the server is out of resources rather than broken.
+var overloadIssueMarkers = []string{
+	"out of memory",
+	"outofmemory", // torch.OutOfMemoryError
+	"queue is full",
+	"queue full",
+	"too many requests",
+	"overloaded",
+}
+
+// HasIssueContaining reports whether any issue description contains substr,
+// compared case-insensitively.
+func (r *HealthCheckResponse) HasIssueContaining(substr string) bool {
+	substr = strings.ToLower(substr)
+	for _, issue := range r.Issues {
+		if strings.Contains(strings.ToLower(issue.Description), substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOverloaded reports whether any issue describes resource exhaustion, such
+// as a GPU out-of-memory error or a saturated generation queue. Monitoring
+// code can use it to back off rather than treat the server as down.
+func (r *HealthCheckResponse) IsOverloaded() bool {
+	for _, marker := range overloadIssueMarkers {
+		if r.HasIssueContaining(marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // UnhealthyEvent represents an issue in a health check
 type UnhealthyEvent struct {
 	Time        string `json:"time"`
 	Description string `json:"description"`
 }
 
+// unhealthyEventTimeLayouts are the formats the server may use for UnhealthyEvent.Time.
+// Python's isoformat omits the zone for naive UTC timestamps.
+var unhealthyEventTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// ParsedTime parses Time into a time.Time. Timestamps without a zone are
+// interpreted as UTC, matching the server's documented behavior.
+func (e *UnhealthyEvent) ParsedTime() (time.Time, error) {
+	for _, layout := range unhealthyEventTimeLayouts {
+		if t, err := time.Parse(layout, e.Time); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized health event time %q", e.Time)
+}
+
 // AuthPermissionResponse represents a response to an auth permission check
 type AuthPermissionResponse struct {
 	Permission string `json:"permission"`
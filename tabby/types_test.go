@@ -0,0 +1,1090 @@
+package tabby
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerationRequests_SkipQueueMarshaling(t *testing.T) {
+	tests := []struct {
+		name string
+		req  interface{}
+		want bool
+	}{
+		{"completion with skip_queue", &CompletionRequest{Prompt: "hi", SkipQueue: true}, true},
+		{"completion without skip_queue", &CompletionRequest{Prompt: "hi"}, false},
+		{"chat with skip_queue", &ChatCompletionRequest{SkipQueue: true}, true},
+		{"chat without skip_queue", &ChatCompletionRequest{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.req)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+			if got := strings.Contains(string(data), `"skip_queue":true`); got != tt.want {
+				t.Errorf("Expected skip_queue present=%v, got JSON %s", tt.want, data)
+			}
+		})
+	}
+}
+
+func TestGenerationRequests_StreamOptionsMarshaling(t *testing.T) {
+	tests := []struct {
+		name string
+		req  interface{}
+		want string
+	}{
+		{"completion with include_usage", &CompletionRequest{StreamOptions: &StreamOptions{IncludeUsage: true}}, `"stream_options":{"include_usage":true}`},
+		{"chat with include_usage", &ChatCompletionRequest{StreamOptions: &StreamOptions{IncludeUsage: true}}, `"stream_options":{"include_usage":true}`},
+		{"chat with empty options", &ChatCompletionRequest{StreamOptions: &StreamOptions{}}, `"stream_options":{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.req)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("Expected %s in JSON, got %s", tt.want, data)
+			}
+		})
+	}
+
+	data, err := json.Marshal(&ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	if strings.Contains(string(data), "stream_options") {
+		t.Errorf("Expected stream_options to be omitted, got %s", data)
+	}
+}
+
+func TestGenerationRequests_IncludeStopStrMarshaling(t *testing.T) {
+	tests := []struct {
+		name string
+		req  interface{}
+		want bool
+	}{
+		{"completion with include_stop_str_in_output", &CompletionRequest{Prompt: "hi", IncludeStopStrInOutput: true}, true},
+		{"completion without include_stop_str_in_output", &CompletionRequest{Prompt: "hi"}, false},
+		{"chat with include_stop_str_in_output", &ChatCompletionRequest{IncludeStopStrInOutput: true}, true},
+		{"chat without include_stop_str_in_output", &ChatCompletionRequest{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.req)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+			if got := strings.Contains(string(data), `"include_stop_str_in_output":true`); got != tt.want {
+				t.Errorf("Expected include_stop_str_in_output present=%v, got JSON %s", tt.want, data)
+			}
+		})
+	}
+}
+
+func TestGenerationRequests_ZeroSamplingMarshaling(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     interface{}
+		want    []string
+		notWant []string
+	}{
+		{"completion with zero temperature", &CompletionRequest{Prompt: "hi", Temperature: Float64(0)}, []string{`"temperature":0`}, []string{"top_p"}},
+		{"chat with zero temperature and top_p", &ChatCompletionRequest{Temperature: Float64(0), TopP: Float64(0)}, []string{`"temperature":0`, `"top_p":0`}, nil},
+		{"chat without sampling", &ChatCompletionRequest{}, nil, []string{"temperature", "top_p"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.req)
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("Expected %s in JSON, got %s", want, data)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(data), notWant) {
+					t.Errorf("Expected %s to be omitted, got %s", notWant, data)
+				}
+			}
+		})
+	}
+}
+
+func TestChatCompletionRequest_AddGenerationPromptMarshaling(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *bool
+		want  string
+	}{
+		{"unset", nil, ""},
+		{"true", Bool(true), `"add_generation_prompt":true`},
+		{"false", Bool(false), `"add_generation_prompt":false`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(&ChatCompletionRequest{AddGenerationPrompt: tt.value})
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+			if tt.want == "" {
+				if strings.Contains(string(data), "add_generation_prompt") {
+					t.Errorf("Expected add_generation_prompt to be omitted, got %s", data)
+				}
+			} else if !strings.Contains(string(data), tt.want) {
+				t.Errorf("Expected %s in JSON, got %s", tt.want, data)
+			}
+		})
+	}
+}
+
+func TestGenerationRequests_TokenFilterMarshaling(t *testing.T) {
+	req := &ChatCompletionRequest{
+		BannedTokens:   []int{13},
+		BannedStrings:  []string{"sorry"},
+		AllowedTokens:  []int{1, 2},
+		AllowedStrings: []string{"yes", "no"},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	for _, want := range []string{
+		`"banned_tokens":[13]`,
+		`"banned_strings":["sorry"]`,
+		`"allowed_tokens":[1,2]`,
+		`"allowed_strings":["yes","no"]`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %s in JSON, got %s", want, data)
+		}
+	}
+
+	data, err = json.Marshal(&CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	if strings.Contains(string(data), "allowed_") || strings.Contains(string(data), "banned_") {
+		t.Errorf("Expected token filters to be omitted, got %s", data)
+	}
+}
+
+func TestValidateTokenFilters(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowedTokens  []int
+		bannedTokens   []int
+		allowedStrings []string
+		bannedStrings  []string
+		wantField      string
+	}{
+		{"disjoint", []int{1, 2}, []int{3}, []string{"yes"}, []string{"no"}, ""},
+		{"ban only", nil, []int{3}, nil, []string{"no"}, ""},
+		{"token conflict", []int{1, 3}, []int{3}, nil, nil, "allowed_tokens"},
+		{"string conflict", nil, nil, []string{"yes", "no"}, []string{"no"}, "allowed_strings"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTokenFilters(tt.allowedTokens, tt.bannedTokens, tt.allowedStrings, tt.bannedStrings)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+				t.Errorf("Expected *ValidationError for %s, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestResponses_FirstChoice(t *testing.T) {
+	completion := &CompletionResponse{Choices: []CompletionRespChoice{{Text: "first"}, {Text: "second", Index: 1}}}
+	if text, ok := completion.FirstText(); !ok || text != "first" {
+		t.Errorf("Expected first text, got %q (ok=%v)", text, ok)
+	}
+	if text, ok := (&CompletionResponse{}).FirstText(); ok || text != "" {
+		t.Errorf("Expected no text for empty choices, got %q (ok=%v)", text, ok)
+	}
+
+	chat := &ChatCompletionResponse{Choices: []ChatCompletionRespChoice{{
+		Message: ChatMessage{Role: ChatMessageRoleAssistant, Content: "hi"},
+	}}}
+	if msg, ok := chat.FirstMessage(); !ok || msg.Content != "hi" {
+		t.Errorf("Expected first message, got %+v (ok=%v)", msg, ok)
+	}
+	if msg, ok := (&ChatCompletionResponse{}).FirstMessage(); ok || msg.Role != "" {
+		t.Errorf("Expected no message for empty choices, got %+v (ok=%v)", msg, ok)
+	}
+}
+
+func TestSanitizeMessages(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: ChatMessageRoleUser, Content: "Hello"},
+		{Role: ChatMessageRoleUser, Content: ""},
+		{Role: ChatMessageRoleUser, Content: "How are you?"},
+		{Role: ChatMessageRoleAssistant, Content: "Fine."},
+	}
+
+	got := SanitizeMessages(messages, true)
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 messages, got %d: %+v", len(got), got)
+	}
+	if got[1].Role != ChatMessageRoleUser || got[1].Content != "Hello\nHow are you?" {
+		t.Errorf("Expected merged user message, got %+v", got[1])
+	}
+	if messages[1].Content != "Hello" {
+		t.Errorf("Expected input to be unchanged, got %+v", messages[1])
+	}
+
+	// Without dropping, the empty message is kept and separates its neighbours
+	got = SanitizeMessages(messages, false)
+	want := []interface{}{"Be brief.", "Hello", "", "How are you?", "Fine."}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d messages, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Content != want[i] {
+			t.Errorf("Message %d: expected %q, got %+v", i, want[i], got[i])
+		}
+	}
+
+	merged := SanitizeMessages([]ChatMessage{
+		{Role: ChatMessageRoleUser, Content: "Hello"},
+		{Role: ChatMessageRoleUser, Content: "Again"},
+	}, false)
+	if len(merged) != 1 || merged[0].Content != "Hello\nAgain" {
+		t.Errorf("Expected non-empty messages merged without dropping, got %+v", merged)
+	}
+}
+
+func TestEnforceSystemFirst(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: ChatMessageRoleUser, Content: "Hello"},
+		{Role: ChatMessageRoleAssistant, Content: "Hi."},
+		{Role: ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: ChatMessageRoleUser, Content: "How are you?"},
+	}
+
+	got, err := EnforceSystemFirst(messages, false)
+	if err != nil {
+		t.Fatalf("EnforceSystemFirst returned error: %v", err)
+	}
+	want := []interface{}{"Be brief.", "Hello", "Hi.", "How are you?"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d messages, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Content != want[i] {
+			t.Errorf("Message %d: expected %v, got %+v", i, want[i], got[i])
+		}
+	}
+	if messages[0].Role != ChatMessageRoleUser {
+		t.Errorf("Expected input to be unchanged, got %+v", messages)
+	}
+
+	noSystem := messages[:2]
+	if got, err := EnforceSystemFirst(noSystem, false); err != nil || len(got) != 2 || got[0].Content != "Hello" {
+		t.Errorf("Expected messages without a system message unchanged, got %+v (err=%v)", got, err)
+	}
+}
+
+func TestEnforceSystemFirst_MultipleSystem(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: ChatMessageRoleUser, Content: "Hello"},
+		{Role: ChatMessageRoleSystem, Content: "Be kind."},
+	}
+
+	_, err := EnforceSystemFirst(messages, false)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "messages" {
+		t.Fatalf("Expected messages ValidationError, got %v", err)
+	}
+
+	got, err := EnforceSystemFirst(messages, true)
+	if err != nil {
+		t.Fatalf("EnforceSystemFirst with merging returned error: %v", err)
+	}
+	if len(got) != 2 || got[0].Content != "Be brief.\nBe kind." || got[1].Content != "Hello" {
+		t.Errorf("Expected merged system message first, got %+v", got)
+	}
+}
+
+func TestSanitizeMessages_StructuredContent(t *testing.T) {
+	image := ChatMessageContent{Type: "image_url", ImageURL: &ChatImageURL{URL: "http://example.com/a.png"}}
+	got := SanitizeMessages([]ChatMessage{
+		{Role: ChatMessageRoleUser, Content: "Describe this:"},
+		{Role: ChatMessageRoleUser, Content: []ChatMessageContent{image}},
+	}, true)
+
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(got))
+	}
+	parts, ok := got[0].Content.([]ChatMessageContent)
+	if !ok || len(parts) != 2 {
+		t.Fatalf("Expected 2 content parts, got %#v", got[0].Content)
+	}
+	if parts[0].Text != "Describe this:" || parts[1].ImageURL == nil {
+		t.Errorf("Unexpected merged parts: %+v", parts)
+	}
+}
+
+func TestEmbeddingObject_AsFloat32(t *testing.T) {
+	tests := []struct {
+		name      string
+		embedding interface{}
+		want      []float32
+		wantErr   bool
+	}{
+		{"json array", []interface{}{1.0, -0.5}, []float32{1.0, -0.5}, false},
+		// 1.0 and -0.5 as little-endian float32
+		{"base64", "AACAPwAAAL8=", []float32{1.0, -0.5}, false},
+		{"invalid base64 length", "AAA=", nil, true},
+		{"unsupported type", 42, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &EmbeddingObject{Embedding: tt.embedding}
+			got, err := obj.AsFloat32()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("Index %d: expected %v, got %v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddingsRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   interface{}
+		wantErr bool
+	}{
+		{"nil", nil, true},
+		{"empty string", "", true},
+		{"empty slice", []string{}, true},
+		{"slice with empty element", []string{"a", ""}, true},
+		{"empty interface slice", []interface{}{}, true},
+		{"string", "hello", false},
+		{"slice", []string{"a", "b"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&EmbeddingsRequest{Input: tt.input}).Validate()
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected *ValidationError, got %v", err)
+			}
+			if validationErr.Field != "input" {
+				t.Errorf("Expected field %q, got %q", "input", validationErr.Field)
+			}
+		})
+	}
+}
+
+func TestEmbeddingModelLoadRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       EmbeddingModelLoadRequest
+		wantField string
+	}{
+		{"cpu", EmbeddingModelLoadRequest{EmbeddingModelName: "bge", EmbeddingsDevice: DeviceCPU}, ""},
+		{"cuda", EmbeddingModelLoadRequest{EmbeddingModelName: "bge", EmbeddingsDevice: DeviceCUDA}, ""},
+		{"auto", EmbeddingModelLoadRequest{EmbeddingModelName: "bge", EmbeddingsDevice: DeviceAuto}, ""},
+		{"default device", EmbeddingModelLoadRequest{EmbeddingModelName: "bge"}, ""},
+		{"empty name", EmbeddingModelLoadRequest{EmbeddingsDevice: DeviceCPU}, "embedding_model_name"},
+		{"invalid device", EmbeddingModelLoadRequest{EmbeddingModelName: "bge", EmbeddingsDevice: "gpu"}, "embeddings_device"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected *ValidationError, got %v", err)
+			}
+			if validationErr.Field != tt.wantField {
+				t.Errorf("Expected field %q, got %q", tt.wantField, validationErr.Field)
+			}
+		})
+	}
+}
+
+func TestModelLoadRequest_ValidateCacheMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    CacheMode
+		wantErr bool
+	}{
+		{"unset", "", false},
+		{"valid constant", CacheModeQ4, false},
+		{"valid string", "FP16", false},
+		{"invalid", "Q5", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&ModelLoadRequest{ModelName: "model", CacheMode: tt.mode}).Validate()
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != "cache_mode" {
+				t.Fatalf("Expected cache_mode *ValidationError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestModelPropsResponse_ContextLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		props ModelPropsResponse
+		want  int
+	}{
+		{"present", ModelPropsResponse{DefaultGenerationSettings: &ModelDefaultGenerationSettings{NCtx: 4096}, maxSeqLen: 2048}, 4096},
+		{"nil settings", ModelPropsResponse{}, 0},
+		{"fallback for nil settings", ModelPropsResponse{maxSeqLen: 2048}, 2048},
+		{"fallback for zero n_ctx", ModelPropsResponse{DefaultGenerationSettings: &ModelDefaultGenerationSettings{}, maxSeqLen: 2048}, 2048},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.props.ContextLength(); got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDownloadRequest_WithSafetensorsOnly(t *testing.T) {
+	req := (&DownloadRequest{RepoID: "org/model", Include: []string{"*.bin"}}).WithSafetensorsOnly()
+
+	want := []string{"*.safetensors", "*.json"}
+	if len(req.Include) != len(want) {
+		t.Fatalf("Expected include %v, got %v", want, req.Include)
+	}
+	for i := range want {
+		if req.Include[i] != want[i] {
+			t.Errorf("Include[%d]: expected %q, got %q", i, want[i], req.Include[i])
+		}
+	}
+	if err := req.Validate(); err != nil {
+		t.Errorf("Expected valid request, got %v", err)
+	}
+}
+
+func TestDownloadRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       DownloadRequest
+		wantField string
+	}{
+		{"no patterns", DownloadRequest{RepoID: "org/model"}, ""},
+		{"empty include", DownloadRequest{Include: []string{"*.json", ""}}, "include"},
+		{"blank exclude", DownloadRequest{Exclude: []string{" "}}, "exclude"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+				t.Fatalf("Expected %s *ValidationError, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestSamplerOverrideListResponse_Accessors(t *testing.T) {
+	payload := `{
+		"selected_preset": "creative",
+		"overrides": {
+			"temperature": {"override": 0.8, "force": false},
+			"top_k": {"override": 40, "force": true},
+			"min_p": 0.05,
+			"max_tokens": 512,
+			"grammar_string": {"override": "root ::= \"yes\"", "force": false},
+			"banned_strings": {"override": ["foo"], "force": false}
+		},
+		"presets": ["creative"]
+	}`
+
+	var resp SamplerOverrideListResponse
+	if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	t.Run("GetFloat", func(t *testing.T) {
+		if v, ok := resp.GetFloat("temperature"); !ok || v != 0.8 {
+			t.Errorf("Expected temperature 0.8, got %v (ok=%v)", v, ok)
+		}
+		if v, ok := resp.GetFloat("min_p"); !ok || v != 0.05 {
+			t.Errorf("Expected bare min_p 0.05, got %v (ok=%v)", v, ok)
+		}
+		if _, ok := resp.GetFloat("grammar_string"); ok {
+			t.Error("Expected a string override not to read as a float")
+		}
+		if _, ok := resp.GetFloat("missing"); ok {
+			t.Error("Expected a missing key to report false")
+		}
+	})
+
+	t.Run("GetInt", func(t *testing.T) {
+		if v, ok := resp.GetInt("top_k"); !ok || v != 40 {
+			t.Errorf("Expected top_k 40, got %v (ok=%v)", v, ok)
+		}
+		if v, ok := resp.GetInt("max_tokens"); !ok || v != 512 {
+			t.Errorf("Expected bare max_tokens 512, got %v (ok=%v)", v, ok)
+		}
+		if _, ok := resp.GetInt("temperature"); ok {
+			t.Error("Expected a fractional value not to read as an int")
+		}
+	})
+
+	t.Run("GetString", func(t *testing.T) {
+		if v, ok := resp.GetString("grammar_string"); !ok || v != `root ::= "yes"` {
+			t.Errorf("Expected grammar string, got %q (ok=%v)", v, ok)
+		}
+		if _, ok := resp.GetString("banned_strings"); ok {
+			t.Error("Expected a list override not to read as a string")
+		}
+		if _, ok := resp.GetString("top_k"); ok {
+			t.Error("Expected a numeric override not to read as a string")
+		}
+	})
+}
+
+func TestHealthCheckResponse_IsOverloaded(t *testing.T) {
+	payload := `{"status":"unhealthy","issues":[
+		{"time":"2024-05-01T10:00:00Z","description":"Generation failed"},
+		{"time":"2024-05-01T10:01:00Z","description":"CUDA out of memory. Tried to allocate 2.00 GiB"}
+	]}`
+
+	var resp HealthCheckResponse
+	if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal payload: %v", err)
+	}
+
+	if !resp.HasIssueContaining("generation FAILED") {
+		t.Error("Expected a case-insensitive match for the generation issue")
+	}
+	if resp.HasIssueContaining("model crashed") {
+		t.Error("Expected no match for an absent issue")
+	}
+	if !resp.IsOverloaded() {
+		t.Error("Expected an out-of-memory issue to count as overloaded")
+	}
+
+	resp.Issues = resp.Issues[:1]
+	if resp.IsOverloaded() {
+		t.Error("Expected a generic failure not to count as overloaded")
+	}
+}
+
+func TestHealthCheckResponse_States(t *testing.T) {
+	tests := []struct {
+		name         string
+		payload      string
+		wantHealthy  bool
+		wantDegraded bool
+		wantWorst    string
+	}{
+		{
+			name:        "healthy",
+			payload:     `{"status":"healthy","issues":[]}`,
+			wantHealthy: true,
+		},
+		{
+			name:         "degraded",
+			payload:      `{"status":"healthy","issues":[{"time":"2024-05-01T10:00:00Z","description":"slow"},{"time":"2024-05-01T11:00:00Z","description":"slower"}]}`,
+			wantDegraded: true,
+			wantWorst:    "slower",
+		},
+		{
+			name:      "down",
+			payload:   `{"status":"unhealthy","issues":[{"time":"2024-05-01T12:30:00.123456","description":"model crashed"},{"time":"bogus","description":"unknown"}]}`,
+			wantWorst: "model crashed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp HealthCheckResponse
+			if err := json.Unmarshal([]byte(tt.payload), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal payload: %v", err)
+			}
+
+			if got := resp.IsHealthy(); got != tt.wantHealthy {
+				t.Errorf("IsHealthy: expected %v, got %v", tt.wantHealthy, got)
+			}
+			if got := resp.IsDegraded(); got != tt.wantDegraded {
+				t.Errorf("IsDegraded: expected %v, got %v", tt.wantDegraded, got)
+			}
+
+			worst := resp.WorstIssue()
+			if tt.wantWorst == "" {
+				if worst != nil {
+					t.Errorf("Expected no worst issue, got %+v", worst)
+				}
+				return
+			}
+			if worst == nil || worst.Description != tt.wantWorst {
+				t.Errorf("Expected worst issue %q, got %+v", tt.wantWorst, worst)
+			}
+		})
+	}
+}
+
+func TestUnhealthyEvent_ParsedTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	for _, value := range []string{"2024-05-01T12:30:00.123456Z", "2024-05-01T12:30:00.123456", "2024-05-01 12:30:00.123456"} {
+		got, err := (&UnhealthyEvent{Time: value}).ParsedTime()
+		if err != nil {
+			t.Errorf("ParsedTime(%q) returned an error: %v", value, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("ParsedTime(%q): expected %v, got %v", value, want, got)
+		}
+	}
+
+	if _, err := (&UnhealthyEvent{Time: "yesterday"}).ParsedTime(); err == nil {
+		t.Error("Expected an error for an invalid time")
+	}
+}
+
+func TestChatMessage_ToolResultRoundTrip(t *testing.T) {
+	msg := ChatMessage{Role: ChatMessageRoleTool, Content: `{"temp":21}`, ToolCallID: "call_1"}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	if !strings.Contains(string(data), `"tool_call_id":"call_1"`) {
+		t.Errorf("Expected tool_call_id in JSON, got %s", data)
+	}
+
+	var decoded ChatMessage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal message: %v", err)
+	}
+	if decoded != msg {
+		t.Errorf("Expected %+v after round trip, got %+v", msg, decoded)
+	}
+
+	// Plain messages omit the field
+	data, err = json.Marshal(ChatMessage{Role: ChatMessageRoleUser, Content: "hi"})
+	if err != nil {
+		t.Fatalf("Failed to marshal message: %v", err)
+	}
+	if strings.Contains(string(data), "tool_call_id") {
+		t.Errorf("Expected tool_call_id to be omitted, got %s", data)
+	}
+
+	// Results for different tool calls are not merged
+	results := SanitizeMessages([]ChatMessage{
+		msg,
+		{Role: ChatMessageRoleTool, Content: "done", ToolCallID: "call_2"},
+	}, true)
+	if len(results) != 2 {
+		t.Errorf("Expected 2 tool results, got %+v", results)
+	}
+}
+
+func TestChatMessage_UnmarshalStructuredContent(t *testing.T) {
+	payload := `{
+		"id": "chat-1",
+		"object": "chat.completion",
+		"choices": [{
+			"index": 0,
+			"message": {
+				"role": "assistant",
+				"content": [
+					{"type": "text", "text": "Here is the image:"},
+					{"type": "image_url", "image_url": {"url": "https://example.com/cat.png"}}
+				]
+			},
+			"finish_reason": "stop"
+		}, {
+			"index": 1,
+			"message": {"role": "assistant", "content": "plain text"}
+		}]
+	}`
+
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	msg := resp.Choices[0].Message
+	if msg.Role != ChatMessageRoleAssistant {
+		t.Errorf("Expected assistant role, got %s", msg.Role)
+	}
+	parts, ok := msg.Content.([]ChatMessageContent)
+	if !ok {
+		t.Fatalf("Expected []ChatMessageContent, got %T", msg.Content)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("Expected 2 content parts, got %d", len(parts))
+	}
+	if parts[0].Type != "text" || parts[0].Text != "Here is the image:" {
+		t.Errorf("Unexpected text part: %+v", parts[0])
+	}
+	if parts[1].Type != "image_url" || parts[1].ImageURL == nil || parts[1].ImageURL.URL != "https://example.com/cat.png" {
+		t.Errorf("Unexpected image part: %+v", parts[1])
+	}
+
+	if text, ok := resp.Choices[1].Message.Content.(string); !ok || text != "plain text" {
+		t.Errorf("Expected string content, got %#v", resp.Choices[1].Message.Content)
+	}
+}
+
+func TestResponse_UnmarshalMatchedStop(t *testing.T) {
+	var completion CompletionResponse
+	payload := `{"choices": [{"index": 0, "text": "1. Apples", "finish_reason": "stop", "stop_str": "\n2."}]}`
+	if err := json.Unmarshal([]byte(payload), &completion); err != nil {
+		t.Fatalf("Failed to unmarshal completion: %v", err)
+	}
+	if got := completion.Choices[0].MatchedStop; got != "\n2." {
+		t.Errorf("Expected matched stop %q, got %q", "\n2.", got)
+	}
+
+	var chat ChatCompletionResponse
+	payload = `{"choices": [
+		{"index": 0, "message": {"role": "assistant", "content": "Done"}, "finish_reason": "stop", "stop_str": "</answer>"},
+		{"index": 1, "message": {"role": "assistant", "content": "Cut"}, "finish_reason": "length"}
+	]}`
+	if err := json.Unmarshal([]byte(payload), &chat); err != nil {
+		t.Fatalf("Failed to unmarshal chat completion: %v", err)
+	}
+	if chat.Choices[0].MatchedStop != "</answer>" || chat.Choices[1].MatchedStop != "" {
+		t.Errorf("Unexpected matched stops: %q, %q", chat.Choices[0].MatchedStop, chat.Choices[1].MatchedStop)
+	}
+}
+
+func TestCompletionLogProbs_Entries(t *testing.T) {
+	payload := `{
+		"tokens": ["Hello", ",", " world"],
+		"token_logprobs": [-0.1, -0.5, -1.25],
+		"top_logprobs": [{"Hello": -0.1, "Hi": -2.3}, {",": -0.5, "!": -1.1}, {" world": -1.25, " there": -1.5}],
+		"text_offset": [0, 5, 6]
+	}`
+	var lp CompletionLogProbs
+	if err := json.Unmarshal([]byte(payload), &lp); err != nil {
+		t.Fatalf("Failed to unmarshal logprobs: %v", err)
+	}
+
+	entries, err := lp.Entries()
+	if err != nil {
+		t.Fatalf("Entries returned an error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	last := entries[2]
+	if last.Token != " world" || last.LogProb != -1.25 || last.Offset != 6 {
+		t.Errorf("Unexpected entry: %+v", last)
+	}
+	if last.TopAlternatives[" there"] != -1.5 {
+		t.Errorf("Expected alternative \" there\", got %v", last.TopAlternatives)
+	}
+
+	// Offsets and alternatives are optional, but must match when present
+	lp.TopLogProbs, lp.TextOffset = nil, nil
+	if entries, err := lp.Entries(); err != nil || entries[1].TopAlternatives != nil {
+		t.Errorf("Expected entries without alternatives, got %+v, %v", entries, err)
+	}
+	lp.TextOffset = []int{0, 5}
+	if _, err := lp.Entries(); err == nil {
+		t.Error("Expected an error for mismatched text_offset")
+	}
+}
+
+func TestChatCompletionResponse_UnmarshalLogprobs(t *testing.T) {
+	payload := `{
+		"id": "chat-1",
+		"object": "chat.completion",
+		"choices": [{
+			"index": 0,
+			"message": {"role": "assistant", "content": "Hi there"},
+			"finish_reason": "stop",
+			"logprobs": {
+				"content": [
+					{"token": "Hi", "logprob": -0.1, "top_logprobs": [{"token": "Hi", "logprob": -0.1}, {"token": "Hello", "logprob": -2.3}]},
+					{"token": " there", "logprob": -0.5, "top_logprobs": []}
+				]
+			}
+		}]
+	}`
+
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	lp := resp.Choices[0].Logprobs
+	if lp == nil {
+		t.Fatal("Expected logprobs, got nil")
+	}
+	if len(lp.Content) != 2 {
+		t.Fatalf("Expected 2 token logprobs, got %d", len(lp.Content))
+	}
+	if lp.Content[0].Token != "Hi" || lp.Content[0].Logprob != -0.1 {
+		t.Errorf("Unexpected first token: %+v", lp.Content[0])
+	}
+	if len(lp.Content[0].TopLogprobs) != 2 || lp.Content[0].TopLogprobs[1].Token != "Hello" {
+		t.Errorf("Unexpected top logprobs: %+v", lp.Content[0].TopLogprobs)
+	}
+
+	data, err := json.Marshal(&ChatCompletionRequest{Logprobs: true, TopLogprobs: 3})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	if !strings.Contains(string(data), `"logprobs":true`) || !strings.Contains(string(data), `"top_logprobs":3`) {
+		t.Errorf("Expected logprobs fields in request JSON, got %s", data)
+	}
+}
+
+func TestCompletionRequest_RolePrompt(t *testing.T) {
+	req := &CompletionRequest{
+		Prompt: RolePrompt(
+			ChatMessage{Role: ChatMessageRoleSystem, Content: "Answer tersely."},
+			ChatMessage{Role: ChatMessageRoleUser, Content: []ChatMessageContent{{Type: "text", Text: "Capital of France?"}}},
+		),
+		MaxTokens: 8,
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate returned an error: %v", err)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	want := `"prompt":[{"role":"system","content":"Answer tersely."},{"role":"user","content":"Capital of France?"}]`
+	if !strings.Contains(string(data), want) {
+		t.Fatalf("Unexpected payload:\n got %s\nwant %s", data, want)
+	}
+
+	// The decoded form validates too, and keeps its roles through ToChat
+	var decoded CompletionRequest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if err := decoded.Validate(); err != nil {
+		t.Errorf("Validate returned an error for the decoded prompt: %v", err)
+	}
+	chat, err := decoded.ToChat("")
+	if err != nil {
+		t.Fatalf("ToChat returned an error: %v", err)
+	}
+	if len(chat.Messages) != 2 || chat.Messages[0].Role != ChatMessageRoleSystem || chat.Messages[1].Content != "Capital of France?" {
+		t.Errorf("Unexpected chat messages: %+v", chat.Messages)
+	}
+
+	var validationErr *ValidationError
+	for _, prompt := range []interface{}{nil, []PromptMessage{}, []PromptMessage{{Content: "no role"}}, []interface{}{map[string]interface{}{"role": "user"}}, 42} {
+		req := &CompletionRequest{Prompt: prompt}
+		if err := req.Validate(); !errors.As(err, &validationErr) || validationErr.Field != "prompt" {
+			t.Errorf("Expected a prompt validation error for %#v, got %v", prompt, err)
+		}
+	}
+}
+
+func TestCompletionRequest_ToChat(t *testing.T) {
+	req := &CompletionRequest{
+		Prompt:                 "Write a haiku",
+		MaxTokens:              64,
+		Temperature:            Float64(0),
+		TopP:                   Float64(0.9),
+		TopK:                   40,
+		Stream:                 true,
+		Stop:                   []string{"\n\n"},
+		Model:                  "my-model",
+		JSONSchema:             map[string]interface{}{"type": "string"},
+		SkipQueue:              true,
+		IncludeStopStrInOutput: true,
+		BannedTokens:           []int{1},
+		BannedStrings:          []string{"foo"},
+		AllowedTokens:          []int{2},
+		AllowedStrings:         []string{"bar"},
+	}
+
+	chat, err := req.ToChat("You are a poet.")
+	if err != nil {
+		t.Fatalf("ToChat returned an error: %v", err)
+	}
+
+	if len(chat.Messages) != 2 ||
+		chat.Messages[0].Role != ChatMessageRoleSystem || chat.Messages[0].Content != "You are a poet." ||
+		chat.Messages[1].Role != ChatMessageRoleUser || chat.Messages[1].Content != "Write a haiku" {
+		t.Errorf("Expected system and user messages, got %+v", chat.Messages)
+	}
+	if chat.MaxTokens != 64 || chat.TopK != 40 || chat.Model != "my-model" || !chat.SkipQueue || !chat.IncludeStopStrInOutput {
+		t.Errorf("Expected scalar settings to carry over, got %+v", chat)
+	}
+	if chat.Temperature == nil || *chat.Temperature != 0 || chat.TopP == nil || *chat.TopP != 0.9 {
+		t.Errorf("Expected temperature 0 and top_p 0.9, got %v and %v", chat.Temperature, chat.TopP)
+	}
+	if len(chat.Stop) != 1 || chat.Stop[0] != "\n\n" || chat.JSONSchema == nil {
+		t.Errorf("Expected stop and schema to carry over, got %q and %v", chat.Stop, chat.JSONSchema)
+	}
+	if len(chat.BannedTokens) != 1 || len(chat.BannedStrings) != 1 || len(chat.AllowedTokens) != 1 || len(chat.AllowedStrings) != 1 {
+		t.Errorf("Expected token filters to carry over, got %+v", chat)
+	}
+	if chat.Stream {
+		t.Error("Expected Stream not to carry over")
+	}
+
+	// The requests do not share mutable state
+	*req.Temperature = 1
+	req.Stop[0] = "changed"
+	req.JSONSchema.(map[string]interface{})["type"] = "object"
+	if *chat.Temperature != 0 || chat.Stop[0] != "\n\n" {
+		t.Error("Expected ToChat to copy pointers and slices")
+	}
+	if chat.JSONSchema.(map[string]interface{})["type"] != "string" {
+		t.Error("Expected ToChat to copy the schema")
+	}
+}
+
+func TestCompletionRequest_ToChat_PromptForms(t *testing.T) {
+	if chat, _ := (&CompletionRequest{Prompt: "hi"}).ToChat(""); len(chat.Messages) != 1 || chat.Messages[0].Role != ChatMessageRoleUser {
+		t.Errorf("Expected a single user message without a system prompt, got %+v", chat.Messages)
+	}
+	if chat, _ := (&CompletionRequest{Prompt: []string{"a", "b"}}).ToChat(""); len(chat.Messages) != 2 || chat.Messages[1].Content != "b" {
+		t.Errorf("Expected one user message per prompt entry, got %+v", chat.Messages)
+	}
+
+	var validationErr *ValidationError
+	for _, prompt := range []interface{}{[]int{1, 2, 3}, [][]int{{1}, {2}}, []interface{}{1.0, 2.0}} {
+		chat, err := (&CompletionRequest{Prompt: prompt}).ToChat("")
+		if !errors.As(err, &validationErr) || validationErr.Field != "prompt" || chat != nil {
+			t.Errorf("Expected a prompt validation error for %#v, got %v", prompt, err)
+		}
+	}
+}
+
+func TestLoraList_CompatibleWith(t *testing.T) {
+	var list LoraList
+	data := `{"object":"list","data":[
+		{"id":"style","base_model":"Llama-3-8B"},
+		{"id":"legal","base_model":"Mistral-7B"},
+		{"id":"unknown"}
+	]}`
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	var ids []string
+	for _, card := range list.CompatibleWith("llama-3-8b") {
+		ids = append(ids, card.ID)
+	}
+	if strings.Join(ids, ",") != "style,unknown" {
+		t.Errorf("Expected adapters style and unknown, got %v", ids)
+	}
+}
+
+func TestModelList_ByOwner(t *testing.T) {
+	var list ModelList
+	data := `{"object":"list","data":[
+		{"id":"Llama-3-8B-exl2","owned_by":"tabbyAPI"},
+		{"id":"turboderp/Mistral-7B-exl2","owned_by":"turboderp"},
+		{"id":"turboderp/Qwen2-7B-exl2","owned_by":"TurboDerp"}
+	]}`
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	var ids []string
+	for _, card := range list.ByOwner("turboderp") {
+		ids = append(ids, card.ID)
+		if !card.IsHubReference() {
+			t.Errorf("Expected %s to be a hub reference", card.ID)
+		}
+	}
+	if strings.Join(ids, ",") != "turboderp/Mistral-7B-exl2,turboderp/Qwen2-7B-exl2" {
+		t.Errorf("Expected both turboderp models, got %v", ids)
+	}
+	if list.Data[0].IsHubReference() {
+		t.Errorf("Expected %s to be a local model", list.Data[0].ID)
+	}
+	if len(list.ByOwner("nobody")) != 0 {
+		t.Error("Expected no models for an unknown owner")
+	}
+}
+
+func TestModelLoadResponse_Progress(t *testing.T) {
+	tests := []struct {
+		name        string
+		resp        ModelLoadResponse
+		done, total int
+		pct         float64
+	}{
+		{"total unknown", ModelLoadResponse{Module: 3, Modules: 0}, 3, 0, 0},
+		{"halfway", ModelLoadResponse{Module: 2, Modules: 4}, 2, 4, 50},
+		{"complete", ModelLoadResponse{Module: 4, Modules: 4}, 4, 4, 100},
+		{"overshoot", ModelLoadResponse{Module: 5, Modules: 4}, 5, 4, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done, total, pct := tt.resp.Progress()
+			if done != tt.done || total != tt.total || pct != tt.pct {
+				t.Errorf("Expected (%d, %d, %v), got (%d, %d, %v)", tt.done, tt.total, tt.pct, done, total, pct)
+			}
+			if math.IsNaN(pct) {
+				t.Error("Expected a number, got NaN")
+			}
+		})
+	}
+}